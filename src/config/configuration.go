@@ -6,16 +6,32 @@ import "time"
 
 type (
 	Configuration struct {
-		AuthConfig struct {
-			AccessTTL  time.Duration `yaml:"auth.accessTTL"`
-			RefreshTTL time.Duration `yaml:"auth.refreshTTL"`
-		} `yaml:"user_service.auth"`
+		AuthConfig AuthConfig `yaml:"user_service.auth"`
 
 		GRPCConfig struct {
 			Host string `yaml:"host"`
 			Port int32  `yaml:"port"`
 		} `yaml:"user_service.grpc"`
 
+		HTTPConfig struct {
+			Host string `yaml:"host"`
+			Port int32  `yaml:"port"`
+		} `yaml:"user_service.http"`
+
+		MailConfig MailConfig `yaml:"user_service.mail"`
+
+		SmsConfig SmsConfig `yaml:"user_service.sms"`
+
+		AccountConfig AccountConfig `yaml:"user_service.account"`
+
+		LoginConfig LoginConfig `yaml:"user_service.login"`
+
+		PasswordConfig PasswordConfig `yaml:"user_service.password"`
+
+		HashConfig HashConfig `yaml:"user_service.hash"`
+
+		MfaConfig MfaConfig `yaml:"user_service.mfa"`
+
 		CQRSConfig struct {
 			PersistConfig PersistConfig `yaml:"persist"`
 
@@ -30,6 +46,134 @@ type (
 		} `yaml:"user_service.cqrs"`
 	}
 
+	AuthConfig struct {
+		AccessTTL  time.Duration `yaml:"auth.accessTTL"`
+		RefreshTTL time.Duration `yaml:"auth.refreshTTL"`
+		// Algorithm is one of RS256, ES256 or EdDSA
+		Algorithm  string    `yaml:"auth.algorithm"`
+		AccessKey  KeyConfig `yaml:"auth.accessKey"`
+		RefreshKey KeyConfig `yaml:"auth.refreshKey"`
+		// retired keys are only used to verify tokens signed before a rotation
+		RetiredAccessKeys  []KeyConfig `yaml:"auth.retiredAccessKeys"`
+		RetiredRefreshKeys []KeyConfig `yaml:"auth.retiredRefreshKeys"`
+		// Issuer is the iss claim of every token, refresh tokens are only accepted by the issuer itself
+		Issuer string `yaml:"auth.issuer"`
+		// Audiences are the services access tokens are issued for, at least one must be in aud
+		Audiences []string      `yaml:"auth.audiences"`
+		Leeway    time.Duration `yaml:"auth.leeway"` // seconds
+
+		Permissions PermissionClaimConfig `yaml:"auth.permissions"`
+
+		// ValidationMode is "stateful" (default) to check every access token against
+		// the cache, or "stateless" to trust signature and claims plus the revocation list
+		ValidationMode string `yaml:"auth.validationMode"`
+		// RevocationReload is the interval of full revocation list reloads, in seconds
+		RevocationReload time.Duration `yaml:"auth.revocationReload"`
+	}
+
+	// MailConfig selects how mails are delivered, the outbox driver writes every
+	// mail to a file in OutboxDir instead of sending it, for local development and tests
+	MailConfig struct {
+		Driver    string `yaml:"mail.driver"` // smtp or outbox
+		Host      string `yaml:"mail.host"`
+		Port      int32  `yaml:"mail.port"`
+		UserName  string `yaml:"mail.userName"`
+		Password  string `yaml:"mail.password"`
+		From      string `yaml:"mail.from"`
+		OutboxDir string `yaml:"mail.outboxDir"`
+	}
+
+	// SmsConfig selects how text messages are delivered, the http driver posts them
+	// to the gateway at Url, log and outbox only log them or write them to files in
+	// OutboxDir, for local development and tests
+	SmsConfig struct {
+		Driver    string `yaml:"sms.driver"` // http, log or outbox
+		Url       string `yaml:"sms.url"`
+		ApiKey    string `yaml:"sms.apiKey"`
+		From      string `yaml:"sms.from"`
+		OutboxDir string `yaml:"sms.outboxDir"`
+	}
+
+	// AccountConfig holds the lifetimes of the account flows
+	AccountConfig struct {
+		ActivationTTL    time.Duration `yaml:"account.activationTTL"`    // minutes
+		PasswordResetTTL time.Duration `yaml:"account.passwordResetTTL"` // minutes
+		// DeletionGracePeriod is how long deleted accounts are kept before they are purged
+		DeletionGracePeriod time.Duration `yaml:"account.deletionGracePeriod"` // days
+		PurgeInterval       time.Duration `yaml:"account.purgeInterval"`       // minutes
+		RestoreTTL          time.Duration `yaml:"account.restoreTTL"`          // minutes
+		MobileCodeTTL       time.Duration `yaml:"account.mobileCodeTTL"`       // minutes
+		// MobileCodeAttempts is how often a mobile verification code may be entered wrong
+		MobileCodeAttempts int `yaml:"account.mobileCodeAttempts"`
+	}
+
+	// LoginConfig holds the limits of failed sign ins. Every failure within Window
+	// beyond DelayAfter doubles the time until the next attempt, starting at one second
+	// and capped at MaxDelay. LockoutThreshold failures lock the user name and
+	// IpThreshold failures the ip address for LockoutDuration
+	LoginConfig struct {
+		Window           time.Duration `yaml:"login.window"` // minutes
+		DelayAfter       int           `yaml:"login.delayAfter"`
+		MaxDelay         time.Duration `yaml:"login.maxDelay"` // seconds
+		LockoutThreshold int           `yaml:"login.lockoutThreshold"`
+		IpThreshold      int           `yaml:"login.ipThreshold"`
+		LockoutDuration  time.Duration `yaml:"login.lockoutDuration"` // minutes
+	}
+
+	// PasswordConfig is the policy new passwords must meet, rules with a zero value
+	// are off. BreachedDir points to SHA-1 range files of known breached passwords
+	PasswordConfig struct {
+		MinLength int `yaml:"password.minLength"`
+		MaxLength int `yaml:"password.maxLength"`
+		// MinClasses of lower case letters, upper case letters, digits and symbols
+		MinClasses     int    `yaml:"password.minClasses"`
+		ForbidUserInfo bool   `yaml:"password.forbidUserInfo"` // user name and email
+		MaxRepeat      int    `yaml:"password.maxRepeat"`
+		BreachedDir    string `yaml:"password.breachedDir"`
+	}
+
+	// HashConfig selects how new passwords are hashed, older hashes are upgraded
+	// on the next sign in
+	HashConfig struct {
+		Algorithm         string `yaml:"hash.algorithm"` // bcrypt or argon2id
+		BcryptCost        int    `yaml:"hash.bcryptCost"`
+		Argon2Memory      uint32 `yaml:"hash.argon2Memory"` // KiB
+		Argon2Time        uint32 `yaml:"hash.argon2Time"`
+		Argon2Parallelism uint8  `yaml:"hash.argon2Parallelism"`
+	}
+
+	// MfaConfig configures the TOTP second factor, EncryptionKey is the path of the
+	// file with the base64 encoded 32 byte key TOTP secrets are encrypted with
+	MfaConfig struct {
+		Issuer        string `yaml:"mfa.issuer"`
+		EncryptionKey string `yaml:"mfa.encryptionKey"`
+		Skew          int    `yaml:"mfa.skew"` // time steps accepted before and after the current one
+		// ChallengeTTL is how long the second factor can be given after the password, in minutes
+		ChallengeTTL      time.Duration `yaml:"mfa.challengeTTL"`
+		ChallengeAttempts int           `yaml:"mfa.challengeAttempts"`
+		RecoveryCodes     int           `yaml:"mfa.recoveryCodes"`
+	}
+
+	// PermissionClaimConfig controls how user permissions are embedded in access tokens
+	PermissionClaimConfig struct {
+		Embed bool `yaml:"embed"`
+		// MaxCount caps the embedded permissions, users with more are flagged instead
+		MaxCount int `yaml:"maxCount"`
+		// Compact encodes every permission as a "key:value" string
+		Compact bool `yaml:"compact"`
+	}
+
+	// KeyConfig points to the PEM files of a signing key pair, relative paths
+	// are resolved against the directory of the configuration file
+	KeyConfig struct {
+		// Id is published as the kid header, the RFC 7638 thumbprint is used when empty
+		Id         string `yaml:"id"`
+		PrivateKey string `yaml:"privateKey"`
+		PublicKey  string `yaml:"publicKey"`
+		// Algorithm overrides auth.algorithm for this key
+		Algorithm string `yaml:"algorithm"`
+	}
+
 	PersistConfig struct {
 		Host              string `yaml:"mongo.host"`
 		Port              int32  `yaml:"mongo.port"`
@@ -38,7 +182,11 @@ type (
 		Password          string `yaml:"mongo.password"`
 		UserDatabase      string `yaml:"mongo.userDatabase"`
 		UserCollection    string `yaml:"mongo.userCollection"`
+		ClientCollection  string `yaml:"mongo.clientCollection"`
 		ConnectionTimeout int64  `yaml:"mongo.connectionTimeout"`
+		// AuditCollection holds the audit events, they are removed after AuditRetention days
+		AuditCollection string        `yaml:"mongo.auditCollection"`
+		AuditRetention  time.Duration `yaml:"mongo.auditRetention"`
 		// todo :: add max pull size and read concern and other options
 	}
 )
@@ -5,6 +5,7 @@ package config
 import (
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
+	"path/filepath"
 )
 
 func LoadConfiguration(path string) (*Configuration, error) {
@@ -19,5 +20,34 @@ func LoadConfiguration(path string) (*Configuration, error) {
 		return nil, err
 	}
 
+	base := filepath.Dir(path)
+	config.AuthConfig.AccessKey.resolve(base)
+	config.AuthConfig.RefreshKey.resolve(base)
+	for i := range config.AuthConfig.RetiredAccessKeys {
+		config.AuthConfig.RetiredAccessKeys[i].resolve(base)
+	}
+	for i := range config.AuthConfig.RetiredRefreshKeys {
+		config.AuthConfig.RetiredRefreshKeys[i].resolve(base)
+	}
+	config.MailConfig.OutboxDir = resolvePath(base, config.MailConfig.OutboxDir)
+	config.SmsConfig.OutboxDir = resolvePath(base, config.SmsConfig.OutboxDir)
+	config.PasswordConfig.BreachedDir = resolvePath(base, config.PasswordConfig.BreachedDir)
+	config.MfaConfig.EncryptionKey = resolvePath(base, config.MfaConfig.EncryptionKey)
+
 	return &config, nil
 }
+
+// resolve makes the key paths relative to the configuration file instead of
+// the working directory of the process
+func (k *KeyConfig) resolve(base string) {
+	k.PrivateKey = resolvePath(base, k.PrivateKey)
+	k.PublicKey = resolvePath(base, k.PublicKey)
+}
+
+func resolvePath(base string, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(base, path)
+}
@@ -10,3 +10,13 @@ func TestReader_LoadConfiguration(t *testing.T) {
 	require.Nil(t, err)
 	require.Equal(t, conf.GRPCConfig.Host, "localhost")
 }
+
+func TestReader_LoadConfiguration_ResolveKeyPaths(t *testing.T) {
+	conf, err := LoadConfiguration("../user-service_test.yml")
+	require.Nil(t, err)
+	require.Equal(t, "../keys/test/access.pem", conf.AuthConfig.AccessKey.PrivateKey)
+	require.Equal(t, "../keys/test/refresh.pub.pem", conf.AuthConfig.RefreshKey.PublicKey)
+	require.Equal(t, "../outbox", conf.MailConfig.OutboxDir)
+	require.Equal(t, "../outbox/sms", conf.SmsConfig.OutboxDir)
+	require.Equal(t, "../keys/test/mfa.key", conf.MfaConfig.EncryptionKey)
+}
@@ -6,6 +6,6 @@ type IConverter interface {
 	Convert(input interface{}) (output interface{}, err error)
 }
 
-func NewConverter(tInput interface{}, tOutput interface{}, logger logger.ILogger) Converter {
+func NewConverter(tInput interface{}, tOutput interface{}, logger logger.ILogger) IConverter {
 	panic("Not implemented")
 }
@@ -0,0 +1,61 @@
+package entity
+
+import (
+	"time"
+)
+
+type (
+	AuditEventType string
+	AuditOutcome   string
+)
+
+const (
+	SignInEvent     AuditEventType = "sign-in"
+	RefreshEvent    AuditEventType = "refresh"
+	TokenReuseEvent AuditEventType = "token-reuse"
+	// PasswordChangeEvent is a change with the old password, PasswordResetEvent one with a reset token
+	PasswordChangeEvent  AuditEventType = "password-change"
+	PasswordResetEvent   AuditEventType = "password-reset"
+	ProfileChangeEvent   AuditEventType = "profile-change"
+	AccountDeletionEvent AuditEventType = "account-deletion"
+	AccountRestoreEvent  AuditEventType = "account-restore"
+	MfaEnableEvent       AuditEventType = "mfa-enable"
+	MfaDisableEvent      AuditEventType = "mfa-disable"
+	// The admin actions, their actor is the admin
+	RestrictUserEvent    AuditEventType = "restrict-user"
+	LiftRestrictionEvent AuditEventType = "lift-restriction"
+	RegisterClientEvent  AuditEventType = "register-client"
+
+	Succeeded AuditOutcome = "success"
+	Failed    AuditOutcome = "failure"
+	// Challenged is a sign in whose password was right and which waits for the second factor
+	Challenged AuditOutcome = "challenged"
+
+	// The paths to access audit events in database
+	AuditTypePath      Path = "type"
+	AuditActorPath     Path = "actor"
+	AuditTargetPath    Path = "target"
+	AuditIpAddressPath Path = "ip-address"
+	AuditOutcomePath   Path = "outcome"
+	AuditTimePath      Path = "time"
+)
+
+// AuditEvent records a security relevant operation, events are never changed
+// once written and expire after the audit retention
+type AuditEvent struct {
+	EventId string         `bson:"event-id"`
+	Type    AuditEventType `bson:"type"`
+	// Actor is the user or client id which did the operation, empty when it is not known
+	Actor string `bson:"actor"`
+	// Target is the user or client id the operation was done to
+	Target    string       `bson:"target"`
+	IpAddress string       `bson:"ip-address"`
+	UserAgent string       `bson:"user-agent"`
+	Outcome   AuditOutcome `bson:"outcome"`
+	// Reason is the error of a failed operation
+	Reason string `bson:"reason"`
+	// Details hold what is specific to the type, like the login of a sign in
+	Details         map[string]string `bson:"details"`
+	Time            *time.Time        `bson:"time"`
+	DocumentVersion string            `bson:"document-version"`
+}
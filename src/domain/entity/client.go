@@ -0,0 +1,23 @@
+package entity
+
+import (
+	"time"
+)
+
+const (
+	// The paths to access client data in database
+	ClientIdPath Path = "client-id"
+)
+
+// Client is a backend service which authenticates with the client credentials
+// grant, its tokens carry the client id as subject and the granted scopes
+type Client struct {
+	ClientId        string     `bson:"client-id"`
+	Name            string     `bson:"name"`
+	SecretHash      string     `bson:"secret-hash"`
+	Scopes          []string   `bson:"scopes"`
+	CreatedAt       *time.Time `bson:"created-at"`
+	UpdatedAt       *time.Time `bson:"updated-at"`
+	DeletedAt       *time.Time `bson:"deleted-at"`
+	DocumentVersion string     `bson:"document-version"`
+}
@@ -0,0 +1,133 @@
+package entity
+
+import (
+	"errors"
+	"golang.org/x/text/unicode/norm"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	MinUserNameLength int = 3
+	MaxUserNameLength int = 32
+)
+
+var (
+	ErrInvalidUserName    = errors.New("user name may only contain letters, digits, '.', '_' and '-'")
+	ErrUserNameLength     = errors.New("user name must have 3 to 32 characters")
+	ErrConfusableUserName = errors.New("user name mixes scripts or imitates latin letters")
+	ErrInvalidEmail       = errors.New("invalid email address")
+)
+
+// Letters of other scripts which look like latin ones, names written only with
+// them pass as latin names
+var latinLookalikes = map[string]string{
+	"Cyrillic": "аеорсухіјѕԁԛԝһӏ",
+	"Greek":    "αικνορτυχ",
+}
+
+// NormalizeUserName returns the form user names are stored and shown in, the
+// unicode NFKC form without surrounding spaces. Names must not mix scripts or
+// only consist of letters which imitate latin ones, so names can not be spoofed
+func NormalizeUserName(userName string) (string, error) {
+	name := norm.NFKC.String(strings.TrimSpace(userName))
+
+	length := utf8.RuneCountInString(name)
+	if length < MinUserNameLength || length > MaxUserNameLength {
+		return "", ErrUserNameLength
+	}
+
+	script := ""
+	for _, r := range foldCase(name) {
+		switch {
+		case r == '.' || r == '_' || r == '-' || (r >= '0' && r <= '9'):
+			continue
+		case unicode.Is(unicode.Mn, r):
+			// combining marks belong to the letter before them
+			continue
+		case !unicode.IsLetter(r):
+			return "", ErrInvalidUserName
+		}
+
+		letterScript := scriptOf(r)
+		if letterScript == "" {
+			continue
+		}
+		if script == "" {
+			script = letterScript
+		} else if script != letterScript {
+			return "", ErrConfusableUserName
+		}
+	}
+
+	if lookalikes, ok := latinLookalikes[script]; ok && onlyLookalikes(foldCase(name), lookalikes) {
+		return "", ErrConfusableUserName
+	}
+
+	return name, nil
+}
+
+// CanonicalUserName is the case insensitive form user names are compared in
+func CanonicalUserName(userName string) string {
+	return foldCase(norm.NFKC.String(strings.TrimSpace(userName)))
+}
+
+// NormalizeEmail returns the form emails are stored and shown in, the unicode NFKC
+// form with lower-cased domain, the local part is kept as the user typed it
+func NormalizeEmail(email string) (string, error) {
+	address := norm.NFKC.String(strings.TrimSpace(email))
+
+	at := strings.LastIndex(address, "@")
+	if at <= 0 || at == len(address)-1 || strings.ContainsAny(address, " \t\r\n") {
+		return "", ErrInvalidEmail
+	}
+
+	return address[:at] + "@" + strings.ToLower(address[at+1:]), nil
+}
+
+// CanonicalEmail is the case insensitive form emails are compared in, although the
+// local part is case sensitive by the standard no mail provider treats it that way
+func CanonicalEmail(email string) string {
+	return foldCase(norm.NFKC.String(strings.TrimSpace(email)))
+}
+
+// foldCase applies the simple unicode case folding, characters which only differ
+// in case end up the same
+func foldCase(s string) string {
+	folded := strings.Map(func(r rune) rune {
+		return unicode.ToLower(unicode.ToUpper(r))
+	}, s)
+
+	return norm.NFKC.String(folded)
+}
+
+// scriptOf returns the unicode script of r, the scripts commonly written together
+// in chinese, japanese and korean count as one. Characters shared by scripts have none
+func scriptOf(r rune) string {
+	for name, table := range unicode.Scripts {
+		if !unicode.Is(table, r) {
+			continue
+		}
+
+		switch name {
+		case "Common", "Inherited":
+			return ""
+		case "Han", "Hiragana", "Katakana", "Hangul":
+			return "CJK"
+		}
+		return name
+	}
+
+	return ""
+}
+
+func onlyLookalikes(name string, lookalikes string) bool {
+	for _, r := range name {
+		if unicode.IsLetter(r) && !strings.ContainsRune(lookalikes, r) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,58 @@
+package entity
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNormalizeUserName(t *testing.T) {
+	name, err := NormalizeUserName("  Ｊｕｎｏ_Chat ")
+	require.Nil(t, err)
+	require.Equal(t, "Juno_Chat", name)
+
+	name, err = NormalizeUserName("Дмитрий")
+	require.Nil(t, err)
+	require.Equal(t, "Дмитрий", name)
+
+	name, err = NormalizeUserName("ユーザー名")
+	require.Nil(t, err)
+	require.Equal(t, "ユーザー名", name)
+
+	_, err = NormalizeUserName("ab")
+	require.Equal(t, ErrUserNameLength, err)
+
+	_, err = NormalizeUserName("juno chat")
+	require.Equal(t, ErrInvalidUserName, err)
+
+	_, err = NormalizeUserName("juno\u200bchat")
+	require.Equal(t, ErrInvalidUserName, err)
+
+	// a cyrillic "а" between latin letters
+	_, err = NormalizeUserName("pаypal")
+	require.Equal(t, ErrConfusableUserName, err)
+
+	// only cyrillic letters, but all of them look latin
+	_, err = NormalizeUserName("рауха")
+	require.Equal(t, ErrConfusableUserName, err)
+}
+
+func TestCanonicalUserName(t *testing.T) {
+	require.Equal(t, CanonicalUserName("juno"), CanonicalUserName("JUNO"))
+	require.Equal(t, CanonicalUserName("juno"), CanonicalUserName("Ｊｕｎｏ"))
+	// the kelvin sign is a capital k
+	require.Equal(t, CanonicalUserName("kilo"), CanonicalUserName("\u212Ailo"))
+	require.NotEqual(t, CanonicalUserName("juno"), CanonicalUserName("juno1"))
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	email, err := NormalizeEmail(" John.Doe@Juno.CHAT ")
+	require.Nil(t, err)
+	require.Equal(t, "John.Doe@juno.chat", email)
+
+	for _, invalid := range []string{"", "juno", "@juno.chat", "john@", "john doe@juno.chat"} {
+		_, err = NormalizeEmail(invalid)
+		require.Equal(t, ErrInvalidEmail, err, invalid)
+	}
+
+	require.Equal(t, CanonicalEmail("john.doe@juno.chat"), CanonicalEmail("John.Doe@Juno.CHAT"))
+}
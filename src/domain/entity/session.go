@@ -0,0 +1,19 @@
+package entity
+
+import (
+	"time"
+)
+
+// Session is a signed in device of a user, it is backed by one refresh token
+// family and lives in the cache as long as its refresh token
+type Session struct {
+	SessionId      string     `json:"session-id"`
+	UserId         string     `json:"user-id"`
+	AccessUUid     string     `json:"access-uuid"`
+	AccessExpireAt int64      `json:"access-expire-at"`
+	RefreshUUid    string     `json:"refresh-uuid"`
+	IpAddress      string     `json:"ip-address"`
+	UserAgent      string     `json:"user-agent"`
+	CreatedAt      *time.Time `json:"created-at"`
+	LastRefreshAt  *time.Time `json:"last-refresh-at"`
+}
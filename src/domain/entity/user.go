@@ -10,40 +10,126 @@ type (
 )
 
 const (
-	Active          Status = "active"
-	Inactive        Status = "inactive"
+	Active   Status = "active"
+	Inactive Status = "inactive"
+	// Suspended and Banned users are locked out by an admin, suspensions may end by themselves
+	Suspended Status = "suspended"
+	Banned    Status = "banned"
+	// Pending users signed up but did not verify their email yet
+	Pending         Status = "pending"
 	DocumentVersion string = "v0.0.1"
 
 	// The paths to access data in database
-	UserNamePath  Path = "user-name"
-	EmailPath     Path = "contact-info.email"
-	StatusPath    Path = "status.user-status"
-	PasswordPath  Path = "password"
-	UserIdPath    Path = "user-id"
-	DeletedAtPath Path = "deleted-at"
+	UserNamePath        Path = "user-name"
+	EmailPath           Path = "contact-info.email"
+	MobilePath          Path = "contact-info.mobile"
+	PhonePath           Path = "contact-info.phone"
+	EmailVerifiedPath   Path = "contact-info.email-verified"
+	MobileVerifiedPath  Path = "contact-info.mobile-verified"
+	StatusPath          Path = "status.user-status"
+	StatusUpdatedAtPath Path = "status.updated-at"
+	DeletedStatusPath   Path = "status.deleted-status"
+	PasswordPath        Path = "password"
+	UserIdPath          Path = "user-id"
+	DeletedAtPath       Path = "deleted-at"
+	UpdatedAtPath       Path = "updated-at"
+	PurgeAtPath         Path = "purge-at"
+	UserStatusPath      Path = "status"
+
+	ProfilePath     Path = "profile"
+	DisplayNamePath Path = "profile.display-name"
+	BioPath         Path = "profile.bio"
+	AvatarUrlPath   Path = "profile.avatar-url"
+	LocalePath      Path = "profile.locale"
+
+	// The canonical paths hold the case insensitive forms user names and emails are looked up with
+	CanonicalUserNamePath Path = "canonical-user-name"
+	CanonicalEmailPath    Path = "canonical-email"
+
+	// The paths of the second factor
+	MfaPath              Path = "mfa"
+	MfaSecretPath        Path = "mfa.secret"
+	MfaLastCounterPath   Path = "mfa.last-counter"
+	MfaRecoveryCodesPath Path = "mfa.recovery-codes"
 )
 
 type User struct {
-	UserName        string        `bson:"user-name"`
-	Password        string        `bson:"password"`
-	UserId          string        `bson:"user-id"`
-	Status          *UserStatus   `bson:"status"`
-	ContactInfo     *ContactInfo  `bson:"contact-info"`
-	Permissions     []*Permission `bson:"permissions"`
-	CreatedAt       *time.Time    `bson:"created-at"`
-	UpdatedAt       *time.Time    `bson:"updated-at"`
-	DeletedAt       *time.Time    `bson:"deleted-at"`
-	DocumentVersion string        `bson:"document-version"`
+	UserName    string        `bson:"user-name"`
+	Password    string        `bson:"password"`
+	UserId      string        `bson:"user-id"`
+	Status      *UserStatus   `bson:"status"`
+	ContactInfo *ContactInfo  `bson:"contact-info"`
+	Profile     *Profile      `bson:"profile"`
+	Permissions []*Permission `bson:"permissions"`
+	CreatedAt   *time.Time    `bson:"created-at"`
+	UpdatedAt   *time.Time    `bson:"updated-at"`
+	DeletedAt   *time.Time    `bson:"deleted-at"`
+	// PurgeAt is when a deleted user is removed for good
+	PurgeAt         *time.Time `bson:"purge-at"`
+	DocumentVersion string     `bson:"document-version"`
+	// CanonicalUserName and CanonicalEmail are maintained by the repository
+	CanonicalUserName string `bson:"canonical-user-name"`
+	CanonicalEmail    string `bson:"canonical-email"`
+	// Mfa is the second factor of the user, nil when none was ever enrolled
+	Mfa *Mfa `bson:"mfa"`
+}
+
+// Mfa is a TOTP second factor, it is pending until the user confirmed it with a first code
+type Mfa struct {
+	Enabled bool `bson:"enabled"`
+	// Secret is the TOTP secret encrypted with the mfa encryption key
+	Secret string `bson:"secret"`
+	// LastCounter is the time step of the last accepted code, codes are accepted once
+	LastCounter int64 `bson:"last-counter"`
+	// RecoveryCodes are the hashes of the unused recovery codes
+	RecoveryCodes []string   `bson:"recovery-codes"`
+	EnabledAt     *time.Time `bson:"enabled-at"`
 }
 
 type UserStatus struct {
-	Status         Status     `bson:"user-status"`
-	ActivationCode string     `bson:"activation-code"`
-	UpdatedAt      *time.Time `bson:"updated-at"`
+	Status Status `bson:"user-status"`
+	// ActivationCode is the hash of the code sent to verify the email
+	ActivationCode     string     `bson:"activation-code"`
+	ActivationExpireAt *time.Time `bson:"activation-expire-at"`
+	UpdatedAt          *time.Time `bson:"updated-at"`
+	// Restriction tells why a user is suspended or banned
+	Restriction *Restriction `bson:"restriction"`
+	// DeletedStatus is the status of a deleted user before its deletion, a restore brings it back
+	DeletedStatus Status `bson:"deleted-status,omitempty"`
+}
+
+type Restriction struct {
+	Reason string `bson:"reason"`
+	// Actor is the user id of the admin who applied the restriction
+	Actor   string     `bson:"actor"`
+	StartAt *time.Time `bson:"start-at"`
+	// EndAt is when a suspension lifts, bans and indefinite suspensions have none
+	EndAt *time.Time `bson:"end-at"`
+}
+
+// Effective returns the status at now, suspensions whose end passed count as active
+func (s *UserStatus) Effective(now time.Time) Status {
+	if s.Status == Suspended && s.Restriction != nil && s.Restriction.EndAt != nil && !now.Before(*s.Restriction.EndAt) {
+		return Active
+	}
+
+	return s.Status
 }
 
 type ContactInfo struct {
 	Mobile string `bson:"mobile"`
 	Phone  string `bson:"phone"`
 	Email  string `bson:"email"`
+	// EmailVerified and MobileVerified tell whether the user proved to own the channel,
+	// they are reset when the channel changes
+	EmailVerified  bool `bson:"email-verified"`
+	MobileVerified bool `bson:"mobile-verified"`
+}
+
+// Profile is what users tell about themselves, every field is optional
+type Profile struct {
+	DisplayName string `bson:"display-name"`
+	Bio         string `bson:"bio"`
+	AvatarUrl   string `bson:"avatar-url"`
+	Locale      string `bson:"locale"`
 }
@@ -0,0 +1,22 @@
+package entity
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestUserStatus_Effective(t *testing.T) {
+	now := time.Now()
+	later := now.Add(time.Hour)
+
+	suspended := UserStatus{Status: Suspended, Restriction: &Restriction{EndAt: &later}}
+	require.Equal(t, Suspended, suspended.Effective(now))
+	require.Equal(t, Active, suspended.Effective(later))
+
+	indefinite := UserStatus{Status: Suspended, Restriction: &Restriction{}}
+	require.Equal(t, Suspended, indefinite.Effective(later))
+
+	banned := UserStatus{Status: Banned, Restriction: &Restriction{}}
+	require.Equal(t, Banned, banned.Effective(later))
+}
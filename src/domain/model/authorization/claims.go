@@ -0,0 +1,129 @@
+package authorization
+
+import (
+	"encoding/json"
+	"github.com/dgrijalva/jwt-go"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"time"
+)
+
+type tokenType string
+
+const (
+	accessTokenType  tokenType = "access"
+	refreshTokenType tokenType = "refresh"
+
+	// registered claims of RFC 7519
+	issuer    string = "iss"
+	subject   string = "sub"
+	audience  string = "aud"
+	issuedAt  string = "iat"
+	notBefore string = "nbf"
+	exp       string = "exp"
+	jwtId     string = "jti"
+
+	// private claims
+	typ        string = "token_type"
+	familyUUid string = "family_id"
+	principal  string = "principal_type"
+	scope      string = "scope"
+)
+
+// newClaims fills the registered claims shared by access and refresh tokens
+func (j *iJwtHandler) newClaims(kind tokenType, id string, userId string, audiences []string, now time.Time, expireAt int64) jwt.MapClaims {
+	return jwt.MapClaims{
+		issuer:    j.issuer,
+		subject:   userId,
+		audience:  audiences,
+		issuedAt:  now.Unix(),
+		notBefore: now.Unix(),
+		exp:       expireAt,
+		jwtId:     id,
+		typ:       string(kind),
+	}
+}
+
+// validateClaims checks the registered claims with the configured leeway and
+// makes sure a token is only used as the kind it was issued for
+func (j *iJwtHandler) validateClaims(claims jwt.MapClaims, kind tokenType, audiences []string) error {
+	now := time.Now().Unix()
+	leeway := int64(j.leeway / time.Second)
+
+	if value, _ := claims[typ].(string); value != string(kind) {
+		return status.Error(http.StatusUnauthorized, "invalid token type")
+	}
+
+	if value, _ := claims[issuer].(string); value != j.issuer {
+		return status.Error(http.StatusUnauthorized, "invalid issuer")
+	}
+
+	if !containsAny(stringsClaim(claims, audience), audiences) {
+		return status.Error(http.StatusUnauthorized, "invalid audience")
+	}
+
+	expireAt, ok := numericClaim(claims, exp)
+	if !ok || now > expireAt+leeway {
+		return status.Error(http.StatusUnauthorized, "token is expired")
+	}
+
+	if issued, ok := numericClaim(claims, issuedAt); !ok || now < issued-leeway {
+		return status.Error(http.StatusUnauthorized, "token used before issued")
+	}
+
+	if before, ok := numericClaim(claims, notBefore); ok && now < before-leeway {
+		return status.Error(http.StatusUnauthorized, "token is not valid yet")
+	}
+
+	if value, _ := claims[subject].(string); value == "" {
+		return status.Error(http.StatusUnauthorized, "invalid subject")
+	}
+
+	if value, _ := claims[jwtId].(string); value == "" {
+		return status.Error(http.StatusUnauthorized, "invalid token id")
+	}
+
+	return nil
+}
+
+func numericClaim(claims jwt.MapClaims, name string) (int64, bool) {
+	switch value := claims[name].(type) {
+	case float64:
+		return int64(value), true
+	case json.Number:
+		v, err := value.Int64()
+		return v, err == nil
+	}
+
+	return 0, false
+}
+
+// stringsClaim reads a claim which is either a string or an array of strings, like aud
+func stringsClaim(claims jwt.MapClaims, name string) []string {
+	switch value := claims[name].(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+
+	return nil
+}
+
+func containsAny(values []string, allowed []string) bool {
+	for _, value := range values {
+		for _, a := range allowed {
+			if value == a {
+				return true
+			}
+		}
+	}
+
+	return false
+}
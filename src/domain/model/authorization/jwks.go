@@ -0,0 +1,138 @@
+package authorization
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// JsonWebKey is the public part of a signing key as described in RFC 7517
+type JsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JsonWebKeySet struct {
+	Keys []JsonWebKey `json:"keys"`
+}
+
+func newJsonWebKey(key crypto.PublicKey) (*JsonWebKey, error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return &JsonWebKey{
+			Kty: "RSA",
+			N:   encodeInt(k.N, 0),
+			E:   encodeInt(big.NewInt(int64(k.E)), 0),
+		}, nil
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		return &JsonWebKey{
+			Kty: "EC",
+			Crv: k.Curve.Params().Name,
+			X:   encodeInt(k.X, size),
+			Y:   encodeInt(k.Y, size),
+		}, nil
+	case ed25519.PublicKey:
+		return &JsonWebKey{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(k),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", key)
+	}
+}
+
+// thumbprint computes the RFC 7638 thumbprint of key which is used as default key id
+func thumbprint(key crypto.PublicKey) (string, error) {
+	jwk, err := newJsonWebKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	// only the required members, the field order of the struct is lexicographic
+	var members interface{}
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Crv, jwk.Kty, jwk.X, jwk.Y}
+	default:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	}
+
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// keySet publishes every access token verification key of the ring
+func (r *keyRing) keySet() (*JsonWebKeySet, error) {
+	set := JsonWebKeySet{
+		Keys: make([]JsonWebKey, 0, len(r.keys)),
+	}
+
+	for _, key := range r.keys {
+		jwk, err := newJsonWebKey(key.publicKey)
+		if err != nil {
+			return nil, err
+		}
+
+		jwk.Kid = key.id
+		jwk.Use = "sig"
+		jwk.Alg = key.method.Alg()
+		set.Keys = append(set.Keys, *jwk)
+	}
+
+	// the active key first, keeps the document stable between calls
+	sort.SliceStable(set.Keys, func(i, j int) bool {
+		if set.Keys[i].Kid == r.active.id || set.Keys[j].Kid == r.active.id {
+			return set.Keys[i].Kid == r.active.id
+		}
+		return set.Keys[i].Kid < set.Keys[j].Kid
+	})
+
+	return &set, nil
+}
+
+func encodeInt(n *big.Int, size int) string {
+	data := n.Bytes()
+	if len(data) < size {
+		padded := make([]byte, size)
+		copy(padded[size-len(data):], data)
+		data = padded
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
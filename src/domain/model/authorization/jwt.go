@@ -1,6 +1,19 @@
 package authorization
 
-import "github.com/Juno-chat-app/user-service/infra/logger"
+import (
+	"github.com/Juno-chat-app/user-service/config"
+	"github.com/Juno-chat-app/user-service/domain/entity"
+	"github.com/Juno-chat-app/user-service/infra/logger"
+	"time"
+)
+
+const (
+	DefaultIssuer string = "user-service"
+
+	// the kinds of principal an access token is issued to
+	UserPrincipal   string = "user"
+	ClientPrincipal string = "client"
+)
 
 type TokenDetail struct {
 	AccessToken     string
@@ -8,26 +21,75 @@ type TokenDetail struct {
 	AccessUUid      string
 	RefreshUUid     string
 	UserId          string
+	FamilyId        string
+	IssuedAt        int64
 	ExpireAt        int64
 	RefreshExpireAt int64
+	Permissions     []*entity.Permission
+	// PermissionsOverflow is set when the user has more permissions than the token carries
+	PermissionsOverflow bool
+	// PrincipalType tells whether UserId is a user or a client id
+	PrincipalType string
+	// Scopes are granted to client tokens only
+	Scopes []string
+	// MfaToken is returned by the sign in of a user with a second factor instead of the
+	// tokens, ExpireAt is then its expiry. The tokens are issued by CompleteMfa
+	MfaToken string
 }
 
 type IJwtHandler interface {
-	CreateAccessToken(userId string) (tokenDetail *TokenDetail, err error)
+	// CreateAccessToken issues a token pair, an empty familyId starts a new refresh token family
+	CreateAccessToken(userId string, familyId string, permissions []*entity.Permission) (tokenDetail *TokenDetail, err error)
+	// CreateClientToken issues an access token without refresh token for a machine client
+	CreateClientToken(clientId string, scopes []string) (tokenDetail *TokenDetail, err error)
 	ValidateAccessToken(accessToken string) (tokenDetail *TokenDetail, err error)
 	ValidateRefreshToken(refreshToken string) (tokenDetail *TokenDetail, err error)
+	PublicKeys() (keySet *JsonWebKeySet, err error)
 }
 
-func NewJwtHandler(accessTtl int64, refreshTtl int64, logger logger.ILogger) IJwtHandler {
+func NewJwtHandler(conf config.AuthConfig, logger logger.ILogger) (IJwtHandler, error) {
 	logger.Info("initial jwt-handler",
 		"method", "NewJwtHandler",
-		"access-ttl", accessTtl,
-		"refresh-ttl", refreshTtl)
+		"access-ttl", conf.AccessTTL,
+		"refresh-ttl", conf.RefreshTTL,
+		"algorithm", conf.Algorithm,
+		"issuer", conf.Issuer,
+		"audiences", conf.Audiences)
+
+	accessKeys, err := newKeyRing(conf.Algorithm, conf.AccessKey, conf.RetiredAccessKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshKeys, err := newKeyRing(conf.Algorithm, conf.RefreshKey, conf.RetiredRefreshKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("signing keys loaded",
+		"method", "NewJwtHandler",
+		"access-kid", accessKeys.active.id,
+		"access-keys", len(accessKeys.keys),
+		"refresh-kid", refreshKeys.active.id,
+		"refresh-keys", len(refreshKeys.keys))
 
 	handler := iJwtHandler{
-		accessTokenTtl:  accessTtl,
-		refreshTokenTtl: refreshTtl,
+		accessTokenTtl:  int64(conf.AccessTTL * time.Minute),
+		refreshTokenTtl: int64(conf.RefreshTTL * time.Hour),
+		accessKeys:      accessKeys,
+		refreshKeys:     refreshKeys,
+		issuer:          conf.Issuer,
+		audiences:       conf.Audiences,
+		leeway:          conf.Leeway * time.Second,
+		permissions:     conf.Permissions,
+	}
+
+	if handler.issuer == "" {
+		handler.issuer = DefaultIssuer
+	}
+	if len(handler.audiences) == 0 {
+		handler.audiences = []string{handler.issuer}
 	}
 
-	return &handler
+	return &handler, nil
 }
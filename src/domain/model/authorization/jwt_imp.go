@@ -2,6 +2,8 @@ package authorization
 
 import (
 	"fmt"
+	"github.com/Juno-chat-app/user-service/config"
+	"github.com/Juno-chat-app/user-service/domain/entity"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/twinj/uuid"
 	"google.golang.org/grpc/status"
@@ -10,60 +12,75 @@ import (
 	"time"
 )
 
-type (
-	key    string
-	secret string
-)
-
 const (
-	accessSecret  secret = "absdnmqpowiey7219gh214kr3o2p[   :)) 	l]asdkjn21]21n1"
-	refreshSecret secret = "askldnqoiwejqopkpglm;werq/weqlkqwna 		alksndasd asdlakdml;aw'qwqeui21y8192y123m1"
-
-	// keys :: -- key are as follow
-	authorized  string = "authorized"
-	accessUUid  string = "access_uuid"
-	refreshUUid string = "refresh_uuid"
-	usrId       string = "user_id"
-	exp         string = "exp"
-	algorithm   string = "alg"
+	algorithm string = "alg"
 )
 
 type iJwtHandler struct {
 	accessTokenTtl  int64
 	refreshTokenTtl int64
+	accessKeys      *keyRing
+	refreshKeys     *keyRing
+	issuer          string
+	audiences       []string
+	leeway          time.Duration
+	permissions     config.PermissionClaimConfig
 }
 
-func (j *iJwtHandler) CreateAccessToken(userId string) (tokenDetail *TokenDetail, err error) {
+func (j *iJwtHandler) CreateAccessToken(userId string, familyId string, permissions []*entity.Permission) (tokenDetail *TokenDetail, err error) {
+	if familyId == "" {
+		familyId = uuid.NewV4().String()
+	}
+
+	now := time.Now()
 	td := TokenDetail{
 		AccessUUid:      uuid.NewV4().String(),
 		RefreshUUid:     uuid.NewV4().String(),
 		UserId:          userId,
-		ExpireAt:        time.Now().Add(time.Duration(j.accessTokenTtl)).Unix(),
-		RefreshExpireAt: time.Now().Add(time.Duration(j.refreshTokenTtl)).Unix(),
+		FamilyId:        familyId,
+		IssuedAt:        now.Unix(),
+		ExpireAt:        now.Add(time.Duration(j.accessTokenTtl)).Unix(),
+		RefreshExpireAt: now.Add(time.Duration(j.refreshTokenTtl)).Unix(),
 	}
 
 	//========= Create access token with access claims
-	accessClaim := jwt.MapClaims{
-		authorized: true,
-		accessUUid: td.AccessUUid,
-		usrId:      userId,
-		exp:        td.ExpireAt,
-	}
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaim)
-	td.AccessToken, err = accessToken.SignedString([]byte(accessSecret))
+	accessClaim := j.newClaims(accessTokenType, td.AccessUUid, userId, j.audiences, now, td.ExpireAt)
+	accessClaim[familyUUid] = td.FamilyId
+	accessClaim[principal] = UserPrincipal
+	td.PrincipalType = UserPrincipal
+	td.Permissions, td.PermissionsOverflow = j.setPermissions(accessClaim, permissions)
+	td.AccessToken, err = j.accessKeys.sign(accessClaim)
 	if err != nil {
 		return nil, status.Error(http.StatusInternalServerError, err.Error())
 	}
 
-	//========= Create refresh token with refresh claims
-	refreshClaim := jwt.MapClaims{
-		authorized:  true,
-		refreshUUid: td.RefreshUUid,
-		usrId:       userId,
-		exp:         td.RefreshExpireAt,
+	//========= Create refresh token with refresh claims, only the issuer accepts them
+	refreshClaim := j.newClaims(refreshTokenType, td.RefreshUUid, userId, []string{j.issuer}, now, td.RefreshExpireAt)
+	refreshClaim[familyUUid] = td.FamilyId
+	td.RefreshToke, err = j.refreshKeys.sign(refreshClaim)
+	if err != nil {
+		return nil, status.Error(http.StatusInternalServerError, err.Error())
 	}
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaim)
-	td.RefreshToke, err = refreshToken.SignedString([]byte(refreshSecret))
+
+	return &td, nil
+}
+
+func (j *iJwtHandler) CreateClientToken(clientId string, scopes []string) (tokenDetail *TokenDetail, err error) {
+	now := time.Now()
+	td := TokenDetail{
+		AccessUUid:    uuid.NewV4().String(),
+		UserId:        clientId,
+		IssuedAt:      now.Unix(),
+		ExpireAt:      now.Add(time.Duration(j.accessTokenTtl)).Unix(),
+		PrincipalType: ClientPrincipal,
+		Scopes:        scopes,
+	}
+
+	// the scope claim is a space separated list as in RFC 8693
+	accessClaim := j.newClaims(accessTokenType, td.AccessUUid, clientId, j.audiences, now, td.ExpireAt)
+	accessClaim[principal] = ClientPrincipal
+	accessClaim[scope] = strings.Join(scopes, " ")
+	td.AccessToken, err = j.accessKeys.sign(accessClaim)
 	if err != nil {
 		return nil, status.Error(http.StatusInternalServerError, err.Error())
 	}
@@ -72,79 +89,110 @@ func (j *iJwtHandler) CreateAccessToken(userId string) (tokenDetail *TokenDetail
 }
 
 func (j *iJwtHandler) ValidateAccessToken(accessToken string) (tokenDetail *TokenDetail, err error) {
-	if strings.HasPrefix(accessToken, "Bearer ") {
-		accessToken = strings.Split(accessToken, " ")[1]
+	accessToken = strings.TrimPrefix(accessToken, "Bearer ")
+
+	claims, err := j.validate(accessToken, j.accessKeys, accessTokenType, j.audiences)
+	if err != nil {
+		return nil, err
 	}
 
-	token, err := jwt.Parse(accessToken, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, status.Error(http.StatusInternalServerError, fmt.Sprintf("unexpected signing method: %v", token.Header[algorithm]))
-		}
-		return []byte(accessSecret), nil
-	})
+	issuedAt_, _ := numericClaim(claims, issuedAt)
+	expireAt, _ := numericClaim(claims, exp)
+	familyId, _ := claims[familyUUid].(string)
+	perms, overflow := getPermissions(claims)
+	principalType, _ := claims[principal].(string)
+	if principalType != UserPrincipal && principalType != ClientPrincipal {
+		return nil, status.Error(http.StatusUnauthorized, "invalid principal type")
+	}
+	scopes_, _ := claims[scope].(string)
+
+	detail := TokenDetail{
+		AccessToken:         accessToken,
+		AccessUUid:          claims[jwtId].(string),
+		UserId:              claims[subject].(string),
+		FamilyId:            familyId,
+		IssuedAt:            issuedAt_,
+		ExpireAt:            expireAt,
+		Permissions:         perms,
+		PermissionsOverflow: overflow,
+		PrincipalType:       principalType,
+		Scopes:              strings.Fields(scopes_),
+	}
+
+	return &detail, nil
+}
 
+func (j *iJwtHandler) ValidateRefreshToken(refreshToken string) (tokenDetail *TokenDetail, err error) {
+	refreshToken = strings.TrimPrefix(refreshToken, "Bearer ")
+
+	claims, err := j.validate(refreshToken, j.refreshKeys, refreshTokenType, []string{j.issuer})
 	if err != nil {
-		return nil, status.Error(http.StatusBadRequest, err.Error())
+		return nil, err
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); !ok && !token.Valid {
-		return nil, status.Error(http.StatusBadRequest, "invalid claim")
-	} else {
-		accessUuid, ok := claims[accessUUid].(string)
-		if !ok {
-			return nil, status.Error(http.StatusUnauthorized, " invalid claim")
-		}
-
-		userId, ok := claims[usrId].(string)
-		if !ok {
-			return nil, status.Error(http.StatusUnauthorized, " invalid claim")
-		}
+	issuedAt_, _ := numericClaim(claims, issuedAt)
+	expireAt, _ := numericClaim(claims, exp)
+	familyId, _ := claims[familyUUid].(string)
+
+	detail := TokenDetail{
+		RefreshToke:     refreshToken,
+		RefreshUUid:     claims[jwtId].(string),
+		UserId:          claims[subject].(string),
+		FamilyId:        familyId,
+		IssuedAt:        issuedAt_,
+		RefreshExpireAt: expireAt,
+	}
 
-		detail := TokenDetail{
-			AccessToken: accessToken,
-			AccessUUid:  accessUuid,
-			UserId:      userId,
-		}
+	return &detail, nil
+}
 
-		return &detail, nil
+func (j *iJwtHandler) PublicKeys() (keySet *JsonWebKeySet, err error) {
+	keySet, err = j.accessKeys.keySet()
+	if err != nil {
+		return nil, status.Error(http.StatusInternalServerError, err.Error())
 	}
+
+	return keySet, nil
 }
 
-func (j *iJwtHandler) ValidateRefreshToken(refreshToken string) (tokenDetail *TokenDetail, err error) {
-	if strings.HasPrefix(refreshToken, "Bearer ") {
-		refreshToken = strings.Split(refreshToken, " ")[1]
+// validate verifies the signature of the token and its claims
+func (j *iJwtHandler) validate(tokenString string, keys *keyRing, kind tokenType, audiences []string) (jwt.MapClaims, error) {
+	token, err := j.parse(tokenString, keys)
+	if err != nil {
+		return nil, status.Error(http.StatusUnauthorized, err.Error())
 	}
 
-	token, err := jwt.Parse(refreshToken, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, status.Error(http.StatusInternalServerError, fmt.Sprintf("unexpected signing method: %v", token.Header[algorithm]))
-		}
-		return []byte(refreshSecret), nil
-	})
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, status.Error(http.StatusBadRequest, "invalid claim")
+	}
 
+	err = j.validateClaims(claims, kind, audiences)
 	if err != nil {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); !ok && !token.Valid {
-		return nil, status.Error(http.StatusBadRequest, "invalid claim")
-	} else {
-		refreshUuid, ok := claims[refreshUUid].(string)
-		if !ok {
-			return nil, status.Error(http.StatusUnauthorized, " invalid claim")
-		}
+	return claims, nil
+}
 
-		userId, ok := claims[usrId].(string)
-		if !ok {
-			return nil, status.Error(http.StatusUnauthorized, " invalid claim")
-		}
+// parse verifies the token signature with the key selected by its kid header,
+// only the signing method of that key is accepted so a token can not choose
+// how it is verified. The claims are validated by validateClaims with leeway
+func (j *iJwtHandler) parse(tokenString string, keys *keyRing) (*jwt.Token, error) {
+	parser := jwt.Parser{
+		ValidMethods:         keys.algorithms(),
+		SkipClaimsValidation: true,
+	}
 
-		detail := TokenDetail{
-			RefreshToke: refreshToken,
-			RefreshUUid: refreshUuid,
-			UserId:      userId,
+	return parser.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		key, err := keys.lookup(token)
+		if err != nil {
+			return nil, err
 		}
 
-		return &detail, nil
-	}
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, status.Error(http.StatusUnauthorized, fmt.Sprintf("unexpected signing method: %v", token.Header[algorithm]))
+		}
+		return key.publicKey, nil
+	})
 }
@@ -1,21 +1,42 @@
 package authorization
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/Juno-chat-app/user-service/config"
+	"github.com/Juno-chat-app/user-service/domain/entity"
 	logger2 "github.com/Juno-chat-app/user-service/infra/logger"
+	"github.com/dgrijalva/jwt-go"
 	"github.com/stretchr/testify/require"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
 
 var (
+	log     logger2.ILogger
 	handler IJwtHandler
 	token   *TokenDetail
 )
 
 func TestMain(m *testing.M) {
-	logger, _ := logger2.NewLogger()
-	handler = NewJwtHandler(int64(time.Second), int64(time.Minute), logger)
+	log, _ = logger2.NewLogger()
+
+	conf, err := config.LoadConfiguration("../../../user-service_test.yml")
+	if err != nil {
+		os.Exit(1)
+	}
+
+	handler, err = NewJwtHandler(conf.AuthConfig, log)
+	if err != nil {
+		os.Exit(1)
+	}
 
 	code := m.Run()
 	os.Exit(code)
@@ -24,7 +45,7 @@ func TestMain(m *testing.M) {
 func TestIJwtHandler(t *testing.T) {
 	var err error
 
-	token, err = handler.CreateAccessToken("123455")
+	token, err = handler.CreateAccessToken("123455", "", nil)
 	require.Nil(t, err)
 
 	token1, err := handler.ValidateAccessToken(token.AccessToken)
@@ -36,4 +57,259 @@ func TestIJwtHandler(t *testing.T) {
 	require.Nil(t, err)
 	require.Equal(t, token.UserId, token2.UserId)
 	require.Equal(t, token.RefreshUUid, token2.RefreshUUid)
+	require.Equal(t, token.FamilyId, token2.FamilyId)
+	require.Equal(t, token.RefreshExpireAt, token2.RefreshExpireAt)
+
+	rotated, err := handler.CreateAccessToken(token.UserId, token.FamilyId, nil)
+	require.Nil(t, err)
+	require.Equal(t, token.FamilyId, rotated.FamilyId)
+	require.NotEqual(t, token.RefreshUUid, rotated.RefreshUUid)
+
+	_, err = handler.ValidateAccessToken(token.RefreshToke)
+	require.NotNil(t, err)
+}
+
+func TestIJwtHandler_Algorithms(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jwt-keys")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+
+	keys := map[string]interface{}{
+		ES256: ecKey,
+		EdDSA: edKey,
+	}
+
+	for algorithm, key := range keys {
+		conf := config.AuthConfig{
+			AccessTTL:  1,
+			RefreshTTL: 1,
+			Algorithm:  algorithm,
+			AccessKey:  config.KeyConfig{PrivateKey: writePrivateKey(t, dir, algorithm, key)},
+			RefreshKey: config.KeyConfig{PrivateKey: writePrivateKey(t, dir, algorithm, key)},
+		}
+
+		h, err := NewJwtHandler(conf, log)
+		require.Nil(t, err)
+
+		td, err := h.CreateAccessToken("123455", "", nil)
+		require.Nil(t, err)
+
+		detail, err := h.ValidateAccessToken(td.AccessToken)
+		require.Nil(t, err)
+		require.Equal(t, "123455", detail.UserId)
+
+		// tokens of another algorithm must be refused
+		_, err = handler.ValidateAccessToken(td.AccessToken)
+		require.NotNil(t, err)
+	}
+}
+
+func TestIJwtHandler_Rotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jwt-keys")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	oldKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	_, newKey, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+
+	oldConf := config.AuthConfig{
+		AccessTTL:  1,
+		RefreshTTL: 1,
+		Algorithm:  ES256,
+		AccessKey:  config.KeyConfig{Id: "old", PrivateKey: writePrivateKey(t, dir, "old", oldKey)},
+		RefreshKey: config.KeyConfig{PrivateKey: writePrivateKey(t, dir, "refresh", oldKey)},
+	}
+	oldHandler, err := NewJwtHandler(oldConf, log)
+	require.Nil(t, err)
+
+	oldToken, err := oldHandler.CreateAccessToken("123455", "", nil)
+	require.Nil(t, err)
+
+	// rotate, the old key is only kept for verification
+	newConf := oldConf
+	newConf.AccessKey = config.KeyConfig{Id: "new", Algorithm: EdDSA, PrivateKey: writePrivateKey(t, dir, "new", newKey)}
+	newConf.RetiredAccessKeys = []config.KeyConfig{{Id: "old", PublicKey: writePublicKey(t, dir, "old", &oldKey.PublicKey)}}
+	newHandler, err := NewJwtHandler(newConf, log)
+	require.Nil(t, err)
+
+	detail, err := newHandler.ValidateAccessToken(oldToken.AccessToken)
+	require.Nil(t, err)
+	require.Equal(t, oldToken.AccessUUid, detail.AccessUUid)
+
+	newToken, err := newHandler.CreateAccessToken("123455", "", nil)
+	require.Nil(t, err)
+	_, err = oldHandler.ValidateAccessToken(newToken.AccessToken)
+	require.NotNil(t, err)
+
+	keySet, err := newHandler.PublicKeys()
+	require.Nil(t, err)
+	require.Len(t, keySet.Keys, 2)
+	require.Equal(t, "new", keySet.Keys[0].Kid)
+	require.Equal(t, "OKP", keySet.Keys[0].Kty)
+	require.Equal(t, "old", keySet.Keys[1].Kid)
+	require.Equal(t, ES256, keySet.Keys[1].Alg)
+}
+
+func TestIJwtHandler_RejectHMAC(t *testing.T) {
+	data, err := ioutil.ReadFile("../../../keys/test/access.pub.pem")
+	require.Nil(t, err)
+
+	// the classic algorithm confusion, signing with the public key as hmac secret
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		jwtId:   "uuid",
+		subject: "123455",
+		typ:     string(accessTokenType),
+	})
+	forgedToken, err := forged.SignedString(data)
+	require.Nil(t, err)
+
+	_, err = handler.ValidateAccessToken(forgedToken)
+	require.NotNil(t, err)
+}
+
+func TestIJwtHandler_Claims(t *testing.T) {
+	conf, err := config.LoadConfiguration("../../../user-service_test.yml")
+	require.Nil(t, err)
+
+	// the same key for both kinds, only the token type claim tells them apart
+	conf.AuthConfig.RefreshKey = conf.AuthConfig.AccessKey
+	conf.AuthConfig.Issuer = "juno-test"
+	conf.AuthConfig.Audiences = []string{"gateway", "chat"}
+	conf.AuthConfig.Leeway = 30
+	h, err := NewJwtHandler(conf.AuthConfig, log)
+	require.Nil(t, err)
+
+	td, err := h.CreateAccessToken("123455", "", nil)
+	require.Nil(t, err)
+
+	_, err = h.ValidateAccessToken(td.RefreshToke)
+	require.NotNil(t, err)
+	_, err = h.ValidateRefreshToken(td.AccessToken)
+	require.NotNil(t, err)
+
+	other := conf.AuthConfig
+	other.Audiences = []string{"media"}
+	otherHandler, err := NewJwtHandler(other, log)
+	require.Nil(t, err)
+	_, err = otherHandler.ValidateAccessToken(td.AccessToken)
+	require.NotNil(t, err)
+
+	keys := h.(*iJwtHandler).accessKeys
+	now := time.Now()
+	claims := h.(*iJwtHandler).newClaims(accessTokenType, "uuid", "123455", []string{"chat"}, now.Add(-time.Hour), now.Add(-10*time.Second).Unix())
+
+	// access tokens name their principal
+	tokenString, err := keys.sign(claims)
+	require.Nil(t, err)
+	_, err = h.ValidateAccessToken(tokenString)
+	require.NotNil(t, err)
+	claims[principal] = UserPrincipal
+
+	// expired, but still within the leeway
+	tokenString, err = keys.sign(claims)
+	require.Nil(t, err)
+	detail, err := h.ValidateAccessToken(tokenString)
+	require.Nil(t, err)
+	require.Equal(t, "uuid", detail.AccessUUid)
+
+	claims[exp] = now.Add(-time.Minute).Unix()
+	tokenString, err = keys.sign(claims)
+	require.Nil(t, err)
+	_, err = h.ValidateAccessToken(tokenString)
+	require.NotNil(t, err)
+
+	claims[exp] = now.Add(time.Hour).Unix()
+	claims[notBefore] = now.Add(time.Minute).Unix()
+	tokenString, err = keys.sign(claims)
+	require.Nil(t, err)
+	_, err = h.ValidateAccessToken(tokenString)
+	require.NotNil(t, err)
+
+	claims[notBefore] = now.Unix()
+	claims[issuer] = "someone-else"
+	tokenString, err = keys.sign(claims)
+	require.Nil(t, err)
+	_, err = h.ValidateAccessToken(tokenString)
+	require.NotNil(t, err)
+}
+
+func writePrivateKey(t *testing.T, dir string, algorithm string, key interface{}) string {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.Nil(t, err)
+
+	path := filepath.Join(dir, algorithm+".pem")
+	err = ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+	require.Nil(t, err)
+
+	return path
+}
+
+func writePublicKey(t *testing.T, dir string, name string, key interface{}) string {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	require.Nil(t, err)
+
+	path := filepath.Join(dir, name+".pub.pem")
+	err = ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600)
+	require.Nil(t, err)
+
+	return path
+}
+
+func TestIJwtHandler_Permissions(t *testing.T) {
+	conf, err := config.LoadConfiguration("../../../user-service_test.yml")
+	require.Nil(t, err)
+
+	perms := []*entity.Permission{
+		{Key: "role", Value: "admin"},
+		{Key: "chat", Value: "group:create"},
+	}
+
+	for _, compact := range []bool{false, true} {
+		conf.AuthConfig.Permissions = config.PermissionClaimConfig{Embed: true, MaxCount: 2, Compact: compact}
+		h, err := NewJwtHandler(conf.AuthConfig, log)
+		require.Nil(t, err)
+
+		td, err := h.CreateAccessToken("123455", "", perms)
+		require.Nil(t, err)
+
+		detail, err := h.ValidateAccessToken(td.AccessToken)
+		require.Nil(t, err)
+		require.Equal(t, perms, detail.Permissions)
+		require.False(t, detail.PermissionsOverflow)
+
+		td, err = h.CreateAccessToken("123455", "", append(perms, &entity.Permission{Key: "media", Value: "upload"}))
+		require.Nil(t, err)
+
+		detail, err = h.ValidateAccessToken(td.AccessToken)
+		require.Nil(t, err)
+		require.Empty(t, detail.Permissions)
+		require.True(t, detail.PermissionsOverflow)
+	}
+}
+
+func TestIJwtHandler_ClientToken(t *testing.T) {
+	td, err := handler.CreateClientToken("message-service", []string{"media:read", "user:read"})
+	require.Nil(t, err)
+	require.Empty(t, td.RefreshToke)
+
+	detail, err := handler.ValidateAccessToken(td.AccessToken)
+	require.Nil(t, err)
+	require.Equal(t, "message-service", detail.UserId)
+	require.Equal(t, ClientPrincipal, detail.PrincipalType)
+	require.Equal(t, []string{"media:read", "user:read"}, detail.Scopes)
+
+	user, err := handler.CreateAccessToken("123455", "", nil)
+	require.Nil(t, err)
+
+	detail, err = handler.ValidateAccessToken(user.AccessToken)
+	require.Nil(t, err)
+	require.Equal(t, UserPrincipal, detail.PrincipalType)
+	require.Empty(t, detail.Scopes)
 }
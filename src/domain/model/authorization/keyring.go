@@ -0,0 +1,88 @@
+package authorization
+
+import (
+	"fmt"
+	"github.com/Juno-chat-app/user-service/config"
+	"github.com/dgrijalva/jwt-go"
+	"google.golang.org/grpc/status"
+	"net/http"
+)
+
+const (
+	keyId string = "kid"
+)
+
+// keyRing holds the key used for signing new tokens and the retired keys
+// which are still accepted for verification until their tokens expire
+type keyRing struct {
+	active *signingKey
+	keys   map[string]*signingKey
+}
+
+func newKeyRing(algorithm string, active config.KeyConfig, retired []config.KeyConfig) (*keyRing, error) {
+	activeKey, err := loadSigningKey(algorithm, active)
+	if err != nil {
+		return nil, err
+	}
+
+	if activeKey.privateKey == nil {
+		return nil, fmt.Errorf("private key is required for the active key %s", activeKey.id)
+	}
+
+	ring := keyRing{
+		active: activeKey,
+		keys: map[string]*signingKey{
+			activeKey.id: activeKey,
+		},
+	}
+
+	for _, conf := range retired {
+		key, err := loadSigningKey(algorithm, conf)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := ring.keys[key.id]; ok {
+			return nil, fmt.Errorf("duplicated key id %s", key.id)
+		}
+		ring.keys[key.id] = key
+	}
+
+	return &ring, nil
+}
+
+// sign stamps the kid header of the active key and signs the claims with it
+func (r *keyRing) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(r.active.method, claims)
+	token.Header[keyId] = r.active.id
+
+	return token.SignedString(r.active.privateKey)
+}
+
+// lookup selects the verification key by the kid header of the token
+func (r *keyRing) lookup(token *jwt.Token) (*signingKey, error) {
+	id, ok := token.Header[keyId].(string)
+	if !ok {
+		return nil, status.Error(http.StatusUnauthorized, "invalid key id")
+	}
+
+	key, ok := r.keys[id]
+	if !ok {
+		return nil, status.Error(http.StatusUnauthorized, fmt.Sprintf("unknown key id: %s", id))
+	}
+
+	return key, nil
+}
+
+func (r *keyRing) algorithms() []string {
+	algorithms := make([]string, 0, len(r.keys))
+	seen := make(map[string]bool)
+	for _, key := range r.keys {
+		if !seen[key.method.Alg()] {
+			seen[key.method.Alg()] = true
+			algorithms = append(algorithms, key.method.Alg())
+		}
+	}
+
+	return algorithms
+}
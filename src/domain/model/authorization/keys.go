@@ -0,0 +1,166 @@
+package authorization
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/Juno-chat-app/user-service/config"
+	"github.com/dgrijalva/jwt-go"
+	"io/ioutil"
+)
+
+const (
+	RS256 string = "RS256"
+	ES256 string = "ES256"
+	EdDSA string = "EdDSA"
+)
+
+// signingKey is an asymmetric key pair bound to the jwt signing method it is used with
+type signingKey struct {
+	id         string
+	method     jwt.SigningMethod
+	privateKey crypto.Signer
+	publicKey  crypto.PublicKey
+}
+
+func signingMethod(algorithm string) (jwt.SigningMethod, error) {
+	switch algorithm {
+	case RS256:
+		return jwt.SigningMethodRS256, nil
+	case ES256:
+		return jwt.SigningMethodES256, nil
+	case EdDSA:
+		return SigningMethodEdDSA, nil
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+}
+
+// loadSigningKey reads the PEM files of conf, when the public key is not
+// configured it is derived from the private key
+func loadSigningKey(algorithm string, conf config.KeyConfig) (*signingKey, error) {
+	if conf.Algorithm != "" {
+		algorithm = conf.Algorithm
+	}
+
+	method, err := signingMethod(algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	key := signingKey{
+		method: method,
+	}
+
+	if conf.PrivateKey != "" {
+		data, err := ioutil.ReadFile(conf.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+
+		key.privateKey, err = parsePrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", conf.PrivateKey, err)
+		}
+		key.publicKey = key.privateKey.Public()
+	}
+
+	if conf.PublicKey != "" {
+		data, err := ioutil.ReadFile(conf.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+
+		key.publicKey, err = parsePublicKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", conf.PublicKey, err)
+		}
+	}
+
+	if key.publicKey == nil {
+		return nil, errors.New("neither private nor public key is configured")
+	}
+
+	if !matchesAlgorithm(algorithm, key.publicKey) {
+		return nil, fmt.Errorf("key type %T can not be used with %s", key.publicKey, algorithm)
+	}
+
+	key.id = conf.Id
+	if key.id == "" {
+		key.id, err = thumbprint(key.publicKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &key, nil
+}
+
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, jwt.ErrKeyMustBePEMEncoded
+	}
+
+	var (
+		key interface{}
+		err error
+	)
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+
+	return signer, nil
+}
+
+func parsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, jwt.ErrKeyMustBePEMEncoded
+	}
+
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	default:
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	}
+}
+
+func matchesAlgorithm(algorithm string, key crypto.PublicKey) bool {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return algorithm == RS256
+	case *ecdsa.PublicKey:
+		return algorithm == ES256 && k.Curve == elliptic.P256()
+	case ed25519.PublicKey:
+		return algorithm == EdDSA
+	default:
+		return false
+	}
+}
@@ -0,0 +1,83 @@
+package authorization
+
+import (
+	"github.com/Juno-chat-app/user-service/domain/entity"
+	"strings"
+)
+
+const (
+	permissions         string = "permissions"
+	compactPermissions  string = "perms"
+	permissionsOverflow string = "perms_overflow"
+
+	compactSeparator string = ":"
+)
+
+// setPermissions embeds the permissions into the access claims, when there are
+// more than the configured cap only the overflow flag is set and verifiers have
+// to ask the user-service. It returns what the token carries
+func (j *iJwtHandler) setPermissions(claims map[string]interface{}, perms []*entity.Permission) (embedded []*entity.Permission, overflow bool) {
+	if !j.permissions.Embed || len(perms) == 0 {
+		return nil, false
+	}
+
+	if j.permissions.MaxCount > 0 && len(perms) > j.permissions.MaxCount {
+		claims[permissionsOverflow] = true
+		return nil, true
+	}
+
+	if j.permissions.Compact {
+		values := make([]string, 0, len(perms))
+		for _, p := range perms {
+			values = append(values, p.Key+compactSeparator+p.Value)
+		}
+		claims[compactPermissions] = values
+		return perms, false
+	}
+
+	values := make([]map[string]string, 0, len(perms))
+	for _, p := range perms {
+		values = append(values, map[string]string{
+			"key":   p.Key,
+			"value": p.Value,
+		})
+	}
+	claims[permissions] = values
+	return perms, false
+}
+
+// getPermissions reads the permissions of either encoding from the claims
+func getPermissions(claims map[string]interface{}) (perms []*entity.Permission, overflow bool) {
+	overflow, _ = claims[permissionsOverflow].(bool)
+
+	if values, ok := claims[compactPermissions].([]interface{}); ok {
+		for _, v := range values {
+			value, ok := v.(string)
+			if !ok {
+				continue
+			}
+
+			parts := strings.SplitN(value, compactSeparator, 2)
+			p := entity.Permission{Key: parts[0]}
+			if len(parts) == 2 {
+				p.Value = parts[1]
+			}
+			perms = append(perms, &p)
+		}
+	}
+
+	if values, ok := claims[permissions].([]interface{}); ok {
+		for _, v := range values {
+			value, ok := v.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			key, _ := value["key"].(string)
+			val, _ := value["value"].(string)
+			perms = append(perms, &entity.Permission{Key: key, Value: val})
+		}
+	}
+
+	return perms, overflow
+}
@@ -0,0 +1,90 @@
+package mfa
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"fmt"
+	"github.com/Juno-chat-app/user-service/config"
+	"github.com/Juno-chat-app/user-service/infra/logger"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// The parameters every authenticator app supports, RFC 6238 defaults
+const (
+	Digits       int           = 6
+	Period       time.Duration = 30 * time.Second
+	SecretLength int           = 20 // bytes, the size of a SHA-1 block key
+
+	DefaultIssuer string = "Juno"
+	DefaultSkew   int    = 1
+)
+
+type ITotpHandler interface {
+	// NewSecret generates a base32 secret and the otpauth uri authenticator apps
+	// enroll it with, accountName is shown next to the issuer in the app
+	NewSecret(accountName string) (secret string, uri string, err error)
+	// Validate checks code against secret at now, allowing the configured skew of
+	// time steps. Only steps after lastCounter are accepted so a code works once,
+	// the matched step is returned to be stored as the next lastCounter
+	Validate(secret string, code string, now time.Time, lastCounter int64) (counter int64, ok bool)
+	// Encrypt seals secret with AES-GCM for storage, userId is authenticated with it
+	// so a secret copied to another user does not decrypt
+	Encrypt(secret string, userId string) (encrypted string, err error)
+	Decrypt(encrypted string, userId string) (secret string, err error)
+}
+
+// NewTotpHandler creates the handler of conf, the encryption key file holds 32
+// base64 encoded bytes
+func NewTotpHandler(conf config.MfaConfig, logger logger.ILogger) (ITotpHandler, error) {
+	logger.Info("initial totp-handler",
+		"method", "NewTotpHandler",
+		"issuer", conf.Issuer,
+		"skew", conf.Skew,
+		"encryption-key", conf.EncryptionKey)
+
+	handler := iTotpHandler{
+		issuer: conf.Issuer,
+		skew:   conf.Skew,
+		logger: logger,
+	}
+
+	if handler.issuer == "" {
+		handler.issuer = DefaultIssuer
+	}
+
+	if handler.skew == 0 {
+		handler.skew = DefaultSkew
+	}
+
+	if conf.EncryptionKey == "" {
+		return nil, fmt.Errorf("no mfa encryption key configured")
+	}
+
+	encoded, err := ioutil.ReadFile(conf.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil {
+		return nil, fmt.Errorf("mfa encryption key is not base64: %v", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("mfa encryption key must have 32 bytes, got %d", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	handler.aead, err = cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &handler, nil
+}
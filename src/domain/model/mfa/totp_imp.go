@@ -0,0 +1,125 @@
+package mfa
+
+import (
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/Juno-chat-app/user-service/infra/logger"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSecret = errors.New("invalid totp secret")
+
+var secretEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type iTotpHandler struct {
+	issuer string
+	skew   int
+	aead   cipher.AEAD
+	logger logger.ILogger
+}
+
+func (h *iTotpHandler) NewSecret(accountName string) (secret string, uri string, err error) {
+	buf := make([]byte, SecretLength)
+	_, err = rand.Read(buf)
+	if err != nil {
+		return "", "", err
+	}
+	secret = secretEncoding.EncodeToString(buf)
+
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", h.issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(Digits))
+	query.Set("period", fmt.Sprint(int(Period/time.Second)))
+
+	uri = fmt.Sprintf("otpauth://totp/%s:%s?%s", url.PathEscape(h.issuer), url.PathEscape(accountName), query.Encode())
+	return secret, uri, nil
+}
+
+func (h *iTotpHandler) Validate(secret string, code string, now time.Time, lastCounter int64) (counter int64, ok bool) {
+	key, err := secretEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != Digits {
+		return 0, false
+	}
+
+	current := now.Unix() / int64(Period/time.Second)
+	for step := -h.skew; step <= h.skew; step++ {
+		counter = current + int64(step)
+		if counter <= lastCounter {
+			continue
+		}
+
+		if subtle.ConstantTimeCompare([]byte(hotp(key, counter)), []byte(code)) == 1 {
+			return counter, true
+		}
+	}
+
+	return 0, false
+}
+
+func (h *iTotpHandler) Encrypt(secret string, userId string) (encrypted string, err error) {
+	nonce := make([]byte, h.aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+
+	sealed := h.aead.Seal(nonce, nonce, []byte(secret), []byte(userId))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (h *iTotpHandler) Decrypt(encrypted string, userId string) (secret string, err error) {
+	sealed, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil || len(sealed) < h.aead.NonceSize() {
+		return "", ErrInvalidSecret
+	}
+
+	nonce := sealed[:h.aead.NonceSize()]
+	plain, err := h.aead.Open(nil, nonce, sealed[h.aead.NonceSize():], []byte(userId))
+	if err != nil {
+		return "", ErrInvalidSecret
+	}
+
+	return string(plain), nil
+}
+
+// GenerateCode returns the code of secret at now, what an authenticator app shows
+func GenerateCode(secret string, now time.Time) (code string, err error) {
+	key, err := secretEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", ErrInvalidSecret
+	}
+
+	return hotp(key, now.Unix()/int64(Period/time.Second)), nil
+}
+
+// hotp is the HMAC-SHA1 one-time password of counter, RFC 4226
+func hotp(key []byte, counter int64) string {
+	message := make([]byte, 8)
+	binary.BigEndian.PutUint64(message, uint64(counter))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(message)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulo := uint32(1)
+	for i := 0; i < Digits; i++ {
+		modulo *= 10
+	}
+
+	return fmt.Sprintf("%0*d", Digits, value%modulo)
+}
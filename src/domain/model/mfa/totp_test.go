@@ -0,0 +1,98 @@
+package mfa
+
+import (
+	"github.com/Juno-chat-app/user-service/config"
+	logger2 "github.com/Juno-chat-app/user-service/infra/logger"
+	"github.com/stretchr/testify/require"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+)
+
+var handler ITotpHandler
+
+func TestMain(m *testing.M) {
+	log, _ := logger2.NewLogger()
+
+	conf, err := config.LoadConfiguration("../../../user-service_test.yml")
+	if err != nil {
+		os.Exit(1)
+	}
+
+	handler, err = NewTotpHandler(conf.MfaConfig, log)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	os.Exit(code)
+}
+
+// the SHA-1 test vectors of RFC 6238, truncated to 6 digits
+func TestGenerateCode_RFC6238(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" // "12345678901234567890"
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+
+	for unix, expected := range vectors {
+		code, err := GenerateCode(secret, time.Unix(unix, 0))
+		require.Nil(t, err)
+		require.Equal(t, expected, code)
+	}
+}
+
+func TestITotpHandler_Validate(t *testing.T) {
+	secret, uri, err := handler.NewSecret("alice")
+	require.Nil(t, err)
+	require.Len(t, secret, 32)
+
+	parsed, err := url.Parse(uri)
+	require.Nil(t, err)
+	require.Equal(t, "otpauth", parsed.Scheme)
+	require.Equal(t, "totp", parsed.Host)
+	require.Equal(t, "/Juno:alice", parsed.Path)
+	require.Equal(t, secret, parsed.Query().Get("secret"))
+	require.Equal(t, "Juno", parsed.Query().Get("issuer"))
+
+	now := time.Now()
+	code, err := GenerateCode(secret, now)
+	require.Nil(t, err)
+
+	counter, ok := handler.Validate(secret, code, now, 0)
+	require.True(t, ok)
+
+	// a code is accepted once
+	_, ok = handler.Validate(secret, code, now, counter)
+	require.False(t, ok)
+
+	// codes of the neighbour steps are accepted, older ones are not
+	next, err := GenerateCode(secret, now.Add(Period))
+	require.Nil(t, err)
+	_, ok = handler.Validate(secret, next, now, counter)
+	require.True(t, ok)
+
+	old, err := GenerateCode(secret, now.Add(-2*Period))
+	require.Nil(t, err)
+	_, ok = handler.Validate(secret, old, now, 0)
+	require.False(t, ok)
+}
+
+func TestITotpHandler_Encrypt(t *testing.T) {
+	encrypted, err := handler.Encrypt("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", "user-1")
+	require.Nil(t, err)
+	require.NotContains(t, encrypted, "GEZDGNBV")
+
+	secret, err := handler.Decrypt(encrypted, "user-1")
+	require.Nil(t, err)
+	require.Equal(t, "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", secret)
+
+	// the secret is bound to its user
+	_, err = handler.Decrypt(encrypted, "user-2")
+	require.Equal(t, ErrInvalidSecret, err)
+}
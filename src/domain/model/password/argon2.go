@@ -0,0 +1,85 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"strings"
+)
+
+const (
+	argon2Prefix     string = "$argon2id$"
+	argon2SaltLength uint32 = 16
+	argon2KeyLength  uint32 = 32
+)
+
+type argon2Params struct {
+	memory      uint32 // KiB
+	time        uint32
+	parallelism uint8
+	saltLength  uint32
+	keyLength   uint32
+}
+
+// hash encodes password in the PHC string format
+//
+//	$argon2id$v=19$m=<memory>,t=<time>,p=<parallelism>$<salt>$<key>
+func (p argon2Params) hash(password string) (string, error) {
+	salt := make([]byte, p.saltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, p.time, p.memory, p.parallelism, p.keyLength)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2Prefix, argon2.Version, p.memory, p.time, p.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func verifyArgon2(password string, hash string) (bool, error) {
+	params, salt, key, err := decodeArgon2(hash)
+	if err != nil {
+		return false, err
+	}
+
+	other := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.parallelism, params.keyLength)
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+func decodeArgon2(hash string) (params argon2Params, salt []byte, key []byte, err error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || "$"+parts[1]+"$" != argon2Prefix {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	var version int
+	_, err = fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+	if version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2 version %d", version)
+	}
+
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.parallelism)
+	if err != nil {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	key, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	params.saltLength = uint32(len(salt))
+	params.keyLength = uint32(len(key))
+	return params, salt, key, nil
+}
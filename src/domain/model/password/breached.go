@@ -0,0 +1,66 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const hashPrefixLength int = 5
+
+// breachedList looks passwords up in a directory of range files, every file
+// holds the "SUFFIX:COUNT" lines of the SHA-1 hashes starting with its name.
+// Only the file of the prefix is read, so the list can be far larger than memory
+type breachedList struct {
+	dir string
+}
+
+func (b *breachedList) contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:hashPrefixLength], hash[hashPrefixLength:]
+
+	file, err := b.open(prefix)
+	if err != nil {
+		return false, err
+	}
+	if file == nil {
+		return false, nil
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		colon := strings.IndexByte(line, ':')
+		if colon < 0 {
+			colon = len(line)
+		}
+
+		// padded entries of the range api have a count of zero
+		if strings.EqualFold(line[:colon], suffix) && !strings.HasSuffix(line, ":0") {
+			return true, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
+
+// open opens the range file of prefix, lists which only cover part of the
+// ranges have no file for the others
+func (b *breachedList) open(prefix string) (*os.File, error) {
+	for _, name := range []string{prefix, prefix + ".txt", strings.ToLower(prefix), strings.ToLower(prefix) + ".txt"} {
+		file, err := os.Open(filepath.Join(b.dir, name))
+		if err == nil {
+			return file, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
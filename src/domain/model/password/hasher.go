@@ -0,0 +1,83 @@
+package password
+
+import (
+	"fmt"
+	"github.com/Juno-chat-app/user-service/config"
+	"github.com/Juno-chat-app/user-service/infra/logger"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	Bcrypt   string = "bcrypt"
+	Argon2id string = "argon2id"
+
+	DefaultBcryptCost        int    = 12
+	DefaultArgon2Memory      uint32 = 64 * 1024 // KiB
+	DefaultArgon2Time        uint32 = 3
+	DefaultArgon2Parallelism uint8  = 2
+)
+
+type IPasswordHasher interface {
+	// Hash encodes password with the current algorithm and parameters, bcrypt in
+	// its own format and argon2id in the PHC string format
+	Hash(password string) (hash string, err error)
+	// Verify checks password against a hash of any of the supported algorithms,
+	// a mismatch is not an error
+	Verify(password string, hash string) (ok bool, err error)
+	// NeedsRehash reports hashes made with another algorithm or parameters than the current ones
+	NeedsRehash(hash string) bool
+}
+
+// NewPasswordHasher creates the hasher of conf, parameters with a zero value fall back to their defaults
+func NewPasswordHasher(conf config.HashConfig, logger logger.ILogger) (IPasswordHasher, error) {
+	hasher := iPasswordHasher{
+		algorithm:  conf.Algorithm,
+		bcryptCost: conf.BcryptCost,
+		argon2: argon2Params{
+			memory:      conf.Argon2Memory,
+			time:        conf.Argon2Time,
+			parallelism: conf.Argon2Parallelism,
+			saltLength:  argon2SaltLength,
+			keyLength:   argon2KeyLength,
+		},
+		logger: logger,
+	}
+
+	if hasher.algorithm == "" {
+		hasher.algorithm = Argon2id
+	}
+
+	if hasher.algorithm != Bcrypt && hasher.algorithm != Argon2id {
+		return nil, fmt.Errorf("unsupported password hash algorithm %s", hasher.algorithm)
+	}
+
+	if hasher.bcryptCost == 0 {
+		hasher.bcryptCost = DefaultBcryptCost
+	}
+
+	if hasher.bcryptCost < bcrypt.MinCost || hasher.bcryptCost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("bcrypt cost %d out of range %d-%d", hasher.bcryptCost, bcrypt.MinCost, bcrypt.MaxCost)
+	}
+
+	if hasher.argon2.memory == 0 {
+		hasher.argon2.memory = DefaultArgon2Memory
+	}
+
+	if hasher.argon2.time == 0 {
+		hasher.argon2.time = DefaultArgon2Time
+	}
+
+	if hasher.argon2.parallelism == 0 {
+		hasher.argon2.parallelism = DefaultArgon2Parallelism
+	}
+
+	logger.Info("create password hasher",
+		"method", "NewPasswordHasher",
+		"algorithm", hasher.algorithm,
+		"bcrypt-cost", hasher.bcryptCost,
+		"argon2-memory", hasher.argon2.memory,
+		"argon2-time", hasher.argon2.time,
+		"argon2-parallelism", hasher.argon2.parallelism)
+
+	return &hasher, nil
+}
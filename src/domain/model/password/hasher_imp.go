@@ -0,0 +1,59 @@
+package password
+
+import (
+	"errors"
+	"github.com/Juno-chat-app/user-service/infra/logger"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+var ErrUnknownHashFormat = errors.New("unknown password hash format")
+
+type iPasswordHasher struct {
+	algorithm  string
+	bcryptCost int
+	argon2     argon2Params
+	logger     logger.ILogger
+}
+
+func (h *iPasswordHasher) Hash(password string) (hash string, err error) {
+	if h.algorithm == Bcrypt {
+		bytes, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+		return string(bytes), err
+	}
+
+	return h.argon2.hash(password)
+}
+
+func (h *iPasswordHasher) Verify(password string, hash string) (ok bool, err error) {
+	switch {
+	case isBcrypt(hash):
+		err = bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return false, nil
+		}
+		return err == nil, err
+	case strings.HasPrefix(hash, argon2Prefix):
+		return verifyArgon2(password, hash)
+	default:
+		return false, ErrUnknownHashFormat
+	}
+}
+
+func (h *iPasswordHasher) NeedsRehash(hash string) bool {
+	if h.algorithm == Bcrypt {
+		if !isBcrypt(hash) {
+			return true
+		}
+
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || cost != h.bcryptCost
+	}
+
+	params, _, _, err := decodeArgon2(hash)
+	return err != nil || params != h.argon2
+}
+
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
@@ -0,0 +1,74 @@
+package password
+
+import (
+	"github.com/Juno-chat-app/user-service/config"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestPasswordHasher_Argon2id(t *testing.T) {
+	hasher, err := NewPasswordHasher(config.HashConfig{
+		Algorithm:         Argon2id,
+		Argon2Memory:      1024,
+		Argon2Time:        1,
+		Argon2Parallelism: 1,
+	}, log)
+	require.Nil(t, err)
+
+	hash, err := hasher.Hash("secret")
+	require.Nil(t, err)
+	require.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$"))
+
+	ok, err := hasher.Verify("secret", hash)
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	ok, err = hasher.Verify("wrong", hash)
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	require.False(t, hasher.NeedsRehash(hash))
+
+	// passwords longer than the 72 bytes bcrypt reads are told apart
+	long := strings.Repeat("a", 80)
+	hash, err = hasher.Hash(long + "1")
+	require.Nil(t, err)
+	ok, err = hasher.Verify(long+"2", hash)
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	_, err = hasher.Verify("secret", "$argon2id$v=19$m=1024$broken")
+	require.Equal(t, ErrUnknownHashFormat, err)
+}
+
+func TestPasswordHasher_NeedsRehash(t *testing.T) {
+	bcryptHasher, err := NewPasswordHasher(config.HashConfig{Algorithm: Bcrypt, BcryptCost: 4}, log)
+	require.Nil(t, err)
+	argon2Hasher, err := NewPasswordHasher(config.HashConfig{Algorithm: Argon2id, Argon2Memory: 1024, Argon2Time: 1}, log)
+	require.Nil(t, err)
+	strongerHasher, err := NewPasswordHasher(config.HashConfig{Algorithm: Argon2id, Argon2Memory: 1024, Argon2Time: 2}, log)
+	require.Nil(t, err)
+
+	bcryptHash, err := bcryptHasher.Hash("secret")
+	require.Nil(t, err)
+	require.False(t, bcryptHasher.NeedsRehash(bcryptHash))
+	require.True(t, argon2Hasher.NeedsRehash(bcryptHash))
+
+	// every hasher verifies the hashes of the others
+	ok, err := argon2Hasher.Verify("secret", bcryptHash)
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	argon2Hash, err := argon2Hasher.Hash("secret")
+	require.Nil(t, err)
+	require.True(t, bcryptHasher.NeedsRehash(argon2Hash))
+	require.True(t, strongerHasher.NeedsRehash(argon2Hash))
+
+	ok, err = bcryptHasher.Verify("secret", argon2Hash)
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	_, err = NewPasswordHasher(config.HashConfig{Algorithm: "md5"}, log)
+	require.NotNil(t, err)
+}
@@ -0,0 +1,73 @@
+package password
+
+import (
+	"fmt"
+	"github.com/Juno-chat-app/user-service/config"
+	"github.com/Juno-chat-app/user-service/domain/entity"
+	"github.com/Juno-chat-app/user-service/infra/logger"
+	"os"
+)
+
+const (
+	DefaultMinLength int = 1
+	DefaultMaxLength int = 128
+
+	// PasswordField is the field the violations of the policy refer to
+	PasswordField string = "password"
+)
+
+type IPasswordPolicy interface {
+	// Check returns a bad request error listing every rule password breaks as a
+	// field violation, user is the owner of the password
+	Check(password string, user *entity.User) (err error)
+}
+
+// NewPasswordPolicy creates the policy of conf, rules with a zero value are off
+// except the length limits which fall back to their defaults. The breached
+// password list is a directory of SHA-1 range files in the format of the
+// haveibeenpwned range api, named after the 5 character prefix of the hashes
+func NewPasswordPolicy(conf config.PasswordConfig, logger logger.ILogger) (IPasswordPolicy, error) {
+	logger.Info("create password policy",
+		"method", "NewPasswordPolicy",
+		"min-length", conf.MinLength,
+		"max-length", conf.MaxLength,
+		"min-classes", conf.MinClasses,
+		"forbid-user-info", conf.ForbidUserInfo,
+		"max-repeat", conf.MaxRepeat,
+		"breached-dir", conf.BreachedDir)
+
+	policy := iPasswordPolicy{
+		minLength:      conf.MinLength,
+		maxLength:      conf.MaxLength,
+		minClasses:     conf.MinClasses,
+		forbidUserInfo: conf.ForbidUserInfo,
+		maxRepeat:      conf.MaxRepeat,
+		logger:         logger,
+	}
+
+	if policy.minLength < DefaultMinLength {
+		policy.minLength = DefaultMinLength
+	}
+
+	if policy.maxLength == 0 {
+		policy.maxLength = DefaultMaxLength
+	}
+
+	if policy.minLength > policy.maxLength {
+		return nil, fmt.Errorf("password min length %d exceeds max length %d", policy.minLength, policy.maxLength)
+	}
+
+	if conf.BreachedDir != "" {
+		info, err := os.Stat(conf.BreachedDir)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("breached password list %s is not a directory", conf.BreachedDir)
+		}
+
+		policy.breached = &breachedList{dir: conf.BreachedDir}
+	}
+
+	return &policy, nil
+}
@@ -0,0 +1,142 @@
+package password
+
+import (
+	"fmt"
+	"github.com/Juno-chat-app/user-service/domain/entity"
+	"github.com/Juno-chat-app/user-service/infra/logger"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// user names and email local parts shorter than this are too common to forbid
+const minUserInfoLength int = 3
+
+type iPasswordPolicy struct {
+	minLength      int
+	maxLength      int
+	minClasses     int
+	forbidUserInfo bool
+	maxRepeat      int
+	breached       *breachedList
+	logger         logger.ILogger
+}
+
+func (p *iPasswordPolicy) Check(password string, user *entity.User) (err error) {
+	var violations []string
+
+	length := utf8.RuneCountInString(password)
+	if length < p.minLength {
+		violations = append(violations, fmt.Sprintf("must have at least %d characters", p.minLength))
+	}
+	if length > p.maxLength {
+		violations = append(violations, fmt.Sprintf("must have at most %d characters", p.maxLength))
+	}
+
+	if p.minClasses > 0 && characterClasses(password) < p.minClasses {
+		violations = append(violations, fmt.Sprintf("must contain %d of lower case letters, upper case letters, digits and symbols", p.minClasses))
+	}
+
+	if p.forbidUserInfo && user != nil {
+		folded := entity.CanonicalUserName(password)
+		if containsInfo(folded, entity.CanonicalUserName(user.UserName)) {
+			violations = append(violations, "must not contain the user name")
+		}
+		if user.ContactInfo != nil {
+			email := entity.CanonicalEmail(user.ContactInfo.Email)
+			if at := strings.LastIndex(email, "@"); at > 0 && containsInfo(folded, email[:at]) {
+				violations = append(violations, "must not contain the email")
+			}
+		}
+	}
+
+	if p.maxRepeat > 0 && longestRun(password) > p.maxRepeat {
+		violations = append(violations, fmt.Sprintf("must not repeat a character more than %d times in a row", p.maxRepeat))
+	}
+
+	// a known password is refused no matter which other rules it meets
+	if p.breached != nil && length <= p.maxLength {
+		breached, err := p.breached.contains(password)
+		if err != nil {
+			// the list is a safeguard on top of the rules, sign ups keep working without it
+			p.logger.Error("got error on reading breached password list",
+				"method", "Check",
+				"err", err)
+		} else if breached {
+			violations = append(violations, "appears in a list of breached passwords, choose another one")
+		}
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	return violationError(violations)
+}
+
+// violationError is the bad request error carrying the violations as field
+// violations of the password, so clients can show them next to the field
+func violationError(violations []string) error {
+	stat := status.New(codes.Code(http.StatusBadRequest), "password "+strings.Join(violations, ", "))
+
+	badRequest := errdetails.BadRequest{}
+	for _, violation := range violations {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       PasswordField,
+			Description: violation,
+		})
+	}
+
+	detailed, err := stat.WithDetails(&badRequest)
+	if err != nil {
+		return stat.Err()
+	}
+
+	return detailed.Err()
+}
+
+func characterClasses(password string) int {
+	var lower, upper, digit, symbol int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+
+	return lower + upper + digit + symbol
+}
+
+func containsInfo(password string, info string) bool {
+	return utf8.RuneCountInString(info) >= minUserInfoLength && strings.Contains(password, info)
+}
+
+// longestRun is the length of the longest run of one repeated character
+func longestRun(password string) int {
+	longest, run := 0, 0
+	var previous rune = -1
+	for _, r := range password {
+		if r == previous {
+			run++
+		} else {
+			run = 1
+		}
+		previous = r
+
+		if run > longest {
+			longest = run
+		}
+	}
+
+	return longest
+}
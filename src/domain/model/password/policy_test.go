@@ -0,0 +1,106 @@
+package password
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"github.com/Juno-chat-app/user-service/config"
+	"github.com/Juno-chat-app/user-service/domain/entity"
+	logger2 "github.com/Juno-chat-app/user-service/infra/logger"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var log logger2.ILogger
+
+func TestMain(m *testing.M) {
+	log, _ = logger2.NewLogger()
+
+	code := m.Run()
+	os.Exit(code)
+}
+
+func TestPasswordPolicy_Check(t *testing.T) {
+	policy, err := NewPasswordPolicy(config.PasswordConfig{
+		MinLength:      10,
+		MaxLength:      20,
+		MinClasses:     3,
+		ForbidUserInfo: true,
+		MaxRepeat:      3,
+	}, log)
+	require.Nil(t, err)
+
+	user := entity.User{
+		UserName:    "Alice",
+		ContactInfo: &entity.ContactInfo{Email: "wonder.land@juno.com"},
+	}
+
+	require.Nil(t, policy.Check("Correct-Horse7", &user))
+	require.Nil(t, policy.Check("Kor-rekt-Pferd-7", nil))
+
+	violations := checkViolations(t, policy, "short", &user)
+	require.Len(t, violations, 2)
+	require.Contains(t, violations[0], "at least 10")
+
+	violations = checkViolations(t, policy, strings.Repeat("Ab1-", 6), &user)
+	require.Equal(t, []string{"must have at most 20 characters"}, violations)
+
+	violations = checkViolations(t, policy, "my-ALICE-password1", &user)
+	require.Equal(t, []string{"must not contain the user name"}, violations)
+
+	violations = checkViolations(t, policy, "Wonder.Land-2020", &user)
+	require.Equal(t, []string{"must not contain the email"}, violations)
+
+	violations = checkViolations(t, policy, "Paaaasword-1", &user)
+	require.Equal(t, []string{"must not repeat a character more than 3 times in a row"}, violations)
+}
+
+func TestPasswordPolicy_Breached(t *testing.T) {
+	dir, err := ioutil.TempDir("", "breached-passwords")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	sum := sha1.Sum([]byte("Correct-Horse7"))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	ranges := "0018A45C4D1DEF81644B54AB7F969B88D65:1\r\n" + hash[5:] + ":42\r\n"
+	err = ioutil.WriteFile(filepath.Join(dir, hash[:5]+".txt"), []byte(ranges), 0600)
+	require.Nil(t, err)
+
+	policy, err := NewPasswordPolicy(config.PasswordConfig{BreachedDir: dir}, log)
+	require.Nil(t, err)
+
+	violations := checkViolations(t, policy, "Correct-Horse7", nil)
+	require.Len(t, violations, 1)
+	require.Contains(t, violations[0], "breached")
+
+	// prefixes without range file are not breached
+	require.Nil(t, policy.Check("Battery-Staple9", nil))
+
+	_, err = NewPasswordPolicy(config.PasswordConfig{BreachedDir: filepath.Join(dir, "missing")}, log)
+	require.NotNil(t, err)
+}
+
+func checkViolations(t *testing.T, policy IPasswordPolicy, password string, user *entity.User) []string {
+	err := policy.Check(password, user)
+	require.Equal(t, codes.Code(http.StatusBadRequest), status.Code(err))
+
+	details := status.Convert(err).Details()
+	require.Len(t, details, 1)
+	badRequest, ok := details[0].(*errdetails.BadRequest)
+	require.True(t, ok)
+
+	var violations []string
+	for _, violation := range badRequest.FieldViolations {
+		require.Equal(t, PasswordField, violation.Field)
+		violations = append(violations, violation.Description)
+	}
+
+	return violations
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"github.com/Juno-chat-app/user-service/domain/entity"
+	"github.com/Juno-chat-app/user-service/domain/model/authorization"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"time"
+)
+
+const (
+	DefaultDeletionGracePeriod = 30 * 24 * time.Hour
+)
+
+func (i *iUserService) DeleteAccount(ctx context.Context, token *authorization.TokenDetail, password string) (err error) {
+	i.logger.Info("DeleteAccount request",
+		"method", "DeleteAccount",
+		"user-id", token.UserId)
+
+	event := entity.AuditEvent{Type: entity.AccountDeletionEvent}
+	defer func() { i.recordEvent(ctx, &event, err) }()
+
+	token_, err := i.Validate(ctx, token)
+	if err != nil {
+		return err
+	}
+	event.Actor = token_.UserId
+	event.Target = token_.UserId
+
+	if token_.PrincipalType != authorization.UserPrincipal {
+		return status.Error(http.StatusForbidden, "only users have an account")
+	}
+
+	if password == "" {
+		return status.Error(http.StatusBadRequest, "invalid value for password")
+	}
+
+	user, err := i.repository.FindWithUserId(ctx, token_.UserId)
+	if err != nil {
+		return err
+	}
+
+	if user.DeletedAt != nil {
+		return status.Error(http.StatusNotFound, "user not found")
+	}
+
+	if !i.checkPassword(password, user.Password) {
+		return status.Error(http.StatusUnauthorized, "invalid password")
+	}
+
+	// the account can be restored until the purge job removes it for good
+	purgeAt := time.Now().UTC().Add(i.deletionGracePeriod)
+	user.PurgeAt = &purgeAt
+
+	_, err = i.repository.Remove(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	err = i.revokeUserSessions(ctx, user.UserId, "")
+	if err != nil {
+		return err
+	}
+
+	i.logger.Info("account deleted",
+		"method", "DeleteAccount",
+		"user-id", user.UserId,
+		"purge-at", purgeAt)
+	return nil
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/Juno-chat-app/user-service/domain/entity"
+	"github.com/Juno-chat-app/user-service/domain/model/authorization"
+	"github.com/Juno-chat-app/user-service/infra/mailer"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"time"
+)
+
+// A restore code lives in the cache under the hash of its value
+//
+//	account-restore:<code-hash>  the id of the deleted user
+const (
+	accountRestorePrefix string = "account-restore:"
+	accountRestoreLength int    = 24
+
+	DefaultRestoreTTL = 30 * time.Minute
+)
+
+func accountRestoreKey(codeHash string) string {
+	return accountRestorePrefix + codeHash
+}
+
+func (i *iUserService) RequestAccountRestore(ctx context.Context, email string) (err error) {
+	i.logger.Info("RequestAccountRestore request",
+		"method", "RequestAccountRestore",
+		"email", email)
+
+	if email == "" {
+		return status.Error(http.StatusBadRequest, "invalid value for email")
+	}
+
+	// emails without deleted account get the same answer so they can not be enumerated
+	user, err := i.repository.FindDeletedWithEmail(ctx, email)
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	code, err := randomToken(accountRestoreLength)
+	if err != nil {
+		return status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	err = i.cache.Set(ctx, accountRestoreKey(hashCode(code)), user.UserId, i.restoreTTL)
+	if err != nil {
+		return err
+	}
+
+	message := mailer.Message{
+		To:      user.ContactInfo.Email,
+		Subject: "Restore your Juno account",
+		Body: fmt.Sprintf("Hi %s,\r\n\r\nyour account restore code: %s\r\n\r\nThe code expires in %s. "+
+			"If you did not ask to restore your account you can ignore this mail.", user.UserName, code, i.restoreTTL),
+	}
+
+	// a failing mail must not tell that the email has a deleted account
+	err = i.mailer.Send(ctx, &message)
+	if err != nil {
+		i.logger.Error("got error on sending account restore mail",
+			"method", "RequestAccountRestore",
+			"user-id", user.UserId,
+			"err", err)
+		return nil
+	}
+
+	i.logger.Info("account restore requested",
+		"method", "RequestAccountRestore",
+		"user-id", user.UserId)
+	return nil
+}
+
+func (i *iUserService) RestoreAccount(ctx context.Context, code string) (user *entity.User, err error) {
+	i.logger.Info("RestoreAccount request",
+		"method", "RestoreAccount")
+
+	event := entity.AuditEvent{Type: entity.AccountRestoreEvent}
+	defer func() { i.recordEvent(ctx, &event, err) }()
+
+	if code == "" {
+		return nil, status.Error(http.StatusBadRequest, "invalid value for restore code")
+	}
+
+	// the code is single use, of concurrent restores only the one taking it goes on
+	userId, err := i.cache.Take(ctx, accountRestoreKey(hashCode(code)))
+	if err != nil {
+		if isNotFound(err) {
+			return nil, status.Error(http.StatusBadRequest, "invalid or expired restore code")
+		}
+		return nil, err
+	}
+
+	event.Actor = userId
+	event.Target = userId
+
+	return i.restore(ctx, userId, userId)
+}
+
+func (i *iUserService) AdminRestoreAccount(ctx context.Context, token *authorization.TokenDetail, userId string) (user *entity.User, err error) {
+	i.logger.Info("AdminRestoreAccount request",
+		"method", "AdminRestoreAccount",
+		"user-id", token.UserId,
+		"target-user-id", userId)
+
+	event := entity.AuditEvent{Type: entity.AccountRestoreEvent, Target: userId}
+	defer func() { i.recordEvent(ctx, &event, err) }()
+
+	token_, err := i.authorizeAdmin(ctx, token)
+	if token_ != nil {
+		event.Actor = token_.UserId
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if userId == "" {
+		return nil, status.Error(http.StatusBadRequest, "invalid value for user-id")
+	}
+
+	return i.restore(ctx, userId, token_.UserId)
+}
+
+func (i *iUserService) restore(ctx context.Context, userId string, actor string) (user *entity.User, err error) {
+	user, err = i.repository.Restore(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	i.logger.Info("account restored",
+		"method", "restore",
+		"user-id", user.UserId,
+		"actor", actor)
+
+	user.Password = "--secret--"
+	return user, nil
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/Juno-chat-app/user-service/domain/entity"
+	"github.com/Juno-chat-app/user-service/infra/mailer"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// activation-sent:<canonical-email>  the unix time before which no further activation mail is sent
+const (
+	activationCodeLength int    = 24
+	activationSentPrefix string = "activation-sent:"
+
+	activationResendDelay = time.Minute
+
+	DefaultActivationTTL = 24 * time.Hour
+)
+
+func (i *iUserService) ActivateAccount(ctx context.Context, email string, code string) (err error) {
+	i.logger.Info("ActivateAccount request",
+		"method", "ActivateAccount",
+		"email", email)
+
+	if email == "" || code == "" {
+		return status.Error(http.StatusBadRequest, "invalid value for email or activation code")
+	}
+
+	user, err := i.repository.FindWithEmail(ctx, email)
+	if err != nil {
+		if isNotFound(err) {
+			return status.Error(http.StatusBadRequest, "invalid or expired activation code")
+		}
+		return err
+	}
+
+	userStatus := user.Status
+	if userStatus == nil || userStatus.Status != entity.Pending || userStatus.ActivationCode == "" {
+		return status.Error(http.StatusBadRequest, "invalid or expired activation code")
+	}
+
+	now := time.Now().UTC()
+	if userStatus.ActivationExpireAt == nil || now.After(*userStatus.ActivationExpireAt) ||
+		!matchesCode(code, userStatus.ActivationCode) {
+		return status.Error(http.StatusBadRequest, "invalid or expired activation code")
+	}
+
+	// the code is single use, it is dropped with the activation
+	userStatus.Status = entity.Active
+	userStatus.ActivationCode = ""
+	userStatus.ActivationExpireAt = nil
+	userStatus.UpdatedAt = &now
+
+	err = i.repository.UpdateStatus(ctx, user.UserId, userStatus)
+	if err != nil {
+		return err
+	}
+
+	// the activation code was mailed, so it proves the email as well
+	_, err = i.repository.Update(ctx, user.UserId, map[entity.Path]interface{}{
+		entity.EmailVerifiedPath: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	i.logger.Info("account activated",
+		"method", "ActivateAccount",
+		"user-id", user.UserId)
+	return nil
+}
+
+func (i *iUserService) ResendActivation(ctx context.Context, email string) (err error) {
+	i.logger.Info("ResendActivation request",
+		"method", "ResendActivation",
+		"email", email)
+
+	if email == "" {
+		return status.Error(http.StatusBadRequest, "invalid value for email")
+	}
+
+	// anyone may call it, so mails to an address are not sent back to back. The delay
+	// is held for unknown emails as well to answer them the same
+	now := time.Now().UTC()
+	sentKey := activationSentPrefix + entity.CanonicalEmail(email)
+	ok, err := i.cache.SetIfAbsent(ctx, sentKey, strconv.FormatInt(now.Add(activationResendDelay).Unix(), 10), activationResendDelay)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		until, err := i.loadTime(ctx, sentKey)
+		if err != nil {
+			return err
+		}
+		return retryLater("an activation mail was sent recently", until.Sub(now))
+	}
+
+	// unknown and already active emails get the same answer so they can not be enumerated
+	user, err := i.repository.FindWithEmail(ctx, email)
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	if user.Status == nil || user.Status.Status != entity.Pending {
+		return nil
+	}
+
+	code, err := i.newActivationCode(user.Status, now)
+	if err != nil {
+		return err
+	}
+
+	err = i.repository.UpdateStatus(ctx, user.UserId, user.Status)
+	if err != nil {
+		return err
+	}
+
+	err = i.sendActivation(ctx, user, code)
+	if err != nil {
+		_ = i.cache.Remove(ctx, sentKey)
+		return err
+	}
+
+	return nil
+}
+
+// newActivationCode replaces the activation code of userStatus, only its hash is kept
+func (i *iUserService) newActivationCode(userStatus *entity.UserStatus, now time.Time) (code string, err error) {
+	code, err = randomToken(activationCodeLength)
+	if err != nil {
+		return "", status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	expireAt := now.Add(i.activationTTL)
+	userStatus.ActivationCode = hashCode(code)
+	userStatus.ActivationExpireAt = &expireAt
+	userStatus.UpdatedAt = &now
+
+	return code, nil
+}
+
+func (i *iUserService) sendActivation(ctx context.Context, user *entity.User, code string) (err error) {
+	message := mailer.Message{
+		To:      user.ContactInfo.Email,
+		Subject: "Activate your Juno account",
+		Body: fmt.Sprintf("Hi %s,\r\n\r\nyour activation code: %s\r\n\r\nThe code expires at %s.",
+			user.UserName, code, user.Status.ActivationExpireAt.Format(time.RFC1123)),
+	}
+
+	err = i.mailer.Send(ctx, &message)
+	if err != nil {
+		i.logger.Error("got error on sending activation mail",
+			"method", "sendActivation",
+			"user-id", user.UserId,
+			"err", err)
+
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"github.com/Juno-chat-app/user-service/domain/entity"
+	"github.com/Juno-chat-app/user-service/domain/model/authorization"
+	"google.golang.org/grpc/status"
+	"net/http"
+)
+
+// AdminPermission grants the administrative operations of the service
+var AdminPermission = entity.Permission{Key: "role", Value: "admin"}
+
+// authorizeAdmin validates token and checks that it belongs to an admin user,
+// the stored permissions are used when the token does not carry them. A valid token
+// of a user who is no admin is returned along with the error
+func (i *iUserService) authorizeAdmin(ctx context.Context, token *authorization.TokenDetail) (token_ *authorization.TokenDetail, err error) {
+	token_, err = i.Validate(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	admin, err := i.isAdmin(ctx, token_)
+	if err != nil {
+		return nil, err
+	}
+
+	if !admin {
+		i.logger.Warn("admin operation denied",
+			"method", "authorizeAdmin",
+			"user-id", token_.UserId)
+		return token_, status.Error(http.StatusForbidden, "admin permission required")
+	}
+
+	return token_, nil
+}
+
+// isAdmin checks the permissions of a validated token, the stored permissions
+// are used when the token does not carry them
+func (i *iUserService) isAdmin(ctx context.Context, token *authorization.TokenDetail) (admin bool, err error) {
+	if token.PrincipalType != authorization.UserPrincipal {
+		return false, nil
+	}
+
+	if hasPermission(token.Permissions, AdminPermission) {
+		return true, nil
+	}
+
+	user, err := i.repository.FindWithUserId(ctx, token.UserId)
+	if err != nil {
+		return false, err
+	}
+
+	return hasPermission(user.Permissions, AdminPermission), nil
+}
+
+func hasPermission(permissions []*entity.Permission, permission entity.Permission) bool {
+	for _, p := range permissions {
+		if p != nil && *p == permission {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"github.com/Juno-chat-app/user-service/domain/entity"
+	"github.com/Juno-chat-app/user-service/domain/model/authorization"
+	"github.com/Juno-chat-app/user-service/domain/repository/mongo"
+	"github.com/twinj/uuid"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"time"
+)
+
+const (
+	DefaultAuditPerPage int = 20
+	MaxAuditPerPage     int = 100
+
+	// auditSaveTimeout bounds the save of an event, which does not end with the request
+	auditSaveTimeout = 5 * time.Second
+)
+
+// AuditQuery selects the audit events an admin looks at, empty fields match every event.
+// Page counts from 1
+type AuditQuery struct {
+	Type      entity.AuditEventType
+	Actor     string
+	Target    string
+	IpAddress string
+	Outcome   entity.AuditOutcome
+	From      *time.Time
+	To        *time.Time
+	Page      int
+	PerPage   int
+}
+
+func (i *iUserService) QueryAuditEvents(ctx context.Context, token *authorization.TokenDetail, query AuditQuery) (events []*entity.AuditEvent, total int64, err error) {
+	i.logger.Info("QueryAuditEvents request",
+		"method", "QueryAuditEvents",
+		"user-id", token.UserId,
+		"query", query)
+
+	_, err = i.authorizeAdmin(ctx, token)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if query.Page == 0 {
+		query.Page = 1
+	}
+	if query.PerPage == 0 {
+		query.PerPage = DefaultAuditPerPage
+	}
+	if query.Page < 0 || query.PerPage < 0 || query.PerPage > MaxAuditPerPage {
+		return nil, 0, status.Errorf(http.StatusBadRequest, "invalid page, at most %d events are returned per page", MaxAuditPerPage)
+	}
+
+	if query.From != nil && query.To != nil && !query.From.Before(*query.To) {
+		return nil, 0, status.Error(http.StatusBadRequest, "invalid time range")
+	}
+
+	fields := map[entity.Path]string{}
+	for path, value := range map[entity.Path]string{
+		entity.AuditTypePath:      string(query.Type),
+		entity.AuditActorPath:     query.Actor,
+		entity.AuditTargetPath:    query.Target,
+		entity.AuditIpAddressPath: query.IpAddress,
+		entity.AuditOutcomePath:   string(query.Outcome),
+	} {
+		if value != "" {
+			fields[path] = value
+		}
+	}
+
+	return i.audits.Find(ctx, mongo.AuditQuery{
+		Fields: fields,
+		From:   query.From,
+		To:     query.To,
+		Skip:   int64((query.Page - 1) * query.PerPage),
+		Limit:  int64(query.PerPage),
+	})
+}
+
+// recordEvent writes event to the audit log with the outcome of err, operations
+// record their event deferred so every return is covered. A failing audit log is
+// only logged, it does not fail the operation. The event is saved apart from ctx so
+// requests the caller cancelled are recorded as well
+func (i *iUserService) recordEvent(ctx context.Context, event *entity.AuditEvent, err error) {
+	now := time.Now().UTC()
+	client := clientInfoFrom(ctx)
+
+	event.EventId = uuid.NewV4().String()
+	event.IpAddress = client.IpAddress
+	event.UserAgent = client.UserAgent
+	event.Time = &now
+	event.DocumentVersion = entity.DocumentVersion
+
+	if err != nil {
+		event.Outcome = entity.Failed
+		event.Reason = status.Convert(err).Message()
+	} else if event.Outcome == "" {
+		event.Outcome = entity.Succeeded
+	}
+
+	saveCtx, cancel := context.WithTimeout(context.Background(), auditSaveTimeout)
+	defer cancel()
+
+	saveErr := i.audits.Save(saveCtx, event)
+	if saveErr != nil {
+		i.logger.Error("got error on saving audit event",
+			"method", "recordEvent",
+			"type", event.Type,
+			"actor", event.Actor,
+			"target", event.Target,
+			"outcome", event.Outcome,
+			"err", saveErr)
+	}
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"github.com/Juno-chat-app/user-service/domain/entity"
+	"github.com/Juno-chat-app/user-service/domain/model/authorization"
+	"github.com/twinj/uuid"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	clientSecretLength int = 32
+)
+
+func (i *iUserService) ClientToken(ctx context.Context, clientId string, clientSecret string, scopes []string) (token *authorization.TokenDetail, err error) {
+	i.logger.Info("ClientToken request",
+		"method", "ClientToken",
+		"client-id", clientId,
+		"scopes", scopes)
+
+	if clientId == "" || clientSecret == "" {
+		return nil, status.Error(http.StatusBadRequest, "invalid value for client-id or client-secret")
+	}
+
+	client, err := i.clients.FindWithClientId(ctx, clientId)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, status.Error(http.StatusUnauthorized, "invalid client credentials")
+		}
+		return nil, err
+	}
+
+	if !i.checkPassword(clientSecret, client.SecretHash) {
+		return nil, status.Error(http.StatusUnauthorized, "invalid client credentials")
+	}
+
+	// without requested scopes the client gets every scope it is allowed
+	granted := client.Scopes
+	if len(scopes) != 0 {
+		for _, scope := range scopes {
+			if !containsString(client.Scopes, scope) {
+				return nil, status.Error(http.StatusBadRequest, "invalid scope "+scope)
+			}
+		}
+		granted = scopes
+	}
+
+	token, err = i.auth.CreateClientToken(client.ClientId, granted)
+	if err != nil {
+		return nil, err
+	}
+
+	// client tokens have no session, they are only kept for stateful validation
+	err = i.cache.Set(ctx, token.AccessUUid, token.AccessToken, expiration(token.ExpireAt))
+	if err != nil {
+		return nil, err
+	}
+
+	i.logger.Info("client token issued",
+		"method", "ClientToken",
+		"client-id", client.ClientId,
+		"scopes", granted)
+	return token, nil
+}
+
+func (i *iUserService) RegisterClient(ctx context.Context, token *authorization.TokenDetail, client *entity.Client) (client_ *entity.Client, secret string, err error) {
+	i.logger.Info("RegisterClient request",
+		"method", "RegisterClient",
+		"user-id", token.UserId,
+		"client-name", client.Name,
+		"scopes", client.Scopes)
+
+	event := entity.AuditEvent{Type: entity.RegisterClientEvent,
+		Details: map[string]string{"client-name": client.Name, "scopes": strings.Join(client.Scopes, " ")}}
+	defer func() { i.recordEvent(ctx, &event, err) }()
+
+	token_, err := i.authorizeAdmin(ctx, token)
+	if token_ != nil {
+		event.Actor = token_.UserId
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	if client.Name == "" {
+		return nil, "", status.Error(http.StatusBadRequest, "invalid value for client name")
+	}
+
+	secret, err = randomToken(clientSecretLength)
+	if err != nil {
+		return nil, "", status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	hash, err := i.hashPassword(secret)
+	if err != nil {
+		return nil, "", status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	create := time.Now().UTC()
+	client.ClientId = uuid.NewV4().String()
+	event.Target = client.ClientId
+	client.SecretHash = hash
+	client.CreatedAt = &create
+	client.UpdatedAt = &create
+	client.DocumentVersion = entity.DocumentVersion
+
+	client_, err = i.clients.Save(ctx, client)
+	if err != nil {
+		return nil, "", err
+	}
+
+	i.logger.Info("client registered",
+		"method", "RegisterClient",
+		"user-id", token_.UserId,
+		"client-id", client_.ClientId,
+		"client-name", client_.Name)
+
+	// the secret is only known to the caller from now on
+	client_.SecretHash = "--secret--"
+	return client_, secret, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
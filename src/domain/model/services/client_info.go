@@ -0,0 +1,24 @@
+package services
+
+import (
+	"context"
+)
+
+type clientInfoKey struct{}
+
+// ClientInfo describes the client a request is coming from
+type ClientInfo struct {
+	IpAddress string
+	UserAgent string
+}
+
+// WithClientInfo attaches the client of the request to ctx, the transport
+// layer fills it before calling the service
+func WithClientInfo(ctx context.Context, info ClientInfo) context.Context {
+	return context.WithValue(ctx, clientInfoKey{}, info)
+}
+
+func clientInfoFrom(ctx context.Context) ClientInfo {
+	info, _ := ctx.Value(clientInfoKey{}).(ClientInfo)
+	return info
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// randomToken returns length random bytes encoded for use in urls
+func randomToken(length int) (token string, err error) {
+	buf := make([]byte, length)
+	_, err = rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// randomDigits returns length random decimal digits, for codes users have to type
+func randomDigits(length int) (code string, err error) {
+	buf := make([]byte, length)
+	_, err = rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+
+	// 250 is the largest multiple of 10 below 256, larger bytes are drawn again
+	// so every digit is equally likely
+	for i := range buf {
+		for buf[i] >= 250 {
+			_, err = rand.Read(buf[i : i+1])
+			if err != nil {
+				return "", err
+			}
+		}
+		buf[i] = '0' + buf[i]%10
+	}
+
+	return string(buf), nil
+}
+
+// hashCode is used for the single use codes sent to users, only the hash is stored.
+// A fast hash is only sufficient as the codes can not be guessed offline: short codes
+// live in the cache for minutes with limited attempts, the codes stored with the user
+// carry at least 80 random bits
+func hashCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+func matchesCode(code string, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(hashCode(code)), []byte(hash)) == 1
+}
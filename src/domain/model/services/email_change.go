@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/Juno-chat-app/user-service/domain/entity"
+	"github.com/Juno-chat-app/user-service/domain/model/authorization"
+	"github.com/Juno-chat-app/user-service/infra/mailer"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"strings"
+)
+
+// An email change is pending in the cache until the code mailed to the new
+// address confirms it, a new change replaces the pending one
+//
+//	email-change:<user-id>  hash of the code and the new email
+const (
+	emailChangePrefix     string = "email-change:"
+	emailChangeCodeLength int    = 24
+)
+
+func emailChangeKey(userId string) string {
+	return emailChangePrefix + userId
+}
+
+// requestEmailChange mails a confirmation code to email, the email of user only
+// changes with ConfirmEmailChange so a stolen access token can not take it over
+func (i *iUserService) requestEmailChange(ctx context.Context, user *entity.User, email string) (err error) {
+	code, err := randomToken(emailChangeCodeLength)
+	if err != nil {
+		return status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	err = i.cache.Set(ctx, emailChangeKey(user.UserId), hashCode(code)+"|"+email, i.activationTTL)
+	if err != nil {
+		return err
+	}
+
+	message := mailer.Message{
+		To:      email,
+		Subject: "Confirm your new Juno email",
+		Body: fmt.Sprintf("Hi %s,\r\n\r\nyour email change code: %s\r\n\r\nThe code expires in %s. "+
+			"If you did not change your email you can ignore this mail.", user.UserName, code, i.activationTTL),
+	}
+
+	err = i.mailer.Send(ctx, &message)
+	if err != nil {
+		return err
+	}
+
+	i.logger.Info("email change requested",
+		"method", "requestEmailChange",
+		"user-id", user.UserId)
+	return nil
+}
+
+func (i *iUserService) ConfirmEmailChange(ctx context.Context, token *authorization.TokenDetail, code string) (user *entity.User, err error) {
+	i.logger.Info("ConfirmEmailChange request",
+		"method", "ConfirmEmailChange",
+		"user-id", token.UserId)
+
+	event := entity.AuditEvent{Type: entity.ProfileChangeEvent, Details: map[string]string{"mask": string(entity.EmailPath)}}
+	defer func() { i.recordEvent(ctx, &event, err) }()
+
+	token_, err := i.Validate(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if token_.PrincipalType != authorization.UserPrincipal {
+		return nil, status.Error(http.StatusForbidden, "only users have an email")
+	}
+	event.Actor = token_.UserId
+	event.Target = token_.UserId
+
+	if code == "" {
+		return nil, status.Error(http.StatusBadRequest, "invalid value for email change code")
+	}
+
+	key := emailChangeKey(token_.UserId)
+	pending, err := i.cache.Get(ctx, key)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, status.Error(http.StatusBadRequest, "invalid or expired email change code")
+		}
+		return nil, err
+	}
+
+	parts := strings.SplitN(pending, "|", 2)
+	if len(parts) != 2 || !matchesCode(code, parts[0]) {
+		return nil, status.Error(http.StatusBadRequest, "invalid or expired email change code")
+	}
+
+	// the code is single use, of concurrent confirmations only the one taking it goes on
+	taken, err := i.cache.Take(ctx, key)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, status.Error(http.StatusBadRequest, "invalid or expired email change code")
+		}
+		return nil, err
+	}
+	if taken != pending {
+		return nil, status.Error(http.StatusBadRequest, "invalid or expired email change code")
+	}
+
+	// the code was mailed to the new address, so it proves the email as well
+	user, err = i.repository.Update(ctx, token_.UserId, map[entity.Path]interface{}{
+		entity.EmailPath:         parts[1],
+		entity.EmailVerifiedPath: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	i.logger.Info("email changed",
+		"method", "ConfirmEmailChange",
+		"user-id", user.UserId)
+
+	user.Password = "--secret--"
+	return user, nil
+}
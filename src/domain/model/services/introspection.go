@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"github.com/Juno-chat-app/user-service/domain/entity"
+	"github.com/Juno-chat-app/user-service/domain/model/authorization"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"strings"
+)
+
+const (
+	AccessTokenType  string = "access_token"
+	RefreshTokenType string = "refresh_token"
+
+	// IntrospectScope is the client scope RFC 7662 callers must hold
+	IntrospectScope string = "introspect"
+)
+
+// Introspection is the RFC 7662 view of a token, an inactive token carries no other data
+type Introspection struct {
+	Active    bool
+	TokenType string
+	UserId    string
+	// PrincipalType is authorization.UserPrincipal or authorization.ClientPrincipal
+	PrincipalType string
+	Permissions   []*entity.Permission
+	Scopes        []string
+	IssuedAt      int64
+	ExpireAt      int64
+	SessionId     string
+	IpAddress     string
+	UserAgent     string
+}
+
+// introspectAccess returns nil when token is not a usable access token
+func (i *iUserService) introspectAccess(ctx context.Context, token string) (introspection *Introspection, err error) {
+	token_, err := i.auth.ValidateAccessToken(token)
+	if err != nil {
+		return nil, nil
+	}
+
+	stored, err := i.cache.Get(ctx, token_.AccessUUid)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if stored != token_.AccessToken {
+		return nil, nil
+	}
+
+	introspection = &Introspection{
+		Active:        true,
+		TokenType:     AccessTokenType,
+		UserId:        token_.UserId,
+		PrincipalType: token_.PrincipalType,
+		Permissions:   token_.Permissions,
+		Scopes:        token_.Scopes,
+		IssuedAt:      token_.IssuedAt,
+		ExpireAt:      token_.ExpireAt,
+	}
+
+	return introspection, i.introspectSession(ctx, token_.FamilyId, introspection)
+}
+
+// introspectRefresh returns nil when token is not a usable refresh token
+func (i *iUserService) introspectRefresh(ctx context.Context, token string) (introspection *Introspection, err error) {
+	token_, err := i.auth.ValidateRefreshToken(token)
+	if err != nil {
+		return nil, nil
+	}
+
+	stored, err := i.cache.Get(ctx, token_.RefreshUUid)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if stored != token_.RefreshToke {
+		return nil, nil
+	}
+
+	introspection = &Introspection{
+		Active:        true,
+		TokenType:     RefreshTokenType,
+		UserId:        token_.UserId,
+		PrincipalType: authorization.UserPrincipal,
+		IssuedAt:      token_.IssuedAt,
+		ExpireAt:      token_.RefreshExpireAt,
+	}
+
+	return introspection, i.introspectSession(ctx, token_.FamilyId, introspection)
+}
+
+func (i *iUserService) introspectSession(ctx context.Context, sessionId string, introspection *Introspection) (err error) {
+	if sessionId == "" {
+		return nil
+	}
+
+	session, err := i.loadSession(ctx, sessionId)
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	introspection.SessionId = session.SessionId
+	introspection.IpAddress = session.IpAddress
+	introspection.UserAgent = session.UserAgent
+	return nil
+}
+
+func (i *iUserService) Introspect(ctx context.Context, caller *authorization.TokenDetail, token string, tokenTypeHint string) (introspection *Introspection, err error) {
+	i.logger.Info("token introspection",
+		"method", "Introspect",
+		"client-id", caller.UserId,
+		"token-type-hint", tokenTypeHint)
+
+	// introspection exposes sessions and permissions, RFC 7662 asks callers to authenticate
+	caller_, err := i.Validate(ctx, caller)
+	if err != nil {
+		return nil, err
+	}
+
+	if caller_.PrincipalType != authorization.ClientPrincipal || !containsString(caller_.Scopes, IntrospectScope) {
+		return nil, status.Error(http.StatusForbidden, "introspection requires a client with the introspect scope")
+	}
+
+	token = strings.TrimPrefix(token, "Bearer ")
+
+	// the hint only decides the order, RFC 7662 asks to search further on a miss
+	introspectors := []func(ctx context.Context, token string) (*Introspection, error){
+		i.introspectAccess,
+		i.introspectRefresh,
+	}
+	if tokenTypeHint == RefreshTokenType {
+		introspectors[0], introspectors[1] = introspectors[1], introspectors[0]
+	}
+
+	for _, introspect := range introspectors {
+		introspection, err = introspect(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+
+		if introspection != nil {
+			return introspection, nil
+		}
+	}
+
+	return &Introspection{Active: false}, nil
+}
@@ -0,0 +1,305 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/Juno-chat-app/user-service/config"
+	"github.com/Juno-chat-app/user-service/domain/entity"
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Failed sign ins are counted per user name and per ip address, the cache holds
+//
+//	login-failures:<kind>:<id>  the sliding window of failures
+//	login-lockout:<kind>:<id>   the unix time until which sign ins are refused
+//	login-delay:<user-name>     the unix time before which no further attempt is taken
+//
+// refused attempts never reach the password check, so guessing costs no hashing, and
+// every other attempt is counted before it so parallel guesses share the limits
+const (
+	loginFailuresPrefix string = "login-failures:"
+	loginLockoutPrefix  string = "login-lockout:"
+	loginDelayPrefix    string = "login-delay:"
+
+	userNameKind string = "user:"
+	ipKind       string = "ip:"
+
+	DefaultLoginWindow            = 15 * time.Minute
+	DefaultLoginDelayAfter    int = 3
+	DefaultLoginMaxDelay          = 30 * time.Second
+	DefaultLockoutThreshold   int = 10
+	DefaultIpLockoutThreshold int = 100
+	DefaultLockoutDuration        = 15 * time.Minute
+)
+
+type loginLimits struct {
+	window           time.Duration
+	delayAfter       int
+	maxDelay         time.Duration
+	lockoutThreshold int
+	ipThreshold      int
+	lockoutDuration  time.Duration
+}
+
+func newLoginLimits(conf config.LoginConfig) loginLimits {
+	limits := loginLimits{
+		window:           conf.Window * time.Minute,
+		delayAfter:       conf.DelayAfter,
+		maxDelay:         conf.MaxDelay * time.Second,
+		lockoutThreshold: conf.LockoutThreshold,
+		ipThreshold:      conf.IpThreshold,
+		lockoutDuration:  conf.LockoutDuration * time.Minute,
+	}
+
+	if limits.window == 0 {
+		limits.window = DefaultLoginWindow
+	}
+	if limits.delayAfter == 0 {
+		limits.delayAfter = DefaultLoginDelayAfter
+	}
+	if limits.maxDelay == 0 {
+		limits.maxDelay = DefaultLoginMaxDelay
+	}
+	if limits.lockoutThreshold == 0 {
+		limits.lockoutThreshold = DefaultLockoutThreshold
+	}
+	if limits.ipThreshold == 0 {
+		limits.ipThreshold = DefaultIpLockoutThreshold
+	}
+	if limits.lockoutDuration == 0 {
+		limits.lockoutDuration = DefaultLockoutDuration
+	}
+
+	return limits
+}
+
+// loginAttempt is a sign in which is counted as failure before its password is
+// checked, so parallel guesses can not all pass the limits of one another
+type loginAttempt struct {
+	userName   string
+	ipAddress  string
+	userEvent  string
+	ipEvent    string
+	failures   int64
+	ipFailures int64
+}
+
+// beginLogin refuses sign ins of locked user names and ip addresses and the ones
+// arriving before the delay of the previous failure passed, the others are counted
+// right away and hold the delay before the next attempt
+func (i *iUserService) beginLogin(ctx context.Context, userName string, ipAddress string) (attempt *loginAttempt, err error) {
+	limits := i.loginLimits
+	keys := []string{
+		loginLockoutPrefix + userNameKind + userName,
+		loginDelayPrefix + userName,
+	}
+	if ipAddress != "" {
+		keys = append(keys, loginLockoutPrefix+ipKind+ipAddress)
+	}
+
+	now := time.Now()
+	for _, key := range keys {
+		until, err := i.loadTime(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+
+		if until.After(now) {
+			return nil, tooManyAttempts(until.Sub(now))
+		}
+	}
+
+	attempt = &loginAttempt{userName: userName, ipAddress: ipAddress}
+	attempt.userEvent, attempt.failures, err = i.cache.AddEvent(ctx, loginFailuresPrefix+userNameKind+userName, limits.window)
+	if err != nil {
+		return nil, err
+	}
+
+	if ipAddress != "" {
+		attempt.ipEvent, attempt.ipFailures, err = i.cache.AddEvent(ctx, loginFailuresPrefix+ipKind+ipAddress, limits.window)
+		if err != nil {
+			return nil, i.abortLogin(ctx, attempt, err)
+		}
+	}
+
+	// attempts running in parallel with the one reaching a limit are refused
+	if attempt.failures > int64(limits.lockoutThreshold) {
+		return nil, i.abortLogin(ctx, attempt, tooManyAttempts(limits.lockoutDuration))
+	}
+	if ipAddress != "" && attempt.ipFailures > int64(limits.ipThreshold) {
+		return nil, i.abortLogin(ctx, attempt, tooManyAttempts(limits.lockoutDuration))
+	}
+
+	if attempt.failures > int64(limits.delayAfter) {
+		delay := time.Second << uint(attempt.failures-int64(limits.delayAfter)-1)
+		if delay > limits.maxDelay || delay <= 0 {
+			delay = limits.maxDelay
+		}
+
+		// of parallel attempts only the one setting the delay goes on
+		ok, err := i.cache.SetIfAbsent(ctx, loginDelayPrefix+userName, strconv.FormatInt(now.Add(delay).Unix(), 10), delay)
+		if err != nil {
+			return nil, i.abortLogin(ctx, attempt, err)
+		}
+		if !ok {
+			return nil, i.abortLogin(ctx, attempt, tooManyAttempts(delay))
+		}
+	}
+
+	return attempt, nil
+}
+
+// beginSecondFactor counts a code given for the mfa challenge of signIn against its
+// user name, the attempt takes the ip address event of signIn along so a completed
+// sign in takes it back. Locked user names are refused, the delay does not apply as
+// signIn held it already
+func (i *iUserService) beginSecondFactor(ctx context.Context, signIn *loginAttempt) (attempt *loginAttempt, err error) {
+	now := time.Now()
+	until, err := i.loadTime(ctx, loginLockoutPrefix+userNameKind+signIn.userName)
+	if err != nil {
+		return nil, err
+	}
+
+	if until.After(now) {
+		return nil, tooManyAttempts(until.Sub(now))
+	}
+
+	attempt = &loginAttempt{userName: signIn.userName}
+	attempt.userEvent, attempt.failures, err = i.cache.AddEvent(ctx, loginFailuresPrefix+userNameKind+signIn.userName, i.loginLimits.window)
+	if err != nil {
+		return nil, err
+	}
+
+	if attempt.failures > int64(i.loginLimits.lockoutThreshold) {
+		return nil, i.abortLogin(ctx, attempt, tooManyAttempts(i.loginLimits.lockoutDuration))
+	}
+
+	attempt.ipAddress = signIn.ipAddress
+	attempt.ipEvent = signIn.ipEvent
+	return attempt, nil
+}
+
+// abortLogin takes back the count of a refused attempt and returns err
+func (i *iUserService) abortLogin(ctx context.Context, attempt *loginAttempt, err error) error {
+	_ = i.cache.RemoveEvent(ctx, loginFailuresPrefix+userNameKind+attempt.userName, attempt.userEvent)
+	if attempt.ipEvent != "" {
+		_ = i.cache.RemoveEvent(ctx, loginFailuresPrefix+ipKind+attempt.ipAddress, attempt.ipEvent)
+	}
+
+	return err
+}
+
+// loginFailed keeps the failure counted by beginLogin and locks what reached its limit
+func (i *iUserService) loginFailed(ctx context.Context, attempt *loginAttempt) (err error) {
+	now := time.Now()
+
+	if attempt.failures >= int64(i.loginLimits.lockoutThreshold) {
+		err = i.lockout(ctx, userNameKind, attempt.userName, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	if attempt.ipAddress != "" && attempt.ipFailures >= int64(i.loginLimits.ipThreshold) {
+		return i.lockout(ctx, ipKind, attempt.ipAddress, now)
+	}
+
+	return nil
+}
+
+// loginSucceeded forgets the failures of the user name, of the ip address only the
+// attempt is taken back as other user names may be guessed from there
+func (i *iUserService) loginSucceeded(ctx context.Context, attempt *loginAttempt) (err error) {
+	err = i.cache.Remove(ctx, loginFailuresPrefix+userNameKind+attempt.userName)
+	if err != nil {
+		return err
+	}
+
+	if attempt.ipEvent != "" {
+		err = i.cache.RemoveEvent(ctx, loginFailuresPrefix+ipKind+attempt.ipAddress, attempt.ipEvent)
+		if err != nil {
+			return err
+		}
+	}
+
+	return i.cache.Remove(ctx, loginDelayPrefix+attempt.userName)
+}
+
+func (i *iUserService) lockout(ctx context.Context, kind string, id string, now time.Time) (err error) {
+	duration := i.loginLimits.lockoutDuration
+	until := now.Add(duration)
+
+	err = i.cache.Set(ctx, loginLockoutPrefix+kind+id, strconv.FormatInt(until.Unix(), 10), duration)
+	if err != nil {
+		return err
+	}
+
+	// the lockout starts a new window, old failures must not lock again once it ends
+	err = i.cache.Remove(ctx, loginFailuresPrefix+kind+id)
+	if err != nil {
+		return err
+	}
+
+	i.logger.Warn("security event: sign ins locked after repeated failures",
+		"method", "lockout",
+		"event", "login-lockout",
+		"kind", kind[:len(kind)-1],
+		"id", id,
+		"until", until)
+	return nil
+}
+
+// loadTime reads a unix time stored at key, missing keys are the zero time
+func (i *iUserService) loadTime(ctx context.Context, key string) (tm time.Time, err error) {
+	value, err := i.cache.Get(ctx, key)
+	if err != nil {
+		if isNotFound(err) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+
+	unix, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, nil
+	}
+
+	return time.Unix(unix, 0), nil
+}
+
+// tooManyAttempts is the resource exhausted error of refused sign ins, it carries
+// the time to wait as retry info
+func tooManyAttempts(retryAfter time.Duration) error {
+	return retryLater("too many failed sign ins", retryAfter)
+}
+
+// retryLater is the resource exhausted error of throttled requests, it carries
+// the time to wait as retry info
+func retryLater(reason string, retryAfter time.Duration) error {
+	retryAfter = retryAfter.Round(time.Second)
+	if retryAfter < time.Second {
+		retryAfter = time.Second
+	}
+
+	stat := status.New(codes.Code(http.StatusTooManyRequests),
+		fmt.Sprintf("%s, retry after %s", reason, retryAfter))
+
+	detailed, err := stat.WithDetails(&errdetails.RetryInfo{RetryDelay: ptypes.DurationProto(retryAfter)})
+	if err != nil {
+		return stat.Err()
+	}
+
+	return detailed.Err()
+}
+
+// loginKey is the form user names are throttled under, so changing the case
+// does not start over
+func loginKey(userName string) string {
+	return entity.CanonicalUserName(userName)
+}
@@ -0,0 +1,422 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"github.com/Juno-chat-app/user-service/domain/entity"
+	"github.com/Juno-chat-app/user-service/domain/model/authorization"
+	"github.com/Juno-chat-app/user-service/domain/model/mfa"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// A sign in waiting for the second factor lives in the cache under the hash of its challenge token
+//
+//	mfa-challenge:<token-hash>           the user id that gave the password and its sign in attempt
+//	mfa-challenge-attempts:<token-hash>  wrong codes given for the challenge
+const (
+	mfaChallengePrefix         string = "mfa-challenge:"
+	mfaChallengeAttemptsPrefix string = "mfa-challenge-attempts:"
+	mfaChallengeLength         int    = 24
+	recoveryCodeLength         int    = 10 // bytes, 16 base32 characters
+	recoveryCodeGroup          int    = 4
+
+	DefaultMfaChallengeTTL          = 5 * time.Minute
+	DefaultMfaChallengeAttempts int = 5
+	DefaultRecoveryCodes        int = 10
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func (i *iUserService) EnrollTotp(ctx context.Context, token *authorization.TokenDetail) (secret string, uri string, err error) {
+	i.logger.Info("EnrollTotp request",
+		"method", "EnrollTotp",
+		"user-id", token.UserId)
+
+	user, err := i.mfaOwner(ctx, token)
+	if err != nil {
+		return "", "", err
+	}
+
+	if user.Mfa != nil && user.Mfa.Enabled {
+		return "", "", status.Error(http.StatusConflict, "two-factor authentication is enabled already")
+	}
+
+	secret, uri, err = i.totp.NewSecret(user.UserName)
+	if err != nil {
+		return "", "", status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	encrypted, err := i.totp.Encrypt(secret, user.UserId)
+	if err != nil {
+		return "", "", status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	// enrolling again replaces a pending secret the user did not confirm
+	_, err = i.repository.Update(ctx, user.UserId, map[entity.Path]interface{}{
+		entity.MfaPath: &entity.Mfa{Secret: encrypted},
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	i.logger.Info("totp enrollment started",
+		"method", "EnrollTotp",
+		"user-id", user.UserId)
+	return secret, uri, nil
+}
+
+func (i *iUserService) ConfirmTotp(ctx context.Context, token *authorization.TokenDetail, code string) (recoveryCodes []string, err error) {
+	i.logger.Info("ConfirmTotp request",
+		"method", "ConfirmTotp",
+		"user-id", token.UserId)
+
+	event := entity.AuditEvent{Type: entity.MfaEnableEvent}
+	defer func() { i.recordEvent(ctx, &event, err) }()
+
+	if code == "" {
+		return nil, status.Error(http.StatusBadRequest, "invalid value for code")
+	}
+
+	user, err := i.mfaOwner(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	event.Actor = user.UserId
+	event.Target = user.UserId
+
+	if user.Mfa == nil || user.Mfa.Secret == "" {
+		return nil, status.Error(http.StatusBadRequest, "no two-factor enrollment started")
+	}
+
+	if user.Mfa.Enabled {
+		return nil, status.Error(http.StatusConflict, "two-factor authentication is enabled already")
+	}
+
+	secret, err := i.totp.Decrypt(user.Mfa.Secret, user.UserId)
+	if err != nil {
+		return nil, status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	counter, ok := i.totp.Validate(secret, code, time.Now(), 0)
+	if !ok {
+		return nil, status.Error(http.StatusBadRequest, "invalid code")
+	}
+
+	recoveryCodes, hashes, err := newRecoveryCodes(i.recoveryCodes)
+	if err != nil {
+		return nil, status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	enabledAt := time.Now().UTC()
+	_, err = i.repository.Update(ctx, user.UserId, map[entity.Path]interface{}{
+		entity.MfaPath: &entity.Mfa{
+			Enabled:       true,
+			Secret:        user.Mfa.Secret,
+			LastCounter:   counter,
+			RecoveryCodes: hashes,
+			EnabledAt:     &enabledAt,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	i.logger.Info("two-factor authentication enabled",
+		"method", "ConfirmTotp",
+		"user-id", user.UserId)
+	return recoveryCodes, nil
+}
+
+func (i *iUserService) CompleteMfa(ctx context.Context, mfaToken string, code string) (token *authorization.TokenDetail, err error) {
+	i.logger.Info("CompleteMfa request",
+		"method", "CompleteMfa")
+
+	event := entity.AuditEvent{Type: entity.SignInEvent, Details: map[string]string{"mfa": "completed"}}
+	defer func() { i.recordEvent(ctx, &event, err) }()
+
+	if mfaToken == "" || code == "" {
+		return nil, status.Error(http.StatusBadRequest, "invalid value for mfa token or code")
+	}
+
+	tokenHash := hashCode(mfaToken)
+	challengeKey := mfaChallengePrefix + tokenHash
+	challenge, err := i.cache.Get(ctx, challengeKey)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, status.Error(http.StatusUnauthorized, "invalid or expired mfa token")
+		}
+		return nil, err
+	}
+
+	userId, signIn, err := parseChallenge(challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	attempts, err := i.cache.Increment(ctx, mfaChallengeAttemptsPrefix+tokenHash, i.mfaChallengeTTL)
+	if err != nil {
+		return nil, err
+	}
+	if attempts > int64(i.mfaChallengeAttempts) {
+		// the challenge is burnt, the user has to sign in again
+		_ = i.cache.Remove(ctx, challengeKey)
+		return nil, status.Error(http.StatusTooManyRequests, "too many wrong codes, sign in again")
+	}
+
+	// the codes given for challenges count against the account like passwords do
+	attempt, err := i.beginSecondFactor(ctx, signIn)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := i.repository.FindWithUserId(ctx, userId)
+	if err != nil {
+		return nil, i.abortLogin(ctx, attempt, err)
+	}
+
+	if user.DeletedAt != nil {
+		return nil, i.abortLogin(ctx, attempt, status.Error(http.StatusUnauthorized, "invalid or expired mfa token"))
+	}
+
+	event.Actor = userId
+	event.Target = userId
+
+	ok, err := i.verifySecondFactor(ctx, user, code)
+	if err != nil {
+		return nil, i.abortLogin(ctx, attempt, err)
+	}
+	if !ok {
+		err = i.loginFailed(ctx, attempt)
+		if err != nil {
+			return nil, err
+		}
+		return nil, status.Error(http.StatusUnauthorized, "invalid code")
+	}
+
+	// the challenge is single use, of concurrent completions only the one taking it goes on
+	taken, err := i.cache.Take(ctx, challengeKey)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, status.Error(http.StatusUnauthorized, "invalid or expired mfa token")
+		}
+		return nil, err
+	}
+	if taken != challenge {
+		return nil, status.Error(http.StatusUnauthorized, "invalid or expired mfa token")
+	}
+	_ = i.cache.Remove(ctx, mfaChallengeAttemptsPrefix+tokenHash)
+
+	err = i.loginSucceeded(ctx, attempt)
+	if err != nil {
+		return nil, err
+	}
+
+	// an admin may have locked the user out since the password was given
+	if user.Status != nil {
+		switch user.Status.Effective(time.Now()) {
+		case entity.Active:
+		case entity.Suspended, entity.Banned:
+			return nil, restrictionError(user.Status.Status, user.Status.Restriction)
+		default:
+			return nil, status.Error(http.StatusUnauthorized, "invalid or expired mfa token")
+		}
+	}
+
+	token, err = i.auth.CreateAccessToken(user.UserId, "", user.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	err = i.storeTokens(ctx, token, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	i.logger.Info("Signed in successfully",
+		"method", "CompleteMfa",
+		"user-id", user.UserId)
+	return token, nil
+}
+
+func (i *iUserService) DisableMfa(ctx context.Context, token *authorization.TokenDetail, password string, code string) (err error) {
+	i.logger.Info("DisableMfa request",
+		"method", "DisableMfa",
+		"user-id", token.UserId)
+
+	event := entity.AuditEvent{Type: entity.MfaDisableEvent}
+	defer func() { i.recordEvent(ctx, &event, err) }()
+
+	if password == "" || code == "" {
+		return status.Error(http.StatusBadRequest, "invalid value for password or code")
+	}
+
+	user, err := i.mfaOwner(ctx, token)
+	if err != nil {
+		return err
+	}
+	event.Actor = user.UserId
+	event.Target = user.UserId
+
+	if user.Mfa == nil || !user.Mfa.Enabled {
+		return status.Error(http.StatusBadRequest, "two-factor authentication is not enabled")
+	}
+
+	// a stolen access token alone must not be enough to drop the second factor
+	if !i.checkPassword(password, user.Password) {
+		return status.Error(http.StatusUnauthorized, "invalid password")
+	}
+
+	ok, err := i.verifySecondFactor(ctx, user, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return status.Error(http.StatusUnauthorized, "invalid code")
+	}
+
+	_, err = i.repository.Update(ctx, user.UserId, map[entity.Path]interface{}{
+		entity.MfaPath: nil,
+	})
+	if err != nil {
+		return err
+	}
+
+	i.logger.Info("two-factor authentication disabled",
+		"method", "DisableMfa",
+		"user-id", user.UserId)
+	return nil
+}
+
+// mfaChallenge is what a sign in of a user with a second factor returns instead of the tokens,
+// the attempt stays counted until CompleteMfa got the second factor
+func (i *iUserService) mfaChallenge(ctx context.Context, user *entity.User, attempt *loginAttempt) (challenge *authorization.TokenDetail, err error) {
+	mfaToken, err := randomToken(mfaChallengeLength)
+	if err != nil {
+		return nil, status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	value := strings.Join([]string{user.UserId, attempt.ipAddress, attempt.ipEvent, attempt.userName}, "|")
+	err = i.cache.Set(ctx, mfaChallengePrefix+hashCode(mfaToken), value, i.mfaChallengeTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	i.logger.Info("second factor required",
+		"method", "SignIn",
+		"user-id", user.UserId)
+	return &authorization.TokenDetail{
+		UserId:        user.UserId,
+		PrincipalType: authorization.UserPrincipal,
+		MfaToken:      mfaToken,
+		ExpireAt:      time.Now().Add(i.mfaChallengeTTL).Unix(),
+	}, nil
+}
+
+// parseChallenge splits a challenge value into the user id and the sign in attempt
+func parseChallenge(value string) (userId string, attempt *loginAttempt, err error) {
+	parts := strings.SplitN(value, "|", 4)
+	if len(parts) != 4 {
+		return "", nil, status.Error(http.StatusUnauthorized, "invalid or expired mfa token")
+	}
+
+	return parts[0], &loginAttempt{ipAddress: parts[1], ipEvent: parts[2], userName: parts[3]}, nil
+}
+
+// verifySecondFactor checks a TOTP code or, for anything else, a recovery code of
+// user. The accepted code is used up
+func (i *iUserService) verifySecondFactor(ctx context.Context, user *entity.User, code string) (ok bool, err error) {
+	if user.Mfa == nil || !user.Mfa.Enabled {
+		return false, nil
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == mfa.Digits && strings.Trim(code, "0123456789") == "" {
+		secret, err := i.totp.Decrypt(user.Mfa.Secret, user.UserId)
+		if err != nil {
+			return false, status.Error(http.StatusInternalServerError, err.Error())
+		}
+
+		counter, ok := i.totp.Validate(secret, code, time.Now(), user.Mfa.LastCounter)
+		if !ok {
+			return false, nil
+		}
+
+		// of concurrent uses of the code only the one storing its time step passes
+		return i.repository.UseMfaCounter(ctx, user.UserId, counter)
+	}
+
+	code = normalizeRecoveryCode(code)
+	for _, hash := range user.Mfa.RecoveryCodes {
+		if !matchesCode(code, hash) {
+			continue
+		}
+
+		ok, err := i.repository.UseRecoveryCode(ctx, user.UserId, hash)
+		if err != nil || !ok {
+			return false, err
+		}
+
+		i.logger.Info("recovery code used",
+			"method", "verifySecondFactor",
+			"user-id", user.UserId,
+			"remaining", len(user.Mfa.RecoveryCodes)-1)
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// mfaOwner returns the not deleted user of token
+func (i *iUserService) mfaOwner(ctx context.Context, token *authorization.TokenDetail) (user *entity.User, err error) {
+	token_, err := i.Validate(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if token_.PrincipalType != authorization.UserPrincipal {
+		return nil, status.Error(http.StatusForbidden, "only users have a second factor")
+	}
+
+	user, err = i.repository.FindWithUserId(ctx, token_.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.DeletedAt != nil {
+		return nil, status.Error(http.StatusNotFound, "user not found")
+	}
+
+	return user, nil
+}
+
+// newRecoveryCodes generates count codes shown once to the user, only their hashes are stored
+func newRecoveryCodes(count int) (codes []string, hashes []string, err error) {
+	buf := make([]byte, recoveryCodeLength)
+	for len(codes) < count {
+		_, err = rand.Read(buf)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(buf))
+		groups := make([]string, 0, len(code)/recoveryCodeGroup)
+		for start := 0; start < len(code); start += recoveryCodeGroup {
+			groups = append(groups, code[start:start+recoveryCodeGroup])
+		}
+		codes = append(codes, strings.Join(groups, "-"))
+		hashes = append(hashes, hashCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode drops the separators and case users may type a recovery code with
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.Replace(code, "-", "", -1)
+	return strings.Replace(code, " ", "", -1)
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/Juno-chat-app/user-service/domain/entity"
+	"github.com/Juno-chat-app/user-service/domain/model/authorization"
+	"github.com/Juno-chat-app/user-service/infra/sms"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// A pending mobile verification lives in the cache under the user id
+//
+//	mobile-code:<user-id>           hash of the code and the mobile it was sent to
+//	mobile-code-attempts:<user-id>  wrong entries of the code
+//	mobile-code-sent:<user-id>      set while no new code may be requested
+const (
+	mobileCodePrefix         string = "mobile-code:"
+	mobileCodeAttemptsPrefix string = "mobile-code-attempts:"
+	mobileCodeSentPrefix     string = "mobile-code-sent:"
+	mobileCodeLength         int    = 6
+
+	mobileCodeResendDelay = time.Minute
+
+	DefaultMobileCodeTTL          = 10 * time.Minute
+	DefaultMobileCodeAttempts int = 5
+)
+
+func (i *iUserService) RequestMobileVerification(ctx context.Context, token *authorization.TokenDetail) (err error) {
+	i.logger.Info("RequestMobileVerification request",
+		"method", "RequestMobileVerification",
+		"user-id", token.UserId)
+
+	user, err := i.mobileOwner(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	if user.ContactInfo.MobileVerified {
+		return status.Error(http.StatusConflict, "mobile is verified already")
+	}
+
+	// text messages cost money, users can not request them back to back
+	ok, err := i.cache.SetIfAbsent(ctx, mobileCodeSentPrefix+user.UserId, "1", mobileCodeResendDelay)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return status.Error(http.StatusTooManyRequests, "a code was sent recently, try again later")
+	}
+
+	code, err := randomDigits(mobileCodeLength)
+	if err != nil {
+		return status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	// a new code gets a fresh set of attempts
+	err = i.cache.Remove(ctx, mobileCodeAttemptsPrefix+user.UserId)
+	if err != nil {
+		return err
+	}
+
+	err = i.cache.Set(ctx, mobileCodePrefix+user.UserId, hashCode(code)+"|"+user.ContactInfo.Mobile, i.mobileCodeTTL)
+	if err != nil {
+		return err
+	}
+
+	message := sms.Message{
+		To:   user.ContactInfo.Mobile,
+		Body: fmt.Sprintf("Your Juno verification code: %s. It expires in %s.", code, i.mobileCodeTTL),
+	}
+
+	// a text message which was not sent does not hold the user back
+	err = i.smsSender.Send(ctx, &message)
+	if err != nil {
+		_ = i.cache.Remove(ctx, mobileCodeSentPrefix+user.UserId)
+		return err
+	}
+
+	i.logger.Info("mobile verification code sent",
+		"method", "RequestMobileVerification",
+		"user-id", user.UserId)
+	return nil
+}
+
+func (i *iUserService) VerifyMobile(ctx context.Context, token *authorization.TokenDetail, code string) (err error) {
+	i.logger.Info("VerifyMobile request",
+		"method", "VerifyMobile",
+		"user-id", token.UserId)
+
+	if code == "" {
+		return status.Error(http.StatusBadRequest, "invalid value for verification code")
+	}
+
+	user, err := i.mobileOwner(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	codeKey := mobileCodePrefix + user.UserId
+	pending, err := i.cache.Get(ctx, codeKey)
+	if err != nil {
+		if isNotFound(err) {
+			return status.Error(http.StatusBadRequest, "invalid or expired verification code")
+		}
+		return err
+	}
+
+	attempts, err := i.cache.Increment(ctx, mobileCodeAttemptsPrefix+user.UserId, i.mobileCodeTTL)
+	if err != nil {
+		return err
+	}
+	if attempts > int64(i.mobileCodeAttempts) {
+		// the code is burnt, the user has to request a new one
+		_ = i.cache.Remove(ctx, codeKey)
+		return status.Error(http.StatusTooManyRequests, "too many wrong codes, request a new one")
+	}
+
+	parts := strings.SplitN(pending, "|", 2)
+	if len(parts) != 2 || !matchesCode(code, parts[0]) {
+		return status.Error(http.StatusBadRequest, "invalid or expired verification code")
+	}
+
+	// the code only proves the number it was sent to
+	if parts[1] != user.ContactInfo.Mobile {
+		_ = i.cache.Remove(ctx, codeKey)
+		return status.Error(http.StatusBadRequest, "invalid or expired verification code")
+	}
+
+	err = i.cache.Remove(ctx, codeKey)
+	if err != nil {
+		return err
+	}
+	_ = i.cache.Remove(ctx, mobileCodeAttemptsPrefix+user.UserId)
+
+	_, err = i.repository.Update(ctx, user.UserId, map[entity.Path]interface{}{
+		entity.MobileVerifiedPath: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	i.logger.Info("mobile verified",
+		"method", "VerifyMobile",
+		"user-id", user.UserId)
+	return nil
+}
+
+// mobileOwner returns the user of token, it must have a mobile
+func (i *iUserService) mobileOwner(ctx context.Context, token *authorization.TokenDetail) (user *entity.User, err error) {
+	token_, err := i.Validate(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if token_.PrincipalType != authorization.UserPrincipal {
+		return nil, status.Error(http.StatusForbidden, "only users have a mobile")
+	}
+
+	user, err = i.repository.FindWithUserId(ctx, token_.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.ContactInfo == nil || user.ContactInfo.Mobile == "" {
+		return nil, status.Error(http.StatusBadRequest, "user has no mobile")
+	}
+
+	return user, nil
+}
@@ -1,15 +1,47 @@
 package services
 
 import (
-	"golang.org/x/crypto/bcrypt"
+	"context"
+	"github.com/Juno-chat-app/user-service/domain/entity"
 )
 
-func generatePasswordOneWayHash(password string) (hash string, err error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
-	return string(bytes), err
+func (i *iUserService) hashPassword(password string) (hash string, err error) {
+	return i.passwordHasher.Hash(password)
 }
 
-func checkPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+func (i *iUserService) checkPassword(password, hash string) bool {
+	ok, err := i.passwordHasher.Verify(password, hash)
+	if err != nil {
+		i.logger.Error("got error on verifying password hash",
+			"method", "checkPassword",
+			"err", err)
+		return false
+	}
+
+	return ok
+}
+
+// rehashPassword upgrades the hash of user to the current algorithm and parameters,
+// password must have been verified. Failures are only logged, the old hash keeps working
+func (i *iUserService) rehashPassword(ctx context.Context, user *entity.User, password string) {
+	if !i.passwordHasher.NeedsRehash(user.Password) {
+		return
+	}
+
+	hash, err := i.hashPassword(password)
+	if err == nil {
+		err = i.repository.UpdatePassword(ctx, user.UserId, hash)
+	}
+	if err != nil {
+		i.logger.Error("got error on upgrading password hash",
+			"method", "rehashPassword",
+			"user-id", user.UserId,
+			"err", err)
+		return
+	}
+
+	user.Password = hash
+	i.logger.Info("password hash upgraded",
+		"method", "rehashPassword",
+		"user-id", user.UserId)
 }
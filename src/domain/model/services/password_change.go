@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"github.com/Juno-chat-app/user-service/domain/entity"
+	"github.com/Juno-chat-app/user-service/domain/model/authorization"
+	"google.golang.org/grpc/status"
+	"net/http"
+)
+
+func (i *iUserService) ChangePassword(ctx context.Context, token *authorization.TokenDetail, oldPassword string, newPassword string) (err error) {
+	i.logger.Info("ChangePassword request",
+		"method", "ChangePassword",
+		"user-id", token.UserId)
+
+	event := entity.AuditEvent{Type: entity.PasswordChangeEvent}
+	defer func() { i.recordEvent(ctx, &event, err) }()
+
+	token_, err := i.Validate(ctx, token)
+	if err != nil {
+		return err
+	}
+	event.Actor = token_.UserId
+	event.Target = token_.UserId
+
+	if token_.PrincipalType != authorization.UserPrincipal {
+		return status.Error(http.StatusForbidden, "only users have a password")
+	}
+
+	if oldPassword == "" || newPassword == "" {
+		return status.Error(http.StatusBadRequest, "invalid value for password")
+	}
+
+	if oldPassword == newPassword {
+		return status.Error(http.StatusBadRequest, "new password must differ from the old one")
+	}
+
+	user, err := i.repository.FindWithUserId(ctx, token_.UserId)
+	if err != nil {
+		return err
+	}
+
+	if user.DeletedAt != nil {
+		return status.Error(http.StatusNotFound, "user not found")
+	}
+
+	if !i.checkPassword(oldPassword, user.Password) {
+		return status.Error(http.StatusUnauthorized, "invalid password")
+	}
+
+	err = i.passwordPolicy.Check(newPassword, user)
+	if err != nil {
+		return err
+	}
+
+	hashPass, err := i.hashPassword(newPassword)
+	if err != nil {
+		return status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	err = i.repository.UpdatePassword(ctx, user.UserId, hashPass)
+	if err != nil {
+		return err
+	}
+
+	// every other session is signed out, the one of the caller is kept so
+	// changing the password does not sign them out
+	err = i.revokeUserSessions(ctx, user.UserId, token_.FamilyId)
+	if err != nil {
+		return err
+	}
+
+	i.logger.Info("password changed",
+		"method", "ChangePassword",
+		"user-id", user.UserId)
+	return nil
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/Juno-chat-app/user-service/domain/entity"
+	"github.com/Juno-chat-app/user-service/infra/mailer"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// A reset token lives in the cache under the hash of its value, a user has at
+// most one token and requesting a new one drops the previous
+//
+//	password-reset:<token-hash>    the user id the token resets
+//	password-reset-user:<user-id>  the token hash of the user
+const (
+	passwordResetPrefix     string = "password-reset:"
+	passwordResetUserPrefix string = "password-reset-user:"
+	passwordResetLength     int    = 24
+
+	DefaultPasswordResetTTL = 30 * time.Minute
+)
+
+func passwordResetKey(tokenHash string) string {
+	return passwordResetPrefix + tokenHash
+}
+
+func passwordResetUserKey(userId string) string {
+	return passwordResetUserPrefix + userId
+}
+
+func (i *iUserService) RequestPasswordReset(ctx context.Context, login string) (err error) {
+	i.logger.Info("RequestPasswordReset request",
+		"method", "RequestPasswordReset",
+		"login", login)
+
+	if login == "" {
+		return status.Error(http.StatusBadRequest, "invalid value for email or user-name")
+	}
+
+	// unknown users get the same answer so accounts can not be enumerated
+	user, err := i.findWithLogin(ctx, login)
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	if user.Status == nil || user.Status.Effective(time.Now()) != entity.Active {
+		return nil
+	}
+
+	// an unverified email may belong to someone else, it must not receive reset tokens
+	if user.ContactInfo == nil || !user.ContactInfo.EmailVerified {
+		return nil
+	}
+
+	token, err := randomToken(passwordResetLength)
+	if err != nil {
+		return status.Error(http.StatusInternalServerError, err.Error())
+	}
+	tokenHash := hashCode(token)
+
+	previous, err := i.cache.Get(ctx, passwordResetUserKey(user.UserId))
+	if err != nil && !isNotFound(err) {
+		return err
+	} else if err == nil {
+		err = i.cache.Remove(ctx, passwordResetKey(previous))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = i.cache.Set(ctx, passwordResetKey(tokenHash), user.UserId, i.passwordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	err = i.cache.Set(ctx, passwordResetUserKey(user.UserId), tokenHash, i.passwordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	message := mailer.Message{
+		To:      user.ContactInfo.Email,
+		Subject: "Reset your Juno password",
+		Body: fmt.Sprintf("Hi %s,\r\n\r\nyour password reset code: %s\r\n\r\nThe code expires in %s. "+
+			"If you did not ask for a new password you can ignore this mail.", user.UserName, token, i.passwordResetTTL),
+	}
+
+	// a failed mail must not tell callers the account exists, the user can ask again
+	err = i.mailer.Send(ctx, &message)
+	if err != nil {
+		i.logger.Error("got error on sending password reset mail",
+			"method", "RequestPasswordReset",
+			"user-id", user.UserId,
+			"err", err)
+		return nil
+	}
+
+	i.logger.Info("password reset requested",
+		"method", "RequestPasswordReset",
+		"user-id", user.UserId)
+	return nil
+}
+
+func (i *iUserService) ResetPassword(ctx context.Context, token string, password string) (err error) {
+	i.logger.Info("ResetPassword request",
+		"method", "ResetPassword")
+
+	event := entity.AuditEvent{Type: entity.PasswordResetEvent}
+	defer func() { i.recordEvent(ctx, &event, err) }()
+
+	if token == "" || password == "" {
+		return status.Error(http.StatusBadRequest, "invalid value for reset token or password")
+	}
+
+	tokenHash := hashCode(token)
+	userId, err := i.cache.Get(ctx, passwordResetKey(tokenHash))
+	if err != nil {
+		if isNotFound(err) {
+			return status.Error(http.StatusBadRequest, "invalid or expired reset token")
+		}
+		return err
+	}
+
+	event.Actor = userId
+	event.Target = userId
+
+	user, err := i.repository.FindWithUserId(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	// checked before the token is used up so the user can pick another password
+	err = i.passwordPolicy.Check(password, user)
+	if err != nil {
+		return err
+	}
+
+	// the token is single use, of concurrent resets only the one taking it goes on
+	taken, err := i.cache.Take(ctx, passwordResetKey(tokenHash))
+	if err != nil {
+		if isNotFound(err) {
+			return status.Error(http.StatusBadRequest, "invalid or expired reset token")
+		}
+		return err
+	}
+	if taken != userId {
+		return status.Error(http.StatusBadRequest, "invalid or expired reset token")
+	}
+
+	err = i.cache.Remove(ctx, passwordResetUserKey(userId))
+	if err != nil {
+		return err
+	}
+
+	hashPass, err := i.hashPassword(password)
+	if err != nil {
+		return status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	err = i.repository.UpdatePassword(ctx, userId, hashPass)
+	if err != nil {
+		return err
+	}
+
+	// whoever knew the old password is signed out everywhere
+	err = i.revokeUserSessions(ctx, userId, "")
+	if err != nil {
+		return err
+	}
+
+	i.logger.Info("password reset completed",
+		"method", "ResetPassword",
+		"user-id", userId)
+	return nil
+}
+
+// findWithLogin finds an active user by email or user-name
+func (i *iUserService) findWithLogin(ctx context.Context, login string) (user *entity.User, err error) {
+	if strings.Contains(login, "@") {
+		return i.repository.FindWithEmail(ctx, login)
+	}
+
+	return i.repository.FindWithUserName(ctx, login)
+}
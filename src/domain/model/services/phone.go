@@ -0,0 +1,40 @@
+package services
+
+import (
+	"google.golang.org/grpc/status"
+	"net/http"
+	"strings"
+)
+
+// normalizeMobile brings mobile numbers to the E.164 format, "+" followed by the
+// country code and the subscriber number. Numbers must carry their country code,
+// either with "+" or the international "00" prefix, the usual separators are dropped
+func normalizeMobile(mobile string) (string, error) {
+	number := strings.TrimSpace(mobile)
+	if strings.HasPrefix(number, "00") {
+		number = "+" + number[2:]
+	}
+
+	if !strings.HasPrefix(number, "+") {
+		return "", status.Error(http.StatusBadRequest, "mobile number must start with the country code")
+	}
+
+	digits := make([]byte, 0, len(number))
+	for _, c := range number[1:] {
+		switch {
+		case c >= '0' && c <= '9':
+			digits = append(digits, byte(c))
+		case c == ' ' || c == '-' || c == '.' || c == '(' || c == ')':
+			continue
+		default:
+			return "", status.Error(http.StatusBadRequest, "invalid mobile number")
+		}
+	}
+
+	// country codes never start with 0 and E.164 numbers have at most 15 digits
+	if len(digits) < 8 || len(digits) > 15 || digits[0] == '0' {
+		return "", status.Error(http.StatusBadRequest, "invalid mobile number")
+	}
+
+	return "+" + string(digits), nil
+}
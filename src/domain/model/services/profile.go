@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/Juno-chat-app/user-service/domain/entity"
+	"github.com/Juno-chat-app/user-service/domain/model/authorization"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"strings"
+)
+
+// profilePaths are the paths UpdateProfile accepts in its field mask
+var profilePaths = map[entity.Path]func(user *entity.User) interface{}{
+	entity.UserNamePath:    func(user *entity.User) interface{} { return user.UserName },
+	entity.EmailPath:       func(user *entity.User) interface{} { return user.ContactInfo.Email },
+	entity.MobilePath:      func(user *entity.User) interface{} { return user.ContactInfo.Mobile },
+	entity.PhonePath:       func(user *entity.User) interface{} { return user.ContactInfo.Phone },
+	entity.DisplayNamePath: func(user *entity.User) interface{} { return user.Profile.DisplayName },
+	entity.BioPath:         func(user *entity.User) interface{} { return user.Profile.Bio },
+	entity.AvatarUrlPath:   func(user *entity.User) interface{} { return user.Profile.AvatarUrl },
+	entity.LocalePath:      func(user *entity.User) interface{} { return user.Profile.Locale },
+}
+
+func (i *iUserService) UpdateProfile(ctx context.Context, token *authorization.TokenDetail, user *entity.User, mask []entity.Path) (user_ *entity.User, err error) {
+	i.logger.Info("UpdateProfile request",
+		"method", "UpdateProfile",
+		"user-id", token.UserId,
+		"mask", mask)
+
+	event := entity.AuditEvent{Type: entity.ProfileChangeEvent, Details: map[string]string{"mask": joinPaths(mask)}}
+	defer func() { i.recordEvent(ctx, &event, err) }()
+
+	token_, err := i.Validate(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	event.Actor = token_.UserId
+	event.Target = token_.UserId
+
+	if token_.PrincipalType != authorization.UserPrincipal {
+		return nil, status.Error(http.StatusForbidden, "only users have a profile")
+	}
+
+	if len(mask) == 0 {
+		return nil, status.Error(http.StatusBadRequest, "empty update mask")
+	}
+
+	if user.ContactInfo == nil {
+		user.ContactInfo = &entity.ContactInfo{}
+	}
+	if user.Profile == nil {
+		user.Profile = &entity.Profile{}
+	}
+
+	fields := make(map[entity.Path]interface{}, len(mask))
+	for _, path := range mask {
+		value, ok := profilePaths[path]
+		if !ok {
+			return nil, status.Error(http.StatusBadRequest, fmt.Sprintf("path %s can not be updated", path))
+		}
+		fields[path] = value(user)
+	}
+
+	if value, ok := fields[entity.UserNamePath]; ok && value == "" {
+		return nil, status.Error(http.StatusBadRequest, "invalid value for user-name")
+	}
+	if value, ok := fields[entity.EmailPath]; ok && value == "" {
+		return nil, status.Error(http.StatusBadRequest, "invalid value for email")
+	}
+	if value, ok := fields[entity.UserNamePath]; ok {
+		fields[entity.UserNamePath], err = entity.NormalizeUserName(value.(string))
+		if err != nil {
+			return nil, status.Error(http.StatusBadRequest, err.Error())
+		}
+	}
+	if value, ok := fields[entity.EmailPath]; ok {
+		fields[entity.EmailPath], err = entity.NormalizeEmail(value.(string))
+		if err != nil {
+			return nil, status.Error(http.StatusBadRequest, err.Error())
+		}
+	}
+	if value, ok := fields[entity.MobilePath]; ok && value != "" {
+		fields[entity.MobilePath], err = normalizeMobile(value.(string))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	current, err := i.repository.FindWithUserId(ctx, token_.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	// a new email only replaces the current one once the code mailed to it is confirmed
+	if value, ok := fields[entity.EmailPath]; ok && current.ContactInfo != nil &&
+		entity.CanonicalEmail(value.(string)) != entity.CanonicalEmail(current.ContactInfo.Email) {
+		delete(fields, entity.EmailPath)
+
+		owner, err := i.repository.FindWithEmail(ctx, value.(string))
+		if err == nil && owner.UserId != current.UserId {
+			return nil, status.Error(http.StatusConflict, "email is taken")
+		} else if err != nil && !isNotFound(err) {
+			return nil, err
+		}
+
+		err = i.requestEmailChange(ctx, current, value.(string))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// a changed mobile has to be verified again
+	if value, ok := fields[entity.MobilePath]; ok && current.ContactInfo != nil && value != current.ContactInfo.Mobile {
+		fields[entity.MobileVerifiedPath] = false
+	}
+
+	// a user without profile has none to set paths in, the whole profile is set instead
+	if current.Profile == nil {
+		profile := entity.Profile{}
+		set := false
+		for path, value := range fields {
+			switch path {
+			case entity.DisplayNamePath:
+				profile.DisplayName, set = value.(string), true
+			case entity.BioPath:
+				profile.Bio, set = value.(string), true
+			case entity.AvatarUrlPath:
+				profile.AvatarUrl, set = value.(string), true
+			case entity.LocalePath:
+				profile.Locale, set = value.(string), true
+			default:
+				continue
+			}
+			delete(fields, path)
+		}
+		if set {
+			fields[entity.ProfilePath] = &profile
+		}
+	}
+
+	if len(fields) == 0 {
+		current.Password = "--secret--"
+		return current, nil
+	}
+
+	user_, err = i.repository.Update(ctx, token_.UserId, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	i.logger.Info("profile updated",
+		"method", "UpdateProfile",
+		"user-id", user_.UserId,
+		"mask", mask)
+
+	user_.Password = "--secret--"
+	return user_, nil
+}
+
+func joinPaths(paths []entity.Path) string {
+	names := make([]string, 0, len(paths))
+	for _, path := range paths {
+		names = append(names, string(path))
+	}
+
+	return strings.Join(names, ",")
+}
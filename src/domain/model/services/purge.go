@@ -0,0 +1,42 @@
+package services
+
+import (
+	"context"
+	"github.com/Juno-chat-app/user-service/domain/repository/mongo"
+	"github.com/Juno-chat-app/user-service/infra/logger"
+	"time"
+)
+
+const (
+	DefaultPurgeInterval = time.Hour
+)
+
+// IPurgeJob permanently deletes the accounts whose deletion grace period is over
+type IPurgeJob interface {
+	Purge(ctx context.Context) (count int64, err error)
+	// Run purges every interval until ctx is done
+	Run(ctx context.Context)
+}
+
+func NewPurgeJob(repo mongo.IUserRepository, gracePeriod time.Duration, interval time.Duration, logger logger.ILogger) IPurgeJob {
+	if gracePeriod <= 0 {
+		gracePeriod = DefaultDeletionGracePeriod
+	}
+	if interval <= 0 {
+		interval = DefaultPurgeInterval
+	}
+
+	logger.Info("initial purge-job",
+		"method", "NewPurgeJob",
+		"grace-period", gracePeriod,
+		"interval", interval)
+
+	job := iPurgeJob{
+		repository:  repo,
+		logger:      logger,
+		gracePeriod: gracePeriod,
+		interval:    interval,
+	}
+
+	return &job
+}
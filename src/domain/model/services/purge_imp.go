@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+	"github.com/Juno-chat-app/user-service/domain/repository/mongo"
+	"github.com/Juno-chat-app/user-service/infra/logger"
+	"time"
+)
+
+type iPurgeJob struct {
+	repository  mongo.IUserRepository
+	logger      logger.ILogger
+	gracePeriod time.Duration
+	interval    time.Duration
+}
+
+func (p *iPurgeJob) Purge(ctx context.Context) (count int64, err error) {
+	now := time.Now().UTC()
+
+	count, err = p.repository.Purge(ctx, now, now.Add(-p.gracePeriod))
+	if err != nil {
+		return 0, err
+	}
+
+	if count != 0 {
+		p.logger.Info("deleted accounts purged",
+			"method", "Purge",
+			"count", count)
+	}
+	return count, nil
+}
+
+func (p *iPurgeJob) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := p.Purge(ctx)
+		if err != nil {
+			p.logger.Error("got error on purging deleted accounts",
+				"method", "Run",
+				"err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
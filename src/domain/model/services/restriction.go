@@ -0,0 +1,226 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Juno-chat-app/user-service/domain/entity"
+	"github.com/Juno-chat-app/user-service/domain/model/authorization"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"time"
+)
+
+// The tokens of restricted users are revoked, the cache remembers why for as
+// long as those tokens could be presented
+//
+//	restricted-user:<user-id>  the status and restriction of the user
+const (
+	restrictedUserPrefix string = "restricted-user:"
+)
+
+type restrictionMarker struct {
+	Status      entity.Status       `json:"status"`
+	Restriction *entity.Restriction `json:"restriction"`
+}
+
+func restrictedUserKey(userId string) string {
+	return restrictedUserPrefix + userId
+}
+
+func (i *iUserService) RestrictUser(ctx context.Context, token *authorization.TokenDetail, userId string, status_ entity.Status,
+	reason string, endAt *time.Time) (user *entity.User, err error) {
+	i.logger.Info("RestrictUser request",
+		"method", "RestrictUser",
+		"user-id", token.UserId,
+		"target-user-id", userId,
+		"status", status_)
+
+	event := entity.AuditEvent{Type: entity.RestrictUserEvent, Target: userId,
+		Details: map[string]string{"status": string(status_), "reason": reason}}
+	if endAt != nil {
+		event.Details["end-at"] = endAt.UTC().Format(time.RFC3339)
+	}
+	defer func() { i.recordEvent(ctx, &event, err) }()
+
+	token_, err := i.authorizeAdmin(ctx, token)
+	if token_ != nil {
+		event.Actor = token_.UserId
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if status_ != entity.Suspended && status_ != entity.Banned {
+		return nil, status.Error(http.StatusBadRequest, "status must be suspended or banned")
+	}
+
+	if reason == "" {
+		return nil, status.Error(http.StatusBadRequest, "invalid value for reason")
+	}
+
+	now := time.Now().UTC()
+	if endAt != nil && (status_ == entity.Banned || !endAt.After(now)) {
+		return nil, status.Error(http.StatusBadRequest, "only suspensions end and their end must be in the future")
+	}
+
+	if userId == token_.UserId {
+		return nil, status.Error(http.StatusBadRequest, "admins can not restrict themselves")
+	}
+
+	user, err = i.repository.FindWithUserId(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.DeletedAt != nil || user.Status == nil {
+		return nil, status.Error(http.StatusNotFound, "user not found")
+	}
+
+	switch user.Status.Status {
+	case entity.Active, entity.Suspended, entity.Banned:
+	default:
+		return nil, status.Error(http.StatusConflict, "only active users can be restricted")
+	}
+
+	user.Status = &entity.UserStatus{
+		Status:    status_,
+		UpdatedAt: &now,
+		Restriction: &entity.Restriction{
+			Reason:  reason,
+			Actor:   token_.UserId,
+			StartAt: &now,
+			EndAt:   endAt,
+		},
+	}
+
+	err = i.repository.UpdateStatus(ctx, user.UserId, user.Status)
+	if err != nil {
+		return nil, err
+	}
+
+	// the marker goes first so the revoked tokens are already explained
+	value, err := json.Marshal(restrictionMarker{Status: status_, Restriction: user.Status.Restriction})
+	if err != nil {
+		return nil, status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	err = i.cache.Set(ctx, restrictedUserKey(user.UserId), string(value), i.accessTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	err = i.revokeUserSessions(ctx, user.UserId, "")
+	if err != nil {
+		return nil, err
+	}
+
+	i.logger.Info("user restricted",
+		"method", "RestrictUser",
+		"user-id", user.UserId,
+		"status", status_,
+		"actor", token_.UserId,
+		"end-at", endAt)
+
+	user.Password = "--secret--"
+	return user, nil
+}
+
+func (i *iUserService) LiftRestriction(ctx context.Context, token *authorization.TokenDetail, userId string) (user *entity.User, err error) {
+	i.logger.Info("LiftRestriction request",
+		"method", "LiftRestriction",
+		"user-id", token.UserId,
+		"target-user-id", userId)
+
+	event := entity.AuditEvent{Type: entity.LiftRestrictionEvent, Target: userId}
+	defer func() { i.recordEvent(ctx, &event, err) }()
+
+	token_, err := i.authorizeAdmin(ctx, token)
+	if token_ != nil {
+		event.Actor = token_.UserId
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user, err = i.repository.FindWithUserId(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.DeletedAt != nil || user.Status == nil {
+		return nil, status.Error(http.StatusNotFound, "user not found")
+	}
+
+	if user.Status.Status != entity.Suspended && user.Status.Status != entity.Banned {
+		return nil, status.Error(http.StatusConflict, "user is not suspended or banned")
+	}
+
+	err = i.liftRestriction(ctx, user, token_.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	user.Password = "--secret--"
+	return user, nil
+}
+
+// liftRestriction makes user active again, the actor of expired suspensions is empty
+func (i *iUserService) liftRestriction(ctx context.Context, user *entity.User, actor string) (err error) {
+	now := time.Now().UTC()
+	user.Status = &entity.UserStatus{
+		Status:    entity.Active,
+		UpdatedAt: &now,
+	}
+
+	err = i.repository.UpdateStatus(ctx, user.UserId, user.Status)
+	if err != nil {
+		return err
+	}
+
+	err = i.cache.Remove(ctx, restrictedUserKey(user.UserId))
+	if err != nil {
+		return err
+	}
+
+	i.logger.Info("user restriction lifted",
+		"method", "liftRestriction",
+		"user-id", user.UserId,
+		"actor", actor)
+	return nil
+}
+
+// restrictionError explains to restricted users why they are locked out
+func restrictionError(status_ entity.Status, restriction *entity.Restriction) error {
+	reason := ""
+	if restriction != nil && restriction.Reason != "" {
+		reason = ": " + restriction.Reason
+	}
+
+	if status_ == entity.Suspended && restriction != nil && restriction.EndAt != nil {
+		return status.Error(http.StatusForbidden, fmt.Sprintf("account suspended until %s%s",
+			restriction.EndAt.Format(time.RFC3339), reason))
+	}
+
+	return status.Error(http.StatusForbidden, fmt.Sprintf("account %s%s", status_, reason))
+}
+
+// explainRevoked returns the restriction error when the tokens of userId were revoked
+// because of a restriction and err otherwise
+func (i *iUserService) explainRevoked(ctx context.Context, userId string, err error) error {
+	value, cacheErr := i.cache.Get(ctx, restrictedUserKey(userId))
+	if cacheErr != nil {
+		return err
+	}
+
+	marker := restrictionMarker{}
+	if json.Unmarshal([]byte(value), &marker) != nil {
+		return err
+	}
+
+	if marker.Restriction != nil && marker.Restriction.EndAt != nil && !time.Now().Before(*marker.Restriction.EndAt) {
+		return err
+	}
+
+	return restrictionError(marker.Status, marker.Restriction)
+}
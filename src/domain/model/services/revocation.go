@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"github.com/Juno-chat-app/user-service/domain/repository/redis"
+	"github.com/Juno-chat-app/user-service/infra/logger"
+	"sync"
+	"time"
+)
+
+const (
+	StatefulValidation  string = "stateful"
+	StatelessValidation string = "stateless"
+
+	DefaultRevocationReload = time.Minute
+)
+
+// IRevocationList holds the ids of access tokens revoked before they expired, it
+// lets Validate honour sign outs without a cache lookup per token. Revocations
+// are kept in the cache and mirrored in memory, other instances learn about
+// them through pub/sub and a periodic reload
+type IRevocationList interface {
+	// Revoke takes effect locally at once, when the cache is unavailable Run
+	// writes the revocation with its next reload instead of failing
+	Revoke(ctx context.Context, jti string, expireAt int64) (err error)
+	IsRevoked(jti string) (revoked bool)
+	// Run keeps the in-memory list in sync with the cache until ctx is done
+	Run(ctx context.Context)
+}
+
+// NewRevocationList creates the revocation list, ttl must cover the whole lifetime
+// of an access token including the validation leeway. Revocations are kept until
+// leeway after the expiry of their token, as long as it is still accepted
+func NewRevocationList(cache redis.ICache, ttl time.Duration, leeway time.Duration, reload time.Duration, logger logger.ILogger) IRevocationList {
+	if reload <= 0 {
+		reload = DefaultRevocationReload
+	}
+
+	logger.Info("initial revocation-list",
+		"method", "NewRevocationList",
+		"ttl", ttl,
+		"leeway", leeway,
+		"reload", reload)
+
+	list := iRevocationList{
+		cache:   cache,
+		logger:  logger,
+		ttl:     ttl,
+		leeway:  int64(leeway / time.Second),
+		reload:  reload,
+		revoked: map[string]int64{},
+		pending: map[string]int64{},
+		sync:    sync.RWMutex{},
+	}
+
+	return &list
+}
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/Juno-chat-app/user-service/domain/repository/redis"
+	"github.com/Juno-chat-app/user-service/infra/logger"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the revoked token ids live in one set, every member is "<jti>|<expire-at>" so
+// expired entries can be dropped without looking at the token
+const (
+	revokedTokensKey     string = "revoked-tokens"
+	revokedTokensChannel string = "revoked-tokens"
+	revocationSeparator  string = "|"
+)
+
+type iRevocationList struct {
+	cache  redis.ICache
+	logger logger.ILogger
+	ttl    time.Duration
+	leeway int64 // seconds
+	reload time.Duration
+	// pruned is when expired entries were last dropped from revoked
+	pruned  time.Time
+	revoked map[string]int64
+	// pending are the revocations which did not reach the cache yet
+	pending map[string]int64
+	sync    sync.RWMutex
+}
+
+func (r *iRevocationList) Revoke(ctx context.Context, jti string, expireAt int64) (err error) {
+	if r.expired(expireAt, time.Now().Unix()) {
+		return nil
+	}
+
+	// the local instance honours the revocation even if the cache is unavailable,
+	// Run writes it to the cache once the cache is back
+	r.add(jti, expireAt)
+
+	err = r.publish(ctx, jti, expireAt)
+	if err != nil {
+		r.logger.Error("got error on publishing revocation, it is retried later",
+			"method", "Revoke",
+			"jti", jti,
+			"err", err)
+
+		r.sync.Lock()
+		r.pending[jti] = expireAt
+		r.sync.Unlock()
+	}
+
+	return nil
+}
+
+func (r *iRevocationList) publish(ctx context.Context, jti string, expireAt int64) (err error) {
+	member := revocationMember(jti, expireAt)
+	err = r.cache.AddMember(ctx, revokedTokensKey, member, r.ttl)
+	if err != nil {
+		return err
+	}
+
+	return r.cache.Publish(ctx, revokedTokensChannel, member)
+}
+
+// retry publishes the pending revocations, the ones failing again stay pending
+func (r *iRevocationList) retry(ctx context.Context) {
+	r.sync.Lock()
+	pending := r.pending
+	r.pending = map[string]int64{}
+	r.sync.Unlock()
+
+	now := time.Now().Unix()
+	for jti, expireAt := range pending {
+		if r.expired(expireAt, now) {
+			continue
+		}
+
+		err := r.publish(ctx, jti, expireAt)
+		if err != nil {
+			r.sync.Lock()
+			r.pending[jti] = expireAt
+			r.sync.Unlock()
+		}
+	}
+}
+
+func (r *iRevocationList) IsRevoked(jti string) (revoked bool) {
+	r.sync.RLock()
+	defer r.sync.RUnlock()
+
+	expireAt, ok := r.revoked[jti]
+	return ok && !r.expired(expireAt, time.Now().Unix())
+}
+
+func (r *iRevocationList) Run(ctx context.Context) {
+	var messages <-chan string
+	ticker := time.NewTicker(r.reload)
+	defer ticker.Stop()
+
+	for {
+		// a lost subscription is restored with the next reload, revocations
+		// published meanwhile are picked up by the reload itself
+		if messages == nil {
+			var err error
+			messages, err = r.cache.Subscribe(ctx, revokedTokensChannel)
+			if err != nil {
+				r.logger.Error("got error on revocation subscription",
+					"method", "Run",
+					"err", err)
+			}
+
+			r.load(ctx)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.retry(ctx)
+			// without subscription the next round subscribes and loads
+			if messages != nil {
+				r.load(ctx)
+			}
+		case member, ok := <-messages:
+			if !ok {
+				messages = nil
+				continue
+			}
+
+			jti, expireAt, err := parseRevocationMember(member)
+			if err != nil {
+				r.logger.Error("got invalid revocation message",
+					"method", "Run",
+					"message", member)
+				continue
+			}
+			r.add(jti, expireAt)
+		}
+	}
+}
+
+// add keeps jti revoked in memory, once per reload interval the expired entries
+// are dropped so the list does not grow without Run
+func (r *iRevocationList) add(jti string, expireAt int64) {
+	r.sync.Lock()
+	defer r.sync.Unlock()
+
+	r.revoked[jti] = expireAt
+
+	now := time.Now()
+	if now.Sub(r.pruned) < r.reload {
+		return
+	}
+
+	r.pruned = now
+	for jti, expireAt := range r.revoked {
+		if r.expired(expireAt, now.Unix()) {
+			delete(r.revoked, jti)
+		}
+	}
+}
+
+// expired tells a token of expireAt is no longer accepted at now, not even with the leeway
+func (r *iRevocationList) expired(expireAt int64, now int64) bool {
+	return expireAt+r.leeway <= now
+}
+
+// load replaces the in-memory list with the cached one, expired entries are
+// dropped from both. On errors the current list is kept
+func (r *iRevocationList) load(ctx context.Context) {
+	members, err := r.cache.Members(ctx, revokedTokensKey)
+	if err != nil {
+		r.logger.Error("got error on loading revocation list",
+			"method", "load",
+			"err", err)
+		return
+	}
+
+	now := time.Now().Unix()
+	revoked := make(map[string]int64, len(members))
+	for _, member := range members {
+		jti, expireAt, err := parseRevocationMember(member)
+		if err != nil || r.expired(expireAt, now) {
+			err = r.cache.RemoveMember(ctx, revokedTokensKey, member)
+			if err != nil {
+				r.logger.Error("got error on pruning revocation list",
+					"method", "load",
+					"err", err)
+			}
+			continue
+		}
+
+		revoked[jti] = expireAt
+	}
+
+	r.sync.Lock()
+	defer r.sync.Unlock()
+
+	// revocations of this instance which did not reach the cache are kept
+	for jti, expireAt := range r.revoked {
+		if _, ok := revoked[jti]; !ok && !r.expired(expireAt, now) {
+			revoked[jti] = expireAt
+		}
+	}
+	r.revoked = revoked
+}
+
+func revocationMember(jti string, expireAt int64) string {
+	return jti + revocationSeparator + strconv.FormatInt(expireAt, 10)
+}
+
+func parseRevocationMember(member string) (jti string, expireAt int64, err error) {
+	index := strings.LastIndex(member, revocationSeparator)
+	if index <= 0 {
+		return "", 0, fmt.Errorf("invalid revocation entry %q", member)
+	}
+
+	expireAt, err = strconv.ParseInt(member[index+1:], 10, 64)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return member[:index], expireAt, nil
+}
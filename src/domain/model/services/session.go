@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Juno-chat-app/user-service/domain/entity"
+	"github.com/Juno-chat-app/user-service/domain/model/authorization"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"time"
+)
+
+// A session is the refresh token family of one sign in, only the latest token
+// pair of it is usable and presenting an already used refresh token revokes the
+// whole session. The cache holds
+//
+//	session:<session-id>        the session document
+//	user-sessions:<user-id>     the set of session ids of a user
+//	used-refresh:<refresh-uuid> the refresh tokens which were already rotated
+const (
+	sessionKeyPrefix     string = "session:"
+	userSessionsPrefix   string = "user-sessions:"
+	usedRefreshKeyPrefix string = "used-refresh:"
+)
+
+func sessionKey(sessionId string) string {
+	return sessionKeyPrefix + sessionId
+}
+
+func userSessionsKey(userId string) string {
+	return userSessionsPrefix + userId
+}
+
+func usedRefreshKey(refreshUUid string) string {
+	return usedRefreshKeyPrefix + refreshUUid
+}
+
+// expiration converts the unix expire time of a token to a cache ttl
+func expiration(expireAt int64) time.Duration {
+	return time.Until(time.Unix(expireAt, 0))
+}
+
+func isNotFound(err error) bool {
+	stat, ok := status.FromError(err)
+	return ok && stat.Code() == http.StatusNotFound
+}
+
+// storeTokens makes token the current pair of session, a nil session starts a new one
+func (i *iUserService) storeTokens(ctx context.Context, token *authorization.TokenDetail, session *entity.Session) (err error) {
+	now := time.Now().UTC()
+	client := clientInfoFrom(ctx)
+
+	if session == nil {
+		session = &entity.Session{
+			SessionId: token.FamilyId,
+			UserId:    token.UserId,
+			CreatedAt: &now,
+		}
+	}
+	session.AccessUUid = token.AccessUUid
+	session.AccessExpireAt = token.ExpireAt
+	session.RefreshUUid = token.RefreshUUid
+	session.LastRefreshAt = &now
+	if client.IpAddress != "" {
+		session.IpAddress = client.IpAddress
+	}
+	if client.UserAgent != "" {
+		session.UserAgent = client.UserAgent
+	}
+
+	err = i.cache.Set(ctx, token.AccessUUid, token.AccessToken, expiration(token.ExpireAt))
+	if err != nil {
+		return err
+	}
+
+	err = i.cache.Set(ctx, token.RefreshUUid, token.RefreshToke, expiration(token.RefreshExpireAt))
+	if err != nil {
+		return err
+	}
+
+	value, err := json.Marshal(session)
+	if err != nil {
+		return status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	err = i.cache.Set(ctx, sessionKey(session.SessionId), string(value), expiration(token.RefreshExpireAt))
+	if err != nil {
+		return err
+	}
+
+	return i.cache.AddMember(ctx, userSessionsKey(session.UserId), session.SessionId, expiration(token.RefreshExpireAt))
+}
+
+func (i *iUserService) loadSession(ctx context.Context, sessionId string) (session *entity.Session, err error) {
+	value, err := i.cache.Get(ctx, sessionKey(sessionId))
+	if err != nil {
+		return nil, err
+	}
+
+	session = &entity.Session{}
+	err = json.Unmarshal([]byte(value), session)
+	if err != nil {
+		return nil, status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	return session, nil
+}
+
+// userSessions loads the live sessions of the user, expired ones are dropped from the index
+func (i *iUserService) userSessions(ctx context.Context, userId string) (sessions []*entity.Session, err error) {
+	ids, err := i.cache.Members(ctx, userSessionsKey(userId))
+	if err != nil {
+		return nil, err
+	}
+
+	sessions = make([]*entity.Session, 0, len(ids))
+	for _, id := range ids {
+		session, err := i.loadSession(ctx, id)
+		if err != nil {
+			if !isNotFound(err) {
+				return nil, err
+			}
+
+			err = i.cache.RemoveMember(ctx, userSessionsKey(userId), id)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return sessions, nil
+}
+
+// revokeSession removes the current token pair of the session, which makes
+// every token ever issued in it unusable
+func (i *iUserService) revokeSession(ctx context.Context, sessionId string) (err error) {
+	session, err := i.loadSession(ctx, sessionId)
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	err = i.revokeAccess(ctx, session.AccessUUid, session.AccessExpireAt)
+	if err != nil {
+		return err
+	}
+
+	err = i.cache.Remove(ctx, session.RefreshUUid)
+	if err != nil {
+		return err
+	}
+
+	err = i.cache.Remove(ctx, sessionKey(sessionId))
+	if err != nil {
+		return err
+	}
+
+	return i.cache.RemoveMember(ctx, userSessionsKey(session.UserId), sessionId)
+}
+
+// revokeAccess records the access token in the revocation list and removes it
+// from the cache, stateless validation does not look at the cache
+func (i *iUserService) revokeAccess(ctx context.Context, accessUUid string, expireAt int64) (err error) {
+	err = i.revocations.Revoke(ctx, accessUUid, expireAt)
+	if err != nil {
+		return err
+	}
+
+	return i.cache.Remove(ctx, accessUUid)
+}
+
+// revokeUserSessions signs the user out everywhere except from the keep session
+func (i *iUserService) revokeUserSessions(ctx context.Context, userId string, keep string) (err error) {
+	sessions, err := i.userSessions(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if session.SessionId == keep {
+			continue
+		}
+
+		err = i.revokeSession(ctx, session.SessionId)
+		if err != nil {
+			return err
+		}
+	}
+
+	i.logger.Info("user sessions revoked",
+		"method", "revokeUserSessions",
+		"user-id", userId,
+		"kept-session", keep)
+	return nil
+}
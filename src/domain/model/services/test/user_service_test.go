@@ -5,12 +5,25 @@ import (
 	"github.com/Juno-chat-app/user-service/config"
 	"github.com/Juno-chat-app/user-service/domain/entity"
 	"github.com/Juno-chat-app/user-service/domain/model/authorization"
+	"github.com/Juno-chat-app/user-service/domain/model/mfa"
+	"github.com/Juno-chat-app/user-service/domain/model/password"
 	"github.com/Juno-chat-app/user-service/domain/model/services"
 	"github.com/Juno-chat-app/user-service/domain/repository/mongo"
 	"github.com/Juno-chat-app/user-service/domain/repository/redis"
 	"github.com/Juno-chat-app/user-service/infra/logger"
+	"github.com/Juno-chat-app/user-service/infra/mailer"
+	"github.com/Juno-chat-app/user-service/infra/sms"
 	"github.com/stretchr/testify/require"
+	"github.com/twinj/uuid"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io/ioutil"
+	"net/http"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 )
@@ -21,11 +34,22 @@ var (
 	cache   redis.ICache
 	auth    authorization.IJwtHandler
 	repo    mongo.IUserRepository
+	clients mongo.IClientRepository
+	audits  mongo.IAuditRepository
 	service services.IUserService
+
+	revocations services.IRevocationList
+	mail        mailer.IMailer
+	smsSender   sms.ISmsSender
+	policy      password.IPasswordPolicy
+	hasher      password.IPasswordHasher
+	totp        mfa.ITotpHandler
+	outbox      string
 )
 
 func TestMain(m *testing.M) {
-	conf, err := config.LoadConfiguration("./user_service_test_config.yml")
+	var err error
+	conf, err = config.LoadConfiguration("./user_service_test_config.yml")
 	if err != nil {
 		os.Exit(1)
 	}
@@ -36,11 +60,37 @@ func TestMain(m *testing.M) {
 	}
 
 	repo = mongo.NewUserRepository(conf.CQRSConfig.PersistConfig, log)
+	clients = mongo.NewClientRepository(conf.CQRSConfig.PersistConfig, log)
+	audits = mongo.NewAuditRepository(conf.CQRSConfig.PersistConfig, log)
 	cache = redis.NewCache(conf.CQRSConfig.CacheConfig.Host, conf.CQRSConfig.CacheConfig.Port, conf.CQRSConfig.CacheConfig.Password, conf.CQRSConfig.CacheConfig.Db, conf.CQRSConfig.CacheConfig.Retry, log)
-	auth = authorization.NewJwtHandler(int64(conf.AuthConfig.AccessTTL*time.Minute), int64(conf.AuthConfig.RefreshTTL*time.Hour), log)
-	service = services.NewUserService(log, repo, cache, auth)
+	auth, err = authorization.NewJwtHandler(conf.AuthConfig, log)
+	if err != nil {
+		os.Exit(1)
+	}
+	revocations = services.NewRevocationList(cache, conf.AuthConfig.AccessTTL*time.Minute+conf.AuthConfig.Leeway*time.Second,
+		conf.AuthConfig.Leeway*time.Second, conf.AuthConfig.RevocationReload*time.Second, log)
+	outbox, err = ioutil.TempDir("", "user-service-outbox")
+	if err != nil {
+		os.Exit(1)
+	}
+	mail = mailer.NewOutboxMailer(outbox, conf.MailConfig.From, log)
+	smsSender = sms.NewOutboxSmsSender(outbox, log)
+	policy, err = password.NewPasswordPolicy(conf.PasswordConfig, log)
+	if err != nil {
+		os.Exit(1)
+	}
+	hasher, err = password.NewPasswordHasher(conf.HashConfig, log)
+	if err != nil {
+		os.Exit(1)
+	}
+	totp, err = mfa.NewTotpHandler(conf.MfaConfig, log)
+	if err != nil {
+		os.Exit(1)
+	}
+	service = services.NewUserService(conf, log, repo, clients, cache, auth, revocations, mail, smsSender, policy, hasher, totp, audits)
 
 	code := m.Run()
+	_ = os.RemoveAll(outbox)
 	os.Exit(code)
 }
 
@@ -52,7 +102,34 @@ func Test_User_Service(t *testing.T) {
 	user.Password = "test"
 
 	require.Nil(t, err)
-	require.Equal(t, signUpResult.Status.Status, entity.Active)
+	require.Equal(t, signUpResult.Status.Status, entity.Pending)
+
+	// pending users can not sign in until they verify their email
+	_, err = service.SignIn(ctx, user)
+	require.NotNil(t, err)
+
+	err = service.ActivateAccount(ctx, user.ContactInfo.Email, "invalid-code")
+	require.NotNil(t, err)
+
+	err = service.ResendActivation(ctx, user.ContactInfo.Email)
+	require.Nil(t, err)
+	code := activationCode(t, user.ContactInfo.Email)
+
+	// activation mails are not sent back to back
+	err = service.ResendActivation(ctx, user.ContactInfo.Email)
+	require.Equal(t, codes.Code(http.StatusTooManyRequests), status.Code(err))
+	details := status.Convert(err).Details()
+	require.Len(t, details, 1)
+	require.IsType(t, &errdetails.RetryInfo{}, details[0])
+	err = cache.Remove(ctx, "activation-sent:"+user.ContactInfo.Email)
+	require.Nil(t, err)
+
+	err = service.ActivateAccount(ctx, user.ContactInfo.Email, code)
+	require.Nil(t, err)
+
+	// the code is single use
+	err = service.ActivateAccount(ctx, user.ContactInfo.Email, code)
+	require.NotNil(t, err)
 
 	signInResult, err := service.SignIn(ctx, user)
 	require.Nil(t, err)
@@ -65,11 +142,1117 @@ func Test_User_Service(t *testing.T) {
 	require.Nil(t, err)
 	require.NotEqual(t, signInResult.AccessUUid, refreshResult.AccessUUid)
 	require.NotEqual(t, signInResult.AccessToken, refreshResult.AccessToken)
+	require.NotEqual(t, signInResult.RefreshUUid, refreshResult.RefreshUUid)
+	require.Equal(t, signInResult.FamilyId, refreshResult.FamilyId)
+
+	_, err = service.Validate(ctx, refreshResult)
+	require.Nil(t, err)
+
+	// replaying the rotated refresh token revokes the whole family
+	_, err = service.RefreshToken(ctx, signInResult)
+	require.NotNil(t, err)
+
+	_, err = service.Validate(ctx, refreshResult)
+	require.NotNil(t, err)
+
+	_, err = service.RefreshToken(ctx, refreshResult)
+	require.NotNil(t, err)
+
+	signInResult, err = service.SignIn(ctx, user)
+	require.Nil(t, err)
+
+	// only clients with the introspect scope may introspect
+	_, err = service.Introspect(ctx, signInResult, signInResult.AccessToken, "")
+	require.Equal(t, codes.Code(http.StatusForbidden), status.Code(err))
+
+	caller := introspector(t, ctx)
+	introspection, err := service.Introspect(ctx, caller, signInResult.AccessToken, "")
+	require.Nil(t, err)
+	require.True(t, introspection.Active)
+	require.Equal(t, services.AccessTokenType, introspection.TokenType)
+	require.Equal(t, signInResult.FamilyId, introspection.SessionId)
+
+	introspection, err = service.Introspect(ctx, caller, signInResult.RefreshToke, services.RefreshTokenType)
+	require.Nil(t, err)
+	require.True(t, introspection.Active)
+	require.Equal(t, services.RefreshTokenType, introspection.TokenType)
+
+	err = service.SignOut(ctx, signInResult)
+	require.Nil(t, err)
+
+	_, err = service.Validate(ctx, signInResult)
+	require.NotNil(t, err)
+
+	_, err = service.RefreshToken(ctx, signInResult)
+	require.NotNil(t, err)
+
+	introspection, err = service.Introspect(ctx, caller, signInResult.AccessToken, "")
+	require.Nil(t, err)
+	require.False(t, introspection.Active)
+
+	_, err = repo.Remove(ctx, user)
+	require.Nil(t, err)
+}
+
+func Test_User_Service_Sessions(t *testing.T) {
+	ctx := services.WithClientInfo(context.Background(), services.ClientInfo{
+		IpAddress: "127.0.0.1",
+		UserAgent: "user-service-test",
+	})
+	user := NewUser()
+	user.UserName = "test-sessions"
+	user.ContactInfo.Email = "test-sessions@juno.com"
+
+	_, err := service.SignUp(ctx, user)
+	require.Nil(t, err)
+	user.Password = "test"
+	activate(t, ctx, user)
+
+	first, err := service.SignIn(ctx, user)
+	require.Nil(t, err)
+	second, err := service.SignIn(ctx, user)
+	require.Nil(t, err)
+
+	sessions, err := service.ListSessions(ctx, first)
+	require.Nil(t, err)
+	require.Len(t, sessions, 2)
+	require.Equal(t, "127.0.0.1", sessions[0].IpAddress)
+	require.Equal(t, "user-service-test", sessions[0].UserAgent)
+
+	err = service.RevokeSession(ctx, first, second.FamilyId)
+	require.Nil(t, err)
+
+	_, err = service.Validate(ctx, second)
+	require.NotNil(t, err)
+
+	sessions, err = service.ListSessions(ctx, first)
+	require.Nil(t, err)
+	require.Len(t, sessions, 1)
+
+	err = service.RevokeAllSessions(ctx, first)
+	require.Nil(t, err)
+
+	_, err = service.Validate(ctx, first)
+	require.NotNil(t, err)
+
+	_, err = repo.Remove(ctx, user)
+	require.Nil(t, err)
+}
+
+func Test_User_Service_PasswordReset(t *testing.T) {
+	ctx := context.Background()
+	user := NewUser()
+	user.UserName = "test-reset"
+	user.ContactInfo.Email = "test-reset@juno.com"
+
+	_, err := service.SignUp(ctx, user)
+	require.Nil(t, err)
+	user.Password = "test"
+	activate(t, ctx, user)
+
+	token, err := service.SignIn(ctx, user)
+	require.Nil(t, err)
+
+	// unknown users look the same as known ones
+	err = service.RequestPasswordReset(ctx, "unknown@juno.com")
+	require.Nil(t, err)
+
+	err = service.RequestPasswordReset(ctx, user.UserName)
+	require.Nil(t, err)
+	resetToken := mailCode(t, user.ContactInfo.Email, "password reset code")
+
+	err = service.ResetPassword(ctx, resetToken, "new-password")
+	require.Nil(t, err)
+
+	err = service.ResetPassword(ctx, resetToken, "another-password")
+	require.NotNil(t, err)
+
+	_, err = service.Validate(ctx, token)
+	require.NotNil(t, err)
+
+	_, err = service.SignIn(ctx, user)
+	require.NotNil(t, err)
+
+	user.Password = "new-password"
+	_, err = service.SignIn(ctx, user)
+	require.Nil(t, err)
 
 	_, err = repo.Remove(ctx, user)
 	require.Nil(t, err)
 }
 
+func Test_User_Service_ChangePassword(t *testing.T) {
+	ctx := context.Background()
+	user := NewUser()
+	user.UserName = "test-change"
+	user.ContactInfo.Email = "test-change@juno.com"
+
+	_, err := service.SignUp(ctx, user)
+	require.Nil(t, err)
+	user.Password = "test"
+	activate(t, ctx, user)
+
+	current, err := service.SignIn(ctx, user)
+	require.Nil(t, err)
+	other, err := service.SignIn(ctx, user)
+	require.Nil(t, err)
+
+	err = service.ChangePassword(ctx, current, "wrong-password", "new-password")
+	require.NotNil(t, err)
+
+	err = service.ChangePassword(ctx, current, "test", "new-password")
+	require.Nil(t, err)
+
+	// only the other sessions are signed out
+	_, err = service.Validate(ctx, current)
+	require.Nil(t, err)
+	_, err = service.Validate(ctx, other)
+	require.NotNil(t, err)
+
+	user.Password = "new-password"
+	_, err = service.SignIn(ctx, user)
+	require.Nil(t, err)
+
+	_, err = repo.Remove(ctx, user)
+	require.Nil(t, err)
+}
+
+func Test_User_Service_GetUser(t *testing.T) {
+	ctx := context.Background()
+	user := NewUser()
+	user.UserName = "test-lookup"
+	user.ContactInfo.Email = "test-lookup@juno.com"
+	user.ContactInfo.Mobile = "+4915112345678"
+
+	viewer := NewUser()
+	viewer.UserName = "test-viewer"
+	viewer.ContactInfo.Email = "test-viewer@juno.com"
+
+	for _, u := range []*entity.User{user, viewer} {
+		_, err := service.SignUp(ctx, u)
+		require.Nil(t, err)
+		u.Password = "test"
+		activate(t, ctx, u)
+	}
+
+	viewerToken, err := service.SignIn(ctx, viewer)
+	require.Nil(t, err)
+	userToken, err := service.SignIn(ctx, user)
+	require.Nil(t, err)
+
+	// others only see the channel they searched by
+	found, err := service.GetUser(ctx, viewerToken, services.UserQuery{Email: user.ContactInfo.Email})
+	require.Nil(t, err)
+	require.Equal(t, user.UserId, found.UserId)
+	require.Equal(t, user.ContactInfo.Email, found.ContactInfo.Email)
+	require.Equal(t, "", found.ContactInfo.Mobile)
+	require.Equal(t, "", found.Password)
+
+	// an unverified mobile does not find anyone
+	_, err = service.GetUser(ctx, viewerToken, services.UserQuery{Mobile: user.ContactInfo.Mobile})
+	require.Equal(t, codes.Code(http.StatusNotFound), status.Code(err))
+
+	err = service.RequestMobileVerification(ctx, userToken)
+	require.Nil(t, err)
+	err = service.VerifyMobile(ctx, userToken, smsCode(t, user.ContactInfo.Mobile))
+	require.Nil(t, err)
+
+	found, err = service.GetUser(ctx, viewerToken, services.UserQuery{Mobile: user.ContactInfo.Mobile})
+	require.Nil(t, err)
+	require.Equal(t, "", found.ContactInfo.Email)
+	require.Equal(t, user.ContactInfo.Mobile, found.ContactInfo.Mobile)
+
+	found, err = service.GetUser(ctx, userToken, services.UserQuery{UserId: user.UserId})
+	require.Nil(t, err)
+	require.Equal(t, user.ContactInfo.Email, found.ContactInfo.Email)
+	require.Equal(t, user.ContactInfo.Mobile, found.ContactInfo.Mobile)
+
+	_, err = service.GetUser(ctx, viewerToken, services.UserQuery{Email: "unknown@juno.com"})
+	require.NotNil(t, err)
+
+	for _, u := range []*entity.User{user, viewer} {
+		_, err = repo.Remove(ctx, u)
+		require.Nil(t, err)
+	}
+}
+
+func Test_User_Service_UpdateProfile(t *testing.T) {
+	ctx := context.Background()
+	user := NewUser()
+	user.UserName = "test-profile"
+	user.ContactInfo.Email = "test-profile@juno.com"
+
+	other := NewUser()
+	other.UserName = "test-profile-other"
+	other.ContactInfo.Email = "test-profile-other@juno.com"
+
+	for _, u := range []*entity.User{user, other} {
+		_, err := service.SignUp(ctx, u)
+		require.Nil(t, err)
+		u.Password = "test"
+		activate(t, ctx, u)
+	}
+
+	token, err := service.SignIn(ctx, user)
+	require.Nil(t, err)
+
+	update := entity.User{
+		UserName:    "ignored",
+		ContactInfo: &entity.ContactInfo{Phone: "+493012345678"},
+		Profile:     &entity.Profile{DisplayName: "Test Profile"},
+	}
+	updated, err := service.UpdateProfile(ctx, token, &update, []entity.Path{entity.PhonePath, entity.DisplayNamePath})
+	require.Nil(t, err)
+	require.Equal(t, user.UserName, updated.UserName)
+	require.Equal(t, "+493012345678", updated.ContactInfo.Phone)
+	require.Equal(t, "Test Profile", updated.Profile.DisplayName)
+	require.Equal(t, user.ContactInfo.Email, updated.ContactInfo.Email)
+
+	// user names and emails stay unique
+	update = entity.User{UserName: other.UserName}
+	_, err = service.UpdateProfile(ctx, token, &update, []entity.Path{entity.UserNamePath})
+	require.NotNil(t, err)
+
+	_, err = service.UpdateProfile(ctx, token, &update, []entity.Path{entity.PasswordPath})
+	require.NotNil(t, err)
+
+	// a new email is only set once the code mailed to it is confirmed
+	update = entity.User{ContactInfo: &entity.ContactInfo{Email: "test-profile-new@juno.com"}}
+	updated, err = service.UpdateProfile(ctx, token, &update, []entity.Path{entity.EmailPath})
+	require.Nil(t, err)
+	require.Equal(t, user.ContactInfo.Email, updated.ContactInfo.Email)
+
+	_, err = service.ConfirmEmailChange(ctx, token, "wrong-code")
+	require.NotNil(t, err)
+
+	code := mailCode(t, "test-profile-new@juno.com", "email change code")
+	updated, err = service.ConfirmEmailChange(ctx, token, code)
+	require.Nil(t, err)
+	require.Equal(t, "test-profile-new@juno.com", updated.ContactInfo.Email)
+	require.True(t, updated.ContactInfo.EmailVerified)
+
+	// the code is single use
+	_, err = service.ConfirmEmailChange(ctx, token, code)
+	require.NotNil(t, err)
+
+	for _, u := range []*entity.User{user, other} {
+		_, err = repo.Remove(ctx, u)
+		require.Nil(t, err)
+	}
+}
+
+func Test_User_Service_DeleteAccount(t *testing.T) {
+	ctx := context.Background()
+	user := NewUser()
+	user.UserName = "test-delete"
+	user.ContactInfo.Email = "test-delete@juno.com"
+
+	_, err := service.SignUp(ctx, user)
+	require.Nil(t, err)
+	user.Password = "test"
+	activate(t, ctx, user)
+
+	token, err := service.SignIn(ctx, user)
+	require.Nil(t, err)
+
+	err = service.DeleteAccount(ctx, token, "wrong-password")
+	require.NotNil(t, err)
+
+	err = service.DeleteAccount(ctx, token, "test")
+	require.Nil(t, err)
+
+	_, err = service.Validate(ctx, token)
+	require.NotNil(t, err)
+
+	_, err = service.SignIn(ctx, user)
+	require.NotNil(t, err)
+
+	deleted, err := repo.FindWithUserId(ctx, user.UserId)
+	require.Nil(t, err)
+	require.NotNil(t, deleted.DeletedAt)
+	require.NotNil(t, deleted.PurgeAt)
+}
+
+func Test_User_Service_RestoreAccount(t *testing.T) {
+	ctx := context.Background()
+	user := NewUser()
+	user.UserName = "test-restore"
+	user.ContactInfo.Email = "test-restore@juno.com"
+
+	_, err := service.SignUp(ctx, user)
+	require.Nil(t, err)
+	user.Password = "test"
+	activate(t, ctx, user)
+
+	token, err := service.SignIn(ctx, user)
+	require.Nil(t, err)
+
+	err = service.DeleteAccount(ctx, token, "test")
+	require.Nil(t, err)
+
+	// unknown emails are answered the same way
+	err = service.RequestAccountRestore(ctx, "nobody@juno.com")
+	require.Nil(t, err)
+
+	err = service.RequestAccountRestore(ctx, user.ContactInfo.Email)
+	require.Nil(t, err)
+	code := mailCode(t, user.ContactInfo.Email, "account restore code")
+
+	_, err = service.RestoreAccount(ctx, "wrong-code")
+	require.NotNil(t, err)
+
+	restored, err := service.RestoreAccount(ctx, code)
+	require.Nil(t, err)
+	require.Equal(t, user.UserId, restored.UserId)
+
+	// restore codes are single use
+	_, err = service.RestoreAccount(ctx, code)
+	require.NotNil(t, err)
+
+	_, err = service.SignIn(ctx, user)
+	require.Nil(t, err)
+
+	found, err := repo.FindWithUserId(ctx, user.UserId)
+	require.Nil(t, err)
+	require.Nil(t, found.DeletedAt)
+	require.Nil(t, found.PurgeAt)
+
+	_, err = repo.Remove(ctx, user)
+	require.Nil(t, err)
+}
+
+func Test_User_Service_VerifyMobile(t *testing.T) {
+	ctx := context.Background()
+	user := NewUser()
+	user.UserName = "test-mobile"
+	user.ContactInfo.Email = "test-mobile@juno.com"
+	user.ContactInfo.Mobile = "0049 (151) 234-56789"
+
+	_, err := service.SignUp(ctx, user)
+	require.Nil(t, err)
+	require.Equal(t, "+4915123456789", user.ContactInfo.Mobile)
+	user.Password = "test"
+	activate(t, ctx, user)
+
+	token, err := service.SignIn(ctx, user)
+	require.Nil(t, err)
+
+	err = service.RequestMobileVerification(ctx, token)
+	require.Nil(t, err)
+	code := smsCode(t, user.ContactInfo.Mobile)
+
+	// codes can not be requested back to back
+	err = service.RequestMobileVerification(ctx, token)
+	require.NotNil(t, err)
+
+	err = service.VerifyMobile(ctx, token, "wrong")
+	require.NotNil(t, err)
+
+	err = service.VerifyMobile(ctx, token, code)
+	require.Nil(t, err)
+
+	found, err := repo.FindWithUserId(ctx, user.UserId)
+	require.Nil(t, err)
+	require.True(t, found.ContactInfo.EmailVerified)
+	require.True(t, found.ContactInfo.MobileVerified)
+
+	// a new number has to be verified again
+	user.ContactInfo.Mobile = "+49 151 98765432"
+	updated, err := service.UpdateProfile(ctx, token, user, []entity.Path{entity.MobilePath})
+	require.Nil(t, err)
+	require.Equal(t, "+4915198765432", updated.ContactInfo.Mobile)
+	require.False(t, updated.ContactInfo.MobileVerified)
+
+	_, err = repo.Remove(ctx, user)
+	require.Nil(t, err)
+}
+
+func Test_User_Service_VerifyMobile_Attempts(t *testing.T) {
+	ctx := context.Background()
+	user := NewUser()
+	user.UserName = "test-mobile-attempts"
+	user.ContactInfo.Email = "test-mobile-attempts@juno.com"
+	user.ContactInfo.Mobile = "+49 151 11122233"
+
+	_, err := service.SignUp(ctx, user)
+	require.Nil(t, err)
+	user.Password = "test"
+	activate(t, ctx, user)
+
+	token, err := service.SignIn(ctx, user)
+	require.Nil(t, err)
+
+	err = service.RequestMobileVerification(ctx, token)
+	require.Nil(t, err)
+	code := smsCode(t, user.ContactInfo.Mobile)
+
+	for i := 0; i < conf.AccountConfig.MobileCodeAttempts; i++ {
+		err = service.VerifyMobile(ctx, token, "wrong")
+		require.NotNil(t, err)
+	}
+
+	// the code is burnt after too many wrong entries
+	err = service.VerifyMobile(ctx, token, code)
+	require.NotNil(t, err)
+
+	_, err = repo.Remove(ctx, user)
+	require.Nil(t, err)
+}
+
+func Test_User_Service_Stateless(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	statelessConf := *conf
+	statelessConf.AuthConfig.ValidationMode = services.StatelessValidation
+	stateless := services.NewUserService(&statelessConf, log, repo, clients, cache, auth, revocations, mail, smsSender, policy, hasher, totp, audits)
+
+	// a second instance learns about revocations through the cache
+	replica := services.NewRevocationList(cache, time.Minute, conf.AuthConfig.Leeway*time.Second, time.Second, log)
+	go replica.Run(ctx)
+
+	user := NewUser()
+	user.UserName = "test-stateless"
+	user.ContactInfo.Email = "test-stateless@juno.com"
+
+	_, err := stateless.SignUp(ctx, user)
+	require.Nil(t, err)
+	user.Password = "test"
+	activate(t, ctx, user)
+
+	token, err := stateless.SignIn(ctx, user)
+	require.Nil(t, err)
+
+	_, err = stateless.Validate(ctx, token)
+	require.Nil(t, err)
+
+	err = stateless.SignOut(ctx, token)
+	require.Nil(t, err)
+
+	_, err = stateless.Validate(ctx, token)
+	require.NotNil(t, err)
+	require.Eventually(t, func() bool {
+		return replica.IsRevoked(token.AccessUUid)
+	}, 3*time.Second, 100*time.Millisecond)
+
+	_, err = repo.Remove(ctx, user)
+	require.Nil(t, err)
+}
+
+func Test_RevocationList_Leeway(t *testing.T) {
+	ctx := context.Background()
+	leeway := 30 * time.Second
+	list := services.NewRevocationList(cache, time.Minute+leeway, leeway, time.Second, log)
+
+	// expired tokens are accepted for the leeway, so their revocations must hold as long
+	now := time.Now()
+	err := list.Revoke(ctx, "leeway-jti", now.Add(-10*time.Second).Unix())
+	require.Nil(t, err)
+	require.True(t, list.IsRevoked("leeway-jti"))
+
+	err = list.Revoke(ctx, "expired-jti", now.Add(-leeway-time.Second).Unix())
+	require.Nil(t, err)
+	require.False(t, list.IsRevoked("expired-jti"))
+
+	// a replica loading the cache keeps the revocation within the leeway as well
+	replica := services.NewRevocationList(cache, time.Minute+leeway, leeway, time.Second, log)
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go replica.Run(runCtx)
+	require.Eventually(t, func() bool {
+		return replica.IsRevoked("leeway-jti")
+	}, 3*time.Second, 100*time.Millisecond)
+}
+
+func Test_User_Service_ClientCredentials(t *testing.T) {
+	ctx := context.Background()
+	admin := NewUser()
+	admin.UserName = "test-admin"
+	admin.ContactInfo.Email = "test-admin@juno.com"
+	admin.Permissions = []*entity.Permission{&services.AdminPermission}
+
+	_, err := service.SignUp(ctx, admin)
+	require.Nil(t, err)
+	admin.Password = "test"
+	activate(t, ctx, admin)
+
+	adminToken, err := service.SignIn(ctx, admin)
+	require.Nil(t, err)
+
+	client, secret, err := service.RegisterClient(ctx, adminToken, &entity.Client{
+		Name:   "message-service",
+		Scopes: []string{"user:read", "media:read"},
+	})
+	require.Nil(t, err)
+	require.NotEqual(t, "", secret)
+
+	token, err := service.ClientToken(ctx, client.ClientId, secret, []string{"user:read"})
+	require.Nil(t, err)
+
+	validated, err := service.Validate(ctx, token)
+	require.Nil(t, err)
+	require.Equal(t, authorization.ClientPrincipal, validated.PrincipalType)
+	require.Equal(t, []string{"user:read"}, validated.Scopes)
+
+	_, err = service.ClientToken(ctx, client.ClientId, secret, []string{"user:write"})
+	require.NotNil(t, err)
+
+	_, err = service.ClientToken(ctx, client.ClientId, "wrong-secret", nil)
+	require.NotNil(t, err)
+
+	// only admins register clients
+	_, _, err = service.RegisterClient(ctx, token, &entity.Client{Name: "media-service"})
+	require.NotNil(t, err)
+
+	_, err = repo.Remove(ctx, admin)
+	require.Nil(t, err)
+}
+
+func Test_User_Service_RestrictUser(t *testing.T) {
+	ctx := context.Background()
+	admin := NewUser()
+	admin.UserName = "test-moderator"
+	admin.ContactInfo.Email = "test-moderator@juno.com"
+	admin.Permissions = []*entity.Permission{&services.AdminPermission}
+
+	_, err := service.SignUp(ctx, admin)
+	require.Nil(t, err)
+	admin.Password = "test"
+	activate(t, ctx, admin)
+
+	user := NewUser()
+	user.UserName = "test-restricted"
+	user.ContactInfo.Email = "test-restricted@juno.com"
+
+	_, err = service.SignUp(ctx, user)
+	require.Nil(t, err)
+	user.Password = "test"
+	activate(t, ctx, user)
+
+	adminToken, err := service.SignIn(ctx, admin)
+	require.Nil(t, err)
+	token, err := service.SignIn(ctx, user)
+	require.Nil(t, err)
+
+	// only admins restrict users
+	_, err = service.RestrictUser(ctx, token, admin.UserId, entity.Suspended, "spam", nil)
+	require.NotNil(t, err)
+
+	_, err = service.RestrictUser(ctx, adminToken, user.UserId, entity.Banned, "", nil)
+	require.NotNil(t, err)
+
+	endAt := time.Now().UTC().Add(time.Hour)
+	restricted, err := service.RestrictUser(ctx, adminToken, user.UserId, entity.Suspended, "spam", &endAt)
+	require.Nil(t, err)
+	require.Equal(t, entity.Suspended, restricted.Status.Status)
+	require.Equal(t, admin.UserId, restricted.Status.Restriction.Actor)
+
+	_, err = service.Validate(ctx, token)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "suspended")
+
+	_, err = service.SignIn(ctx, user)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "spam")
+
+	_, err = service.LiftRestriction(ctx, adminToken, user.UserId)
+	require.Nil(t, err)
+
+	token, err = service.SignIn(ctx, user)
+	require.Nil(t, err)
+
+	// expired suspensions lift with the next sign in
+	past := time.Now().UTC().Add(-time.Minute)
+	err = repo.UpdateStatus(ctx, user.UserId, &entity.UserStatus{
+		Status:      entity.Suspended,
+		UpdatedAt:   &past,
+		Restriction: &entity.Restriction{Reason: "spam", StartAt: &past, EndAt: &past},
+	})
+	require.Nil(t, err)
+
+	_, err = service.SignIn(ctx, user)
+	require.Nil(t, err)
+
+	found, err := repo.FindWithUserId(ctx, user.UserId)
+	require.Nil(t, err)
+	require.Equal(t, entity.Active, found.Status.Status)
+
+	_, err = repo.Remove(ctx, user)
+	require.Nil(t, err)
+	_, err = repo.Remove(ctx, admin)
+	require.Nil(t, err)
+}
+
+func Test_User_Service_SignIn_Throttle(t *testing.T) {
+	ctx := services.WithClientInfo(context.Background(), services.ClientInfo{IpAddress: "10.0.0.21"})
+
+	throttledConf := *conf
+	throttledConf.LoginConfig = config.LoginConfig{
+		Window:           1,
+		DelayAfter:       1,
+		MaxDelay:         1,
+		LockoutThreshold: 4,
+		IpThreshold:      100,
+		LockoutDuration:  1,
+	}
+	throttled := services.NewUserService(&throttledConf, log, repo, clients, cache, auth, revocations, mail, smsSender, policy, hasher, totp, audits)
+
+	user := NewUser()
+	user.UserName = "test-throttle"
+	user.ContactInfo.Email = "test-throttle@juno.com"
+
+	_, err := throttled.SignUp(ctx, user)
+	require.Nil(t, err)
+	activate(t, ctx, user)
+
+	wrong := *user
+	wrong.Password = "wrong"
+	right := *user
+	right.Password = "test"
+
+	_, err = throttled.SignIn(ctx, &wrong)
+	require.Equal(t, codes.Code(http.StatusUnauthorized), status.Code(err))
+	_, err = throttled.SignIn(ctx, &wrong)
+	require.Equal(t, codes.Code(http.StatusUnauthorized), status.Code(err))
+
+	// the second failure delays the next attempt, even with the right password
+	_, err = throttled.SignIn(ctx, &right)
+	require.Equal(t, codes.Code(http.StatusTooManyRequests), status.Code(err))
+	details := status.Convert(err).Details()
+	require.Len(t, details, 1)
+	require.IsType(t, &errdetails.RetryInfo{}, details[0])
+
+	time.Sleep(1100 * time.Millisecond)
+	_, err = throttled.SignIn(ctx, &wrong)
+	require.Equal(t, codes.Code(http.StatusUnauthorized), status.Code(err))
+
+	// the fourth failure locks the user name
+	time.Sleep(1100 * time.Millisecond)
+	_, err = throttled.SignIn(ctx, &wrong)
+	require.Equal(t, codes.Code(http.StatusUnauthorized), status.Code(err))
+
+	time.Sleep(1100 * time.Millisecond)
+	_, err = throttled.SignIn(ctx, &right)
+	require.Equal(t, codes.Code(http.StatusTooManyRequests), status.Code(err))
+
+	err = cache.Remove(ctx, "login-lockout:user:test-throttle")
+	require.Nil(t, err)
+
+	_, err = throttled.SignIn(ctx, &right)
+	require.Nil(t, err)
+
+	// parallel guesses share the limits, only the ones before the delay get through
+	results := make(chan error, 8)
+	for n := 0; n < cap(results); n++ {
+		go func() {
+			_, err := throttled.SignIn(ctx, &wrong)
+			results <- err
+		}()
+	}
+	guessed := 0
+	for n := 0; n < cap(results); n++ {
+		if status.Code(<-results) == codes.Code(http.StatusUnauthorized) {
+			guessed++
+		}
+	}
+	require.True(t, guessed <= 2)
+
+	_, err = repo.Remove(ctx, user)
+	require.Nil(t, err)
+}
+
+func Test_User_Service_PasswordPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	strictConf := *conf
+	strictConf.PasswordConfig = config.PasswordConfig{
+		MinLength:      10,
+		MinClasses:     3,
+		ForbidUserInfo: true,
+	}
+	strictPolicy, err := password.NewPasswordPolicy(strictConf.PasswordConfig, log)
+	require.Nil(t, err)
+	strict := services.NewUserService(&strictConf, log, repo, clients, cache, auth, revocations, mail, smsSender, strictPolicy, hasher, totp, audits)
+
+	user := NewUser()
+	user.UserName = "test-policy"
+	user.ContactInfo.Email = "test-policy@juno.com"
+
+	_, err = strict.SignUp(ctx, user)
+	require.Equal(t, codes.Code(http.StatusBadRequest), status.Code(err))
+	details := status.Convert(err).Details()
+	require.Len(t, details, 1)
+	badRequest, ok := details[0].(*errdetails.BadRequest)
+	require.True(t, ok)
+	require.Len(t, badRequest.FieldViolations, 2)
+	require.Equal(t, password.PasswordField, badRequest.FieldViolations[0].Field)
+
+	user.Password = "Test-Policy-2020"
+	_, err = strict.SignUp(ctx, user)
+	require.Equal(t, codes.Code(http.StatusBadRequest), status.Code(err))
+
+	user.Password = "Juno-Strict-2020"
+	_, err = strict.SignUp(ctx, user)
+	require.Nil(t, err)
+	user.Password = "Juno-Strict-2020"
+	activate(t, ctx, user)
+
+	err = strict.RequestPasswordReset(ctx, user.UserName)
+	require.Nil(t, err)
+	resetToken := mailCode(t, user.ContactInfo.Email, "password reset code")
+
+	// a rejected password leaves the reset token usable
+	err = strict.ResetPassword(ctx, resetToken, "weak")
+	require.Equal(t, codes.Code(http.StatusBadRequest), status.Code(err))
+
+	err = strict.ResetPassword(ctx, resetToken, "Another-Strict-2021")
+	require.Nil(t, err)
+
+	user.Password = "Another-Strict-2021"
+	token, err := strict.SignIn(ctx, user)
+	require.Nil(t, err)
+
+	err = strict.ChangePassword(ctx, token, "Another-Strict-2021", "weakpassword")
+	require.Equal(t, codes.Code(http.StatusBadRequest), status.Code(err))
+
+	_, err = repo.Remove(ctx, user)
+	require.Nil(t, err)
+}
+
+func Test_User_Service_SignIn_Rehash(t *testing.T) {
+	ctx := context.Background()
+
+	bcryptConf := *conf
+	bcryptConf.HashConfig = config.HashConfig{Algorithm: password.Bcrypt, BcryptCost: 4}
+	bcryptHasher, err := password.NewPasswordHasher(bcryptConf.HashConfig, log)
+	require.Nil(t, err)
+	legacy := services.NewUserService(&bcryptConf, log, repo, clients, cache, auth, revocations, mail, smsSender, policy, bcryptHasher, totp, audits)
+
+	user := NewUser()
+	user.UserName = "test-rehash"
+	user.ContactInfo.Email = "test-rehash@juno.com"
+
+	_, err = legacy.SignUp(ctx, user)
+	require.Nil(t, err)
+	user.Password = "test"
+	activate(t, ctx, user)
+
+	usr, err := repo.FindWithUserId(ctx, user.UserId)
+	require.Nil(t, err)
+	require.True(t, strings.HasPrefix(usr.Password, "$2a$"))
+
+	// refused sign ins do not pay for a new hash
+	active := *usr.Status
+	err = repo.UpdateStatus(ctx, user.UserId, &entity.UserStatus{Status: entity.Banned, Restriction: &entity.Restriction{Reason: "spam"}})
+	require.Nil(t, err)
+	_, err = service.SignIn(ctx, user)
+	require.NotNil(t, err)
+
+	usr, err = repo.FindWithUserId(ctx, user.UserId)
+	require.Nil(t, err)
+	require.True(t, strings.HasPrefix(usr.Password, "$2a$"))
+	err = repo.UpdateStatus(ctx, user.UserId, &active)
+	require.Nil(t, err)
+
+	// signing in with the current hasher upgrades the bcrypt hash
+	_, err = service.SignIn(ctx, user)
+	require.Nil(t, err)
+
+	usr, err = repo.FindWithUserId(ctx, user.UserId)
+	require.Nil(t, err)
+	require.True(t, strings.HasPrefix(usr.Password, "$argon2id$"))
+
+	_, err = service.SignIn(ctx, user)
+	require.Nil(t, err)
+
+	_, err = repo.Remove(ctx, user)
+	require.Nil(t, err)
+}
+
+func Test_User_Service_Mfa(t *testing.T) {
+	ctx := context.Background()
+	user := NewUser()
+	user.UserName = "test-mfa"
+	user.ContactInfo.Email = "test-mfa@juno.com"
+
+	_, err := service.SignUp(ctx, user)
+	require.Nil(t, err)
+	user.Password = "test"
+	activate(t, ctx, user)
+
+	token, err := service.SignIn(ctx, user)
+	require.Nil(t, err)
+
+	secret, uri, err := service.EnrollTotp(ctx, token)
+	require.Nil(t, err)
+	require.Contains(t, uri, "secret="+secret)
+
+	// the secret is only stored encrypted
+	usr, err := repo.FindWithUserId(ctx, user.UserId)
+	require.Nil(t, err)
+	require.False(t, usr.Mfa.Enabled)
+	require.NotEqual(t, secret, usr.Mfa.Secret)
+
+	now := time.Now()
+	code, err := mfa.GenerateCode(secret, now)
+	require.Nil(t, err)
+	recoveryCodes, err := service.ConfirmTotp(ctx, token, code)
+	require.Nil(t, err)
+	require.Len(t, recoveryCodes, conf.MfaConfig.RecoveryCodes)
+
+	challenge, err := service.SignIn(ctx, user)
+	require.Nil(t, err)
+	require.NotEqual(t, "", challenge.MfaToken)
+	require.Equal(t, "", challenge.AccessToken)
+
+	_, err = service.CompleteMfa(ctx, challenge.MfaToken, "000000x")
+	require.Equal(t, codes.Code(http.StatusUnauthorized), status.Code(err))
+
+	// the code of the confirmation is used up
+	_, err = service.CompleteMfa(ctx, challenge.MfaToken, code)
+	require.Equal(t, codes.Code(http.StatusUnauthorized), status.Code(err))
+
+	code, err = mfa.GenerateCode(secret, now.Add(mfa.Period))
+	require.Nil(t, err)
+	mfaToken, err := service.CompleteMfa(ctx, challenge.MfaToken, code)
+	require.Nil(t, err)
+	_, err = service.Validate(ctx, mfaToken)
+	require.Nil(t, err)
+
+	_, err = service.CompleteMfa(ctx, challenge.MfaToken, recoveryCodes[0])
+	require.Equal(t, codes.Code(http.StatusUnauthorized), status.Code(err))
+
+	// recovery codes work once, whatever case they are typed in
+	challenge, err = service.SignIn(ctx, user)
+	require.Nil(t, err)
+	_, err = service.CompleteMfa(ctx, challenge.MfaToken, strings.ToUpper(recoveryCodes[0]))
+	require.Nil(t, err)
+
+	challenge, err = service.SignIn(ctx, user)
+	require.Nil(t, err)
+	_, err = service.CompleteMfa(ctx, challenge.MfaToken, recoveryCodes[0])
+	require.Equal(t, codes.Code(http.StatusUnauthorized), status.Code(err))
+
+	err = service.DisableMfa(ctx, mfaToken, "wrong", recoveryCodes[1])
+	require.Equal(t, codes.Code(http.StatusUnauthorized), status.Code(err))
+
+	err = service.DisableMfa(ctx, mfaToken, user.Password, recoveryCodes[1])
+	require.Nil(t, err)
+
+	token, err = service.SignIn(ctx, user)
+	require.Nil(t, err)
+	require.Equal(t, "", token.MfaToken)
+	require.NotEqual(t, "", token.AccessToken)
+
+	_, err = repo.Remove(ctx, user)
+	require.Nil(t, err)
+}
+
+func Test_User_Service_Mfa_Throttle(t *testing.T) {
+	ctx := context.Background()
+
+	throttledConf := *conf
+	throttledConf.LoginConfig = config.LoginConfig{
+		Window:           1,
+		DelayAfter:       10,
+		MaxDelay:         1,
+		LockoutThreshold: 3,
+		IpThreshold:      100,
+		LockoutDuration:  1,
+	}
+	throttled := services.NewUserService(&throttledConf, log, repo, clients, cache, auth, revocations, mail, smsSender, policy, hasher, totp, audits)
+
+	user := NewUser()
+	user.UserName = "test-mfa-throttle"
+	user.ContactInfo.Email = "test-mfa-throttle@juno.com"
+
+	_, err := throttled.SignUp(ctx, user)
+	require.Nil(t, err)
+	user.Password = "test"
+	activate(t, ctx, user)
+
+	token, err := throttled.SignIn(ctx, user)
+	require.Nil(t, err)
+	secret, _, err := throttled.EnrollTotp(ctx, token)
+	require.Nil(t, err)
+	code, err := mfa.GenerateCode(secret, time.Now())
+	require.Nil(t, err)
+	_, err = throttled.ConfirmTotp(ctx, token, code)
+	require.Nil(t, err)
+
+	// the sign in stays counted until the second factor is given, wrong codes add to it
+	challenge, err := throttled.SignIn(ctx, user)
+	require.Nil(t, err)
+	_, err = throttled.CompleteMfa(ctx, challenge.MfaToken, "0000-0000-0000-0000")
+	require.Equal(t, codes.Code(http.StatusUnauthorized), status.Code(err))
+	_, err = throttled.CompleteMfa(ctx, challenge.MfaToken, "0000-0000-0000-0000")
+	require.Equal(t, codes.Code(http.StatusUnauthorized), status.Code(err))
+
+	code, err = mfa.GenerateCode(secret, time.Now().Add(mfa.Period))
+	require.Nil(t, err)
+	_, err = throttled.CompleteMfa(ctx, challenge.MfaToken, code)
+	require.Equal(t, codes.Code(http.StatusTooManyRequests), status.Code(err))
+
+	_, err = throttled.SignIn(ctx, user)
+	require.Equal(t, codes.Code(http.StatusTooManyRequests), status.Code(err))
+
+	_, err = repo.Remove(ctx, user)
+	require.Nil(t, err)
+}
+
+func Test_User_Service_AuditEvents(t *testing.T) {
+	ctx := services.WithClientInfo(context.Background(), services.ClientInfo{IpAddress: "10.0.0.25", UserAgent: "audit-test"})
+	admin := NewUser()
+	admin.UserName = "test-auditor"
+	admin.ContactInfo.Email = "test-auditor@juno.com"
+	admin.Permissions = []*entity.Permission{&services.AdminPermission}
+
+	_, err := service.SignUp(ctx, admin)
+	require.Nil(t, err)
+	admin.Password = "test"
+	activate(t, ctx, admin)
+
+	user := NewUser()
+	user.UserName = "test-audited"
+	user.ContactInfo.Email = "test-audited@juno.com"
+
+	_, err = service.SignUp(ctx, user)
+	require.Nil(t, err)
+	user.Password = "test"
+	activate(t, ctx, user)
+
+	wrong := *user
+	wrong.Password = "wrong"
+	_, err = service.SignIn(ctx, &wrong)
+	require.NotNil(t, err)
+
+	token, err := service.SignIn(ctx, user)
+	require.Nil(t, err)
+	adminToken, err := service.SignIn(ctx, admin)
+	require.Nil(t, err)
+
+	// only admins read the audit log
+	_, _, err = service.QueryAuditEvents(ctx, token, services.AuditQuery{})
+	require.Equal(t, codes.Code(http.StatusForbidden), status.Code(err))
+
+	_, _, err = service.QueryAuditEvents(ctx, adminToken, services.AuditQuery{PerPage: services.MaxAuditPerPage + 1})
+	require.Equal(t, codes.Code(http.StatusBadRequest), status.Code(err))
+
+	events, total, err := service.QueryAuditEvents(ctx, adminToken, services.AuditQuery{
+		Type:   entity.SignInEvent,
+		Target: user.UserId,
+	})
+	require.Nil(t, err)
+	require.Equal(t, int64(2), total)
+	require.Len(t, events, 2)
+
+	// newest first
+	require.Equal(t, entity.Succeeded, events[0].Outcome)
+	require.Equal(t, entity.Failed, events[1].Outcome)
+	require.Equal(t, "10.0.0.25", events[1].IpAddress)
+	require.Equal(t, "audit-test", events[1].UserAgent)
+	require.Equal(t, user.UserName, events[1].Details["login"])
+
+	// the actor comes from validated tokens only, a claimed user id is not recorded
+	forged := authorization.TokenDetail{AccessToken: "forged", UserId: user.UserId}
+	err = service.ChangePassword(ctx, &forged, "test", "changed")
+	require.NotNil(t, err)
+
+	_, total, err = service.QueryAuditEvents(ctx, adminToken, services.AuditQuery{
+		Type:   entity.PasswordChangeEvent,
+		Target: user.UserId,
+	})
+	require.Nil(t, err)
+	require.Equal(t, int64(0), total)
+
+	_, err = service.RestrictUser(ctx, adminToken, user.UserId, entity.Banned, "spam", nil)
+	require.Nil(t, err)
+
+	events, _, err = service.QueryAuditEvents(ctx, adminToken, services.AuditQuery{
+		Actor:   admin.UserId,
+		Type:    entity.RestrictUserEvent,
+		PerPage: 1,
+	})
+	require.Nil(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, user.UserId, events[0].Target)
+	require.Equal(t, "spam", events[0].Details["reason"])
+
+	_, err = repo.Remove(ctx, user)
+	require.Nil(t, err)
+	_, err = repo.Remove(ctx, admin)
+	require.Nil(t, err)
+}
+
+// introspector stores a client with the introspect scope and returns its token
+func introspector(t *testing.T, ctx context.Context) *authorization.TokenDetail {
+	secretHash, err := hasher.Hash("introspector-secret")
+	require.Nil(t, err)
+
+	now := time.Now().UTC()
+	client, err := clients.Save(ctx, &entity.Client{
+		ClientId:        uuid.NewV4().String(),
+		Name:            "gateway",
+		SecretHash:      secretHash,
+		Scopes:          []string{services.IntrospectScope},
+		CreatedAt:       &now,
+		UpdatedAt:       &now,
+		DocumentVersion: entity.DocumentVersion,
+	})
+	require.Nil(t, err)
+
+	token, err := service.ClientToken(ctx, client.ClientId, "introspector-secret", nil)
+	require.Nil(t, err)
+
+	return token
+}
+
+// smsCode reads the code of the latest text message sent to mobile
+func smsCode(t *testing.T, mobile string) string {
+	files, err := ioutil.ReadDir(outbox)
+	require.Nil(t, err)
+
+	var message []byte
+	for _, file := range files {
+		if strings.HasSuffix(file.Name(), "-"+mobile+".sms") {
+			message, err = ioutil.ReadFile(filepath.Join(outbox, file.Name()))
+			require.Nil(t, err)
+		}
+	}
+
+	match := regexp.MustCompile(`code: (\d+)`).FindSubmatch(message)
+	require.Len(t, match, 2)
+	return string(match[1])
+}
+
+func activationCode(t *testing.T, email string) string {
+	return mailCode(t, email, "activation code")
+}
+
+// mailCode reads the code labeled label from the latest mail sent to email
+func mailCode(t *testing.T, email string, label string) string {
+	files, err := ioutil.ReadDir(outbox)
+	require.Nil(t, err)
+
+	// mail files are named after their send time, the last one is the latest
+	var mail []byte
+	for _, file := range files {
+		if strings.HasSuffix(file.Name(), "-"+email+".eml") {
+			mail, err = ioutil.ReadFile(filepath.Join(outbox, file.Name()))
+			require.Nil(t, err)
+		}
+	}
+
+	match := regexp.MustCompile(label + `: (\S+)`).FindSubmatch(mail)
+	require.Len(t, match, 2)
+	return string(match[1])
+}
+
+func activate(t *testing.T, ctx context.Context, user *entity.User) {
+	err := service.ActivateAccount(ctx, user.ContactInfo.Email, activationCode(t, user.ContactInfo.Email))
+	require.Nil(t, err)
+}
+
 func NewUser() *entity.User {
 	user := entity.User{
 		UserName: "test",
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"github.com/Juno-chat-app/user-service/domain/entity"
+	"github.com/Juno-chat-app/user-service/domain/model/authorization"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"time"
+)
+
+// UserQuery finds a user by exactly one of its fields
+type UserQuery struct {
+	UserId string
+	Email  string
+	Mobile string
+}
+
+func (i *iUserService) GetUser(ctx context.Context, token *authorization.TokenDetail, query UserQuery) (usr *entity.User, err error) {
+	i.logger.Info("GetUser request",
+		"method", "GetUser",
+		"user-id", token.UserId,
+		"query-user-id", query.UserId)
+
+	viewer, err := i.Validate(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if query.Mobile != "" {
+		query.Mobile, err = normalizeMobile(query.Mobile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var user *entity.User
+	switch {
+	case query.UserId != "" && query.Email == "" && query.Mobile == "":
+		user, err = i.repository.FindWithUserId(ctx, query.UserId)
+	case query.Email != "" && query.UserId == "" && query.Mobile == "":
+		user, err = i.repository.FindWithEmail(ctx, query.Email)
+	case query.Mobile != "" && query.UserId == "" && query.Email == "":
+		user, err = i.repository.FindWithMobile(ctx, query.Mobile)
+	default:
+		return nil, status.Error(http.StatusBadRequest, "exactly one of user-id, email or mobile is required")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// users which can not sign in are not discoverable either
+	if user.DeletedAt != nil || user.Status == nil || user.Status.Effective(time.Now()) != entity.Active {
+		return nil, status.Error(http.StatusNotFound, "user not found")
+	}
+
+	// an unverified mobile may belong to someone else
+	if query.Mobile != "" && (user.ContactInfo == nil || !user.ContactInfo.MobileVerified) {
+		return nil, status.Error(http.StatusNotFound, "user not found")
+	}
+
+	owner := viewer.PrincipalType == authorization.UserPrincipal && viewer.UserId == user.UserId
+	if !owner {
+		owner, err = i.isAdmin(ctx, viewer)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return project(user, owner, query), nil
+}
+
+// project copies the public fields of user, the contact info is only visible
+// to the user and admins, everyone else only sees the channel they searched by
+// and therefore already knew
+func project(user *entity.User, fullView bool, query UserQuery) *entity.User {
+	projection := entity.User{
+		UserName:    user.UserName,
+		UserId:      user.UserId,
+		ContactInfo: &entity.ContactInfo{},
+	}
+
+	if user.ContactInfo == nil {
+		return &projection
+	}
+
+	if fullView {
+		contactInfo := *user.ContactInfo
+		projection.ContactInfo = &contactInfo
+		return &projection
+	}
+
+	if query.Email != "" {
+		projection.ContactInfo.Email = user.ContactInfo.Email
+	}
+	if query.Mobile != "" {
+		projection.ContactInfo.Mobile = user.ContactInfo.Mobile
+	}
+
+	return &projection
+}
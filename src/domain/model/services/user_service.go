@@ -2,11 +2,17 @@ package services
 
 import (
 	"context"
+	"github.com/Juno-chat-app/user-service/config"
 	"github.com/Juno-chat-app/user-service/domain/entity"
 	"github.com/Juno-chat-app/user-service/domain/model/authorization"
+	"github.com/Juno-chat-app/user-service/domain/model/mfa"
+	"github.com/Juno-chat-app/user-service/domain/model/password"
 	"github.com/Juno-chat-app/user-service/domain/repository/mongo"
 	"github.com/Juno-chat-app/user-service/domain/repository/redis"
 	"github.com/Juno-chat-app/user-service/infra/logger"
+	"github.com/Juno-chat-app/user-service/infra/mailer"
+	"github.com/Juno-chat-app/user-service/infra/sms"
+	"time"
 )
 
 type IUserService interface {
@@ -14,15 +20,141 @@ type IUserService interface {
 	SignIn(ctx context.Context, user *entity.User) (auth *authorization.TokenDetail, err error)
 	RefreshToken(ctx context.Context, token *authorization.TokenDetail) (token_ *authorization.TokenDetail, err error)
 	Validate(ctx context.Context, token *authorization.TokenDetail) (token_ *authorization.TokenDetail, err error)
-	GetUser(ctx context.Context, info entity.ContactInfo) (usr *entity.User, err error)
+	SignOut(ctx context.Context, token *authorization.TokenDetail) (err error)
+	// Introspect reports revoked, expired or malformed tokens as inactive instead of failing,
+	// only clients with the introspect scope may call it
+	Introspect(ctx context.Context, caller *authorization.TokenDetail, token string, tokenTypeHint string) (introspection *Introspection, err error)
+	ListSessions(ctx context.Context, token *authorization.TokenDetail) (sessions []*entity.Session, err error)
+	RevokeSession(ctx context.Context, token *authorization.TokenDetail, sessionId string) (err error)
+	RevokeAllSessions(ctx context.Context, token *authorization.TokenDetail) (err error)
+	// ActivateAccount verifies the email of a pending user with the code sent on sign up
+	ActivateAccount(ctx context.Context, email string, code string) (err error)
+	// ResendActivation answers the same for unknown and active users
+	ResendActivation(ctx context.Context, email string) (err error)
+	// RequestPasswordReset mails a reset token to the user with the email or user-name login,
+	// unknown logins get the same answer
+	RequestPasswordReset(ctx context.Context, login string) (err error)
+	// ResetPassword sets the password of the token owner and signs them out everywhere, a
+	// password breaking the policy keeps the token usable
+	ResetPassword(ctx context.Context, token string, password string) (err error)
+	// ChangePassword checks the old password and signs out every other session of the user
+	ChangePassword(ctx context.Context, token *authorization.TokenDetail, oldPassword string, newPassword string) (err error)
+	// GetUser finds an active user, the password is never returned and the contact
+	// info is projected to what the caller may see
+	GetUser(ctx context.Context, token *authorization.TokenDetail, query UserQuery) (usr *entity.User, err error)
+	// UpdateProfile sets the mask paths of the token owner to the values in user, a new
+	// email is mailed a code and only set by ConfirmEmailChange
+	UpdateProfile(ctx context.Context, token *authorization.TokenDetail, user *entity.User, mask []entity.Path) (user_ *entity.User, err error)
+	// ConfirmEmailChange sets the pending email of the token owner as verified email
+	ConfirmEmailChange(ctx context.Context, token *authorization.TokenDetail, code string) (user *entity.User, err error)
+	// DeleteAccount soft deletes the token owner after checking the password and signs them out
+	// everywhere, the account is purged after the deletion grace period
+	DeleteAccount(ctx context.Context, token *authorization.TokenDetail, password string) (err error)
+	// RequestAccountRestore mails a restore code for the deleted account of email, emails
+	// without deleted account get the same answer
+	RequestAccountRestore(ctx context.Context, email string) (err error)
+	RestoreAccount(ctx context.Context, code string) (user *entity.User, err error)
+	// AdminRestoreAccount restores a deleted account without restore code
+	AdminRestoreAccount(ctx context.Context, token *authorization.TokenDetail, userId string) (user *entity.User, err error)
+	// RequestMobileVerification sends a one-time code to the mobile of the user
+	RequestMobileVerification(ctx context.Context, token *authorization.TokenDetail) (err error)
+	VerifyMobile(ctx context.Context, token *authorization.TokenDetail, code string) (err error)
+	// RestrictUser suspends or bans a user and revokes its tokens, suspensions with
+	// endAt lift by themselves
+	RestrictUser(ctx context.Context, token *authorization.TokenDetail, userId string, status entity.Status,
+		reason string, endAt *time.Time) (user *entity.User, err error)
+	LiftRestriction(ctx context.Context, token *authorization.TokenDetail, userId string) (user *entity.User, err error)
+	// EnrollTotp returns a new TOTP secret and its otpauth uri, the second factor is
+	// enabled once ConfirmTotp got a first code of it
+	EnrollTotp(ctx context.Context, token *authorization.TokenDetail) (secret string, uri string, err error)
+	// ConfirmTotp enables the enrolled second factor, the recovery codes are returned only once
+	ConfirmTotp(ctx context.Context, token *authorization.TokenDetail, code string) (recoveryCodes []string, err error)
+	// CompleteMfa issues the tokens of a sign in which returned an mfa token, code is
+	// a TOTP code or a recovery code
+	CompleteMfa(ctx context.Context, mfaToken string, code string) (token *authorization.TokenDetail, err error)
+	// DisableMfa needs the password and a code of the second factor
+	DisableMfa(ctx context.Context, token *authorization.TokenDetail, password string, code string) (err error)
+	// QueryAuditEvents is an admin operation, the events are returned newest first
+	// with the total number of matching events
+	QueryAuditEvents(ctx context.Context, token *authorization.TokenDetail, query AuditQuery) (events []*entity.AuditEvent, total int64, err error)
+	PublicKeys(ctx context.Context) (keySet *authorization.JsonWebKeySet, err error)
+	// ClientToken is the client credentials grant, scopes must be a subset of the allowed ones
+	ClientToken(ctx context.Context, clientId string, clientSecret string, scopes []string) (token *authorization.TokenDetail, err error)
+	// RegisterClient is an admin operation, the generated secret is returned only once
+	RegisterClient(ctx context.Context, token *authorization.TokenDetail, client *entity.Client) (client_ *entity.Client, secret string, err error)
 }
 
-func NewUserService(logger logger.ILogger, repo mongo.IUserRepository, cache redis.ICache, auth authorization.IJwtHandler) IUserService {
+func NewUserService(conf *config.Configuration, logger logger.ILogger, repo mongo.IUserRepository, clients mongo.IClientRepository,
+	cache redis.ICache, auth authorization.IJwtHandler, revocations IRevocationList, mailer mailer.IMailer,
+	smsSender sms.ISmsSender, passwordPolicy password.IPasswordPolicy,
+	passwordHasher password.IPasswordHasher, totp mfa.ITotpHandler, audits mongo.IAuditRepository) IUserService {
 	userService := iUserService{
-		logger:     logger,
-		cache:      cache,
-		repository: repo,
-		auth:       auth,
+		logger:         logger,
+		cache:          cache,
+		repository:     repo,
+		clients:        clients,
+		audits:         audits,
+		mailer:         mailer,
+		smsSender:      smsSender,
+		passwordPolicy: passwordPolicy,
+		passwordHasher: passwordHasher,
+		totp:           totp,
+		auth:           auth,
+		revocations:    revocations,
+		validationMode: conf.AuthConfig.ValidationMode,
+		accessTTL:      conf.AuthConfig.AccessTTL * time.Minute,
+		activationTTL:  conf.AccountConfig.ActivationTTL * time.Minute,
+
+		passwordResetTTL:    conf.AccountConfig.PasswordResetTTL * time.Minute,
+		deletionGracePeriod: conf.AccountConfig.DeletionGracePeriod * 24 * time.Hour,
+		restoreTTL:          conf.AccountConfig.RestoreTTL * time.Minute,
+		mobileCodeTTL:       conf.AccountConfig.MobileCodeTTL * time.Minute,
+		mobileCodeAttempts:  conf.AccountConfig.MobileCodeAttempts,
+		loginLimits:         newLoginLimits(conf.LoginConfig),
+
+		mfaChallengeTTL:      conf.MfaConfig.ChallengeTTL * time.Minute,
+		mfaChallengeAttempts: conf.MfaConfig.ChallengeAttempts,
+		recoveryCodes:        conf.MfaConfig.RecoveryCodes,
+	}
+
+	if userService.activationTTL == 0 {
+		userService.activationTTL = DefaultActivationTTL
+	}
+
+	if userService.passwordResetTTL == 0 {
+		userService.passwordResetTTL = DefaultPasswordResetTTL
+	}
+
+	if userService.deletionGracePeriod == 0 {
+		userService.deletionGracePeriod = DefaultDeletionGracePeriod
+	}
+
+	if userService.restoreTTL == 0 {
+		userService.restoreTTL = DefaultRestoreTTL
+	}
+
+	if userService.mobileCodeTTL == 0 {
+		userService.mobileCodeTTL = DefaultMobileCodeTTL
+	}
+
+	if userService.mobileCodeAttempts == 0 {
+		userService.mobileCodeAttempts = DefaultMobileCodeAttempts
+	}
+
+	if userService.mfaChallengeTTL == 0 {
+		userService.mfaChallengeTTL = DefaultMfaChallengeTTL
+	}
+
+	if userService.mfaChallengeAttempts == 0 {
+		userService.mfaChallengeAttempts = DefaultMfaChallengeAttempts
+	}
+
+	if userService.recoveryCodes == 0 {
+		userService.recoveryCodes = DefaultRecoveryCodes
+	}
+
+	if userService.validationMode == "" {
+		userService.validationMode = StatefulValidation
 	}
 
 	return &userService
@@ -4,9 +4,13 @@ import (
 	"context"
 	"github.com/Juno-chat-app/user-service/domain/entity"
 	"github.com/Juno-chat-app/user-service/domain/model/authorization"
+	"github.com/Juno-chat-app/user-service/domain/model/mfa"
+	"github.com/Juno-chat-app/user-service/domain/model/password"
 	"github.com/Juno-chat-app/user-service/domain/repository/mongo"
 	"github.com/Juno-chat-app/user-service/domain/repository/redis"
 	"github.com/Juno-chat-app/user-service/infra/logger"
+	"github.com/Juno-chat-app/user-service/infra/mailer"
+	"github.com/Juno-chat-app/user-service/infra/sms"
 	"github.com/twinj/uuid"
 	"google.golang.org/grpc/status"
 	"net/http"
@@ -17,7 +21,33 @@ type iUserService struct {
 	logger     logger.ILogger
 	cache      redis.ICache
 	repository mongo.IUserRepository
+	clients    mongo.IClientRepository
+	audits     mongo.IAuditRepository
+	mailer     mailer.IMailer
+	smsSender  sms.ISmsSender
 	auth       authorization.IJwtHandler
+	// passwordPolicy is checked on sign up, change and reset of passwords
+	passwordPolicy password.IPasswordPolicy
+	// passwordHasher hashes new passwords, hashes of older algorithms or parameters are upgraded on sign in
+	passwordHasher password.IPasswordHasher
+	totp           mfa.ITotpHandler
+	// revocations are recorded in both validation modes so switching to
+	// stateless validation keeps earlier sign outs
+	revocations    IRevocationList
+	validationMode string
+	accessTTL      time.Duration
+	activationTTL  time.Duration
+
+	passwordResetTTL    time.Duration
+	deletionGracePeriod time.Duration
+	restoreTTL          time.Duration
+	mobileCodeTTL       time.Duration
+	mobileCodeAttempts  int
+	loginLimits         loginLimits
+
+	mfaChallengeTTL      time.Duration
+	mfaChallengeAttempts int
+	recoveryCodes        int
 }
 
 func (i *iUserService) SignUp(ctx context.Context, user *entity.User) (user_ *entity.User, err error) {
@@ -30,9 +60,33 @@ func (i *iUserService) SignUp(ctx context.Context, user *entity.User) (user_ *en
 		return nil, status.Error(http.StatusBadRequest, "invalid value for user-name or password")
 	}
 
+	user.UserName, err = entity.NormalizeUserName(user.UserName)
+	if err != nil {
+		return nil, status.Error(http.StatusBadRequest, err.Error())
+	}
+	user.ContactInfo.Email, err = entity.NormalizeEmail(user.ContactInfo.Email)
+	if err != nil {
+		return nil, status.Error(http.StatusBadRequest, err.Error())
+	}
+	if user.ContactInfo.Mobile != "" {
+		user.ContactInfo.Mobile, err = normalizeMobile(user.ContactInfo.Mobile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = i.passwordPolicy.Check(user.Password, user)
+	if err != nil {
+		return nil, err
+	}
+
+	// a new user has proven none of its channels yet
+	user.ContactInfo.EmailVerified = false
+	user.ContactInfo.MobileVerified = false
+
 	create := time.Now().UTC()
 
-	hashPass, err := generatePasswordOneWayHash(user.Password)
+	hashPass, err := i.hashPassword(user.Password)
 	if err != nil {
 		return nil, status.Error(http.StatusInternalServerError, err.Error())
 	}
@@ -40,12 +94,20 @@ func (i *iUserService) SignUp(ctx context.Context, user *entity.User) (user_ *en
 	user.UserId = uuid.NewV4().String()
 	user.CreatedAt = &create
 	user.UpdatedAt = &create
+	if user.Profile == nil {
+		user.Profile = &entity.Profile{}
+	}
 
+	// new users stay pending until they verify their email
+	var activationCode string
 	if user.Status == nil {
 		user.Status = &entity.UserStatus{
-			Status:         entity.Active,
-			ActivationCode: "",
-			UpdatedAt:      &create,
+			Status: entity.Pending,
+		}
+
+		activationCode, err = i.newActivationCode(user.Status, create)
+		if err != nil {
+			return nil, err
 		}
 	}
 
@@ -69,6 +131,11 @@ func (i *iUserService) SignUp(ctx context.Context, user *entity.User) (user_ *en
 		"user-contact", user_.ContactInfo,
 		"user-status", user_.Status)
 
+	// the user is registered already, a lost mail is recovered with ResendActivation
+	if activationCode != "" {
+		_ = i.sendActivation(ctx, user_, activationCode)
+	}
+
 	user_.Password = "--secret--"
 	return user_, nil
 }
@@ -78,25 +145,70 @@ func (i *iUserService) SignIn(ctx context.Context, user *entity.User) (auth *aut
 		"method", "SignUp",
 		"user-name", user.UserName)
 
-	user_, err := i.repository.FindWithUserName(ctx, user.UserName)
+	event := entity.AuditEvent{Type: entity.SignInEvent, Details: map[string]string{"login": user.UserName}}
+	defer func() { i.recordEvent(ctx, &event, err) }()
+
+	attempt, err := i.beginLogin(ctx, loginKey(user.UserName), clientInfoFrom(ctx).IpAddress)
 	if err != nil {
 		return nil, err
 	}
 
-	if !checkPasswordHash(user.Password, user_.Password) {
-		return nil, status.Error(http.StatusUnauthorized, "invalid user-name or password")
+	user_, err := i.repository.FindWithUserName(ctx, user.UserName)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, i.signInFailed(ctx, attempt)
+		}
+		return nil, i.abortLogin(ctx, attempt, err)
 	}
 
-	token, err := i.auth.CreateAccessToken(user_.UserId)
-	if err != nil {
-		return nil, err
+	if !i.checkPassword(user.Password, user_.Password) {
+		return nil, i.signInFailed(ctx, attempt)
 	}
 
-	err = i.cache.Set(ctx, token.AccessUUid, token.AccessToken, time.Duration(token.ExpireAt))
+	event.Actor = user_.UserId
+	event.Target = user_.UserId
+
+	// users with a second factor only succeed once CompleteMfa got it
+	mfaEnabled := user_.Mfa != nil && user_.Mfa.Enabled
+	if !mfaEnabled {
+		err = i.loginSucceeded(ctx, attempt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the restriction is only told to users who proved their password, users
+	// stored without status are active
+	if user_.Status != nil {
+		switch user_.Status.Effective(time.Now()) {
+		case entity.Suspended, entity.Banned:
+			return nil, restrictionError(user_.Status.Status, user_.Status.Restriction)
+		case entity.Active:
+			if user_.Status.Status == entity.Suspended {
+				err = i.liftRestriction(ctx, user_, "")
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	// users with a second factor get the tokens from CompleteMfa
+	if mfaEnabled {
+		event.Outcome = entity.Challenged
+		return i.mfaChallenge(ctx, user_, attempt)
+	}
+
+	// only sign ins which get their tokens pay for a new hash, users with a second
+	// factor keep theirs until the password changes as CompleteMfa has no password
+	i.rehashPassword(ctx, user_, user.Password)
+
+	token, err := i.auth.CreateAccessToken(user_.UserId, "", user_.Permissions)
 	if err != nil {
 		return nil, err
 	}
-	err = i.cache.Set(ctx, token.RefreshUUid, token.RefreshToke, time.Duration(token.RefreshExpireAt))
+
+	err = i.storeTokens(ctx, token, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -107,10 +219,23 @@ func (i *iUserService) SignIn(ctx context.Context, user *entity.User) (auth *aut
 	return token, nil
 }
 
+// signInFailed counts the failure and returns the error of a wrong user name or password
+func (i *iUserService) signInFailed(ctx context.Context, attempt *loginAttempt) error {
+	err := i.loginFailed(ctx, attempt)
+	if err != nil {
+		return err
+	}
+
+	return status.Error(http.StatusUnauthorized, "invalid user-name or password")
+}
+
 func (i *iUserService) RefreshToken(ctx context.Context, token *authorization.TokenDetail) (token_ *authorization.TokenDetail, err error) {
 	i.logger.Info("Refresh token request",
 		"method", "refresh")
 
+	event := entity.AuditEvent{Type: entity.RefreshEvent}
+	defer func() { i.recordEvent(ctx, &event, err) }()
+
 	if token.RefreshToke == "" {
 		return nil, status.Error(http.StatusBadRequest, "unspecified refresh token")
 	}
@@ -119,9 +244,45 @@ func (i *iUserService) RefreshToken(ctx context.Context, token *authorization.To
 	if err != nil {
 		return nil, err
 	}
+	event.Actor = token_.UserId
+	event.Target = token_.UserId
+
+	if token.UserId != token_.UserId {
+		return nil, status.Error(http.StatusConflict, "invalid user-id for token")
+	}
+
+	// a refresh token is single use, marking it first keeps concurrent refreshes
+	// from both succeeding
+	firstUse, err := i.cache.SetIfAbsent(ctx, usedRefreshKey(token_.RefreshUUid), token_.FamilyId, expiration(token_.RefreshExpireAt))
+	if err != nil {
+		return nil, err
+	}
+
+	if !firstUse {
+		event.Type = entity.TokenReuseEvent
+		event.Details = map[string]string{"session-id": token_.FamilyId}
+		i.logger.Warn("security event: refresh token reuse detected, revoking session",
+			"method", "RefreshToken",
+			"event", "refresh-token-reuse",
+			"user-id", token_.UserId,
+			"session-id", token_.FamilyId,
+			"refresh-uuid", token_.RefreshUUid)
+
+		if token_.FamilyId != "" {
+			err = i.revokeSession(ctx, token_.FamilyId)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return nil, status.Error(http.StatusUnauthorized, "refresh token already used")
+	}
 
 	refreshToken, err := i.cache.Get(ctx, token_.RefreshUUid)
 	if err != nil {
+		if stat, ok := status.FromError(err); ok && stat.Code() == http.StatusNotFound {
+			return nil, i.explainRevoked(ctx, token_.UserId, status.Error(http.StatusUnauthorized, "refresh token revoked or expired"))
+		}
 		return nil, err
 	}
 
@@ -129,28 +290,50 @@ func (i *iUserService) RefreshToken(ctx context.Context, token *authorization.To
 		return nil, status.Error(http.StatusConflict, "invalid token value")
 	}
 
-	if token.UserId != token_.UserId {
-		return nil, status.Error(http.StatusConflict, "invalid user-id for token")
+	// permissions are reloaded so changes reach the client with the next refresh
+	user, err := i.repository.FindWithUserId(ctx, token_.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Status != nil {
+		switch user.Status.Effective(time.Now()) {
+		case entity.Suspended, entity.Banned:
+			return nil, restrictionError(user.Status.Status, user.Status.Restriction)
+		}
 	}
 
-	newToken, err := i.auth.CreateAccessToken(token_.UserId)
+	newToken, err := i.auth.CreateAccessToken(token_.UserId, token_.FamilyId, user.Permissions)
 	if err != nil {
 		return nil, err
 	}
 
-	err = i.cache.Set(ctx, newToken.AccessUUid, newToken.AccessToken, time.Duration(newToken.ExpireAt))
+	// the previous pair of the session is retired with the rotation
+	session, err := i.loadSession(ctx, newToken.FamilyId)
+	if err != nil && !isNotFound(err) {
+		return nil, err
+	} else if session != nil {
+		err = i.revokeAccess(ctx, session.AccessUUid, session.AccessExpireAt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = i.cache.Remove(ctx, token_.RefreshUUid)
 	if err != nil {
 		return nil, err
 	}
 
-	token_.AccessToken = newToken.AccessToken
-	token_.AccessUUid = newToken.AccessUUid
-	token_.ExpireAt = newToken.ExpireAt
+	err = i.storeTokens(ctx, newToken, session)
+	if err != nil {
+		return nil, err
+	}
 
 	i.logger.Info("token refreshed successfully",
 		"method", "RefreshToken",
-		"user-id", token_.UserId)
-	return token_, nil
+		"user-id", newToken.UserId,
+		"session-id", newToken.FamilyId)
+	return newToken, nil
 }
 
 func (i *iUserService) Validate(ctx context.Context, token *authorization.TokenDetail) (token_ *authorization.TokenDetail, err error) {
@@ -170,8 +353,18 @@ func (i *iUserService) Validate(ctx context.Context, token *authorization.TokenD
 		return nil, status.Error(http.StatusConflict, "invalid access-token")
 	}
 
+	if i.validationMode == StatelessValidation {
+		if i.revocations.IsRevoked(token_.AccessUUid) {
+			return nil, i.explainRevoked(ctx, token_.UserId, status.Error(http.StatusUnauthorized, "access-token revoked"))
+		}
+		return token_, nil
+	}
+
 	accessToken, err := i.cache.Get(ctx, token_.AccessUUid)
 	if err != nil {
+		if isNotFound(err) {
+			return nil, i.explainRevoked(ctx, token_.UserId, err)
+		}
 		return nil, err
 	}
 
@@ -182,6 +375,79 @@ func (i *iUserService) Validate(ctx context.Context, token *authorization.TokenD
 	return token_, nil
 }
 
-func (i *iUserService) GetUser(ctx context.Context, info entity.ContactInfo) (*entity.User, error) {
-	panic("not implemented")
+func (i *iUserService) SignOut(ctx context.Context, token *authorization.TokenDetail) (err error) {
+	i.logger.Info("SignOut request",
+		"method", "SignOut",
+		"user-id", token.UserId)
+
+	token_, err := i.Validate(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	// client tokens have no session, only the token itself is revoked
+	if token_.PrincipalType == authorization.ClientPrincipal {
+		err = i.revokeAccess(ctx, token_.AccessUUid, token_.ExpireAt)
+	} else {
+		err = i.revokeSession(ctx, token_.FamilyId)
+	}
+	if err != nil {
+		return err
+	}
+
+	i.logger.Info("Signed out successfully",
+		"method", "SignOut",
+		"user-id", token_.UserId,
+		"session-id", token_.FamilyId)
+	return nil
+}
+
+func (i *iUserService) ListSessions(ctx context.Context, token *authorization.TokenDetail) (sessions []*entity.Session, err error) {
+	token_, err := i.Validate(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	return i.userSessions(ctx, token_.UserId)
+}
+
+func (i *iUserService) RevokeSession(ctx context.Context, token *authorization.TokenDetail, sessionId string) (err error) {
+	i.logger.Info("RevokeSession request",
+		"method", "RevokeSession",
+		"user-id", token.UserId,
+		"session-id", sessionId)
+
+	token_, err := i.Validate(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	session, err := i.loadSession(ctx, sessionId)
+	if err != nil {
+		return err
+	}
+
+	// revoking sessions of another user looks the same as an unknown session
+	if session.UserId != token_.UserId {
+		return status.Error(http.StatusNotFound, "session not found")
+	}
+
+	return i.revokeSession(ctx, sessionId)
+}
+
+func (i *iUserService) RevokeAllSessions(ctx context.Context, token *authorization.TokenDetail) (err error) {
+	i.logger.Info("RevokeAllSessions request",
+		"method", "RevokeAllSessions",
+		"user-id", token.UserId)
+
+	token_, err := i.Validate(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	return i.revokeUserSessions(ctx, token_.UserId, "")
+}
+
+func (i *iUserService) PublicKeys(ctx context.Context) (keySet *authorization.JsonWebKeySet, err error) {
+	return i.auth.PublicKeys()
 }
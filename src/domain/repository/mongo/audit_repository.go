@@ -0,0 +1,55 @@
+package mongo
+
+import (
+	"context"
+	"github.com/Juno-chat-app/user-service/config"
+	"github.com/Juno-chat-app/user-service/domain/entity"
+	"github.com/Juno-chat-app/user-service/infra/logger"
+	"time"
+)
+
+const DefaultAuditRetention = 365 * 24 * time.Hour
+
+// IAuditRepository is the append-only store of the audit events, in the audit collection
+type IAuditRepository interface {
+	Save(ctx context.Context, event *entity.AuditEvent) (err error)
+	// Find returns the events matching query, newest first, and how many match in total
+	Find(ctx context.Context, query AuditQuery) (events []*entity.AuditEvent, total int64, err error)
+	// EnsureIndexes creates the query indexes and the TTL index removing events after
+	// the retention, a changed retention is applied to the existing TTL index
+	EnsureIndexes(ctx context.Context) (err error)
+	Ping(ctx context.Context) (err error)
+}
+
+// AuditQuery selects audit events, every given field must match
+type AuditQuery struct {
+	// Fields are compared for equality, by their path
+	Fields map[entity.Path]string
+	// From and To limit the time of the events, To is exclusive
+	From  *time.Time
+	To    *time.Time
+	Skip  int64
+	Limit int64
+}
+
+func NewAuditRepository(conf config.PersistConfig, logger logger.ILogger) IAuditRepository {
+	logger.Info("create mongo client",
+		"method", "NewAuditRepository",
+		"host", conf.Host,
+		"port", conf.Port,
+		"uri", conf.ConnectionUri,
+		"retention", conf.AuditRetention)
+
+	repo := iAuditRepository{
+		conf:       conf,
+		retention:  conf.AuditRetention * 24 * time.Hour,
+		logger:     logger,
+		connection: nil,
+	}
+
+	if repo.retention == 0 {
+		repo.retention = DefaultAuditRetention
+	}
+
+	return &repo
+}
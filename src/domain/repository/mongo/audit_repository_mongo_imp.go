@@ -0,0 +1,188 @@
+package mongo
+
+import (
+	"context"
+	"github.com/Juno-chat-app/user-service/config"
+	"github.com/Juno-chat-app/user-service/domain/entity"
+	"github.com/Juno-chat-app/user-service/infra/logger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"time"
+)
+
+const (
+	auditRetentionIndex string = "audit-retention"
+	auditTargetIndex    string = "audit-target-time"
+	auditActorIndex     string = "audit-actor-time"
+
+	indexOptionsConflictCode int32 = 85
+)
+
+type iAuditRepository struct {
+	conf       config.PersistConfig
+	retention  time.Duration
+	logger     logger.ILogger
+	connection *mongo.Client
+}
+
+func (ar *iAuditRepository) Save(ctx context.Context, event *entity.AuditEvent) (err error) {
+	err = ar.establishConnection(ctx)
+	if err != nil {
+		return err
+	}
+
+	dbContext, cancel := context.WithTimeout(ctx, time.Duration(ar.conf.ConnectionTimeout)*time.Second)
+	defer cancel()
+
+	_, err = ar.collection().InsertOne(dbContext, event)
+	if err != nil {
+		return status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	return nil
+}
+
+func (ar *iAuditRepository) Find(ctx context.Context, query AuditQuery) (events []*entity.AuditEvent, total int64, err error) {
+	err = ar.establishConnection(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	dbContext, cancel := context.WithTimeout(ctx, time.Duration(ar.conf.ConnectionTimeout)*time.Second)
+	defer cancel()
+
+	filter := bson.M{}
+	for path, value := range query.Fields {
+		filter[string(path)] = value
+	}
+
+	if query.From != nil || query.To != nil {
+		between := bson.M{}
+		if query.From != nil {
+			between["$gte"] = query.From
+		}
+		if query.To != nil {
+			between["$lt"] = query.To
+		}
+		filter[string(entity.AuditTimePath)] = between
+	}
+
+	total, err = ar.collection().CountDocuments(dbContext, filter)
+	if err != nil {
+		return nil, 0, status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: string(entity.AuditTimePath), Value: -1}}).
+		SetSkip(query.Skip).
+		SetLimit(query.Limit)
+
+	cursor, err := ar.collection().Find(dbContext, filter, findOptions)
+	if err != nil {
+		return nil, 0, status.Error(http.StatusInternalServerError, err.Error())
+	}
+	defer cursor.Close(dbContext)
+
+	events = []*entity.AuditEvent{}
+	for cursor.Next(dbContext) {
+		event := entity.AuditEvent{}
+		err = cursor.Decode(&event)
+		if err != nil {
+			return nil, 0, status.Error(http.StatusInternalServerError, err.Error())
+		}
+		events = append(events, &event)
+	}
+
+	if cursor.Err() != nil {
+		return nil, 0, status.Error(http.StatusInternalServerError, cursor.Err().Error())
+	}
+
+	return events, total, nil
+}
+
+func (ar *iAuditRepository) EnsureIndexes(ctx context.Context) (err error) {
+	err = ar.establishConnection(ctx)
+	if err != nil {
+		return err
+	}
+
+	dbContext, cancel := context.WithTimeout(ctx, time.Duration(ar.conf.ConnectionTimeout)*time.Second)
+	defer cancel()
+
+	expireAfter := int32(ar.retention / time.Second)
+	models := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: string(entity.AuditTimePath), Value: 1}},
+			Options: options.Index().SetName(auditRetentionIndex).SetExpireAfterSeconds(expireAfter),
+		},
+		{
+			Keys: bson.D{
+				{Key: string(entity.AuditTargetPath), Value: 1},
+				{Key: string(entity.AuditTimePath), Value: -1},
+			},
+			Options: options.Index().SetName(auditTargetIndex),
+		},
+		{
+			Keys: bson.D{
+				{Key: string(entity.AuditActorPath), Value: 1},
+				{Key: string(entity.AuditTimePath), Value: -1},
+			},
+			Options: options.Index().SetName(auditActorIndex),
+		},
+	}
+
+	_, err = ar.collection().Indexes().CreateMany(dbContext, models)
+	if e, ok := err.(mongo.CommandError); ok && e.Code == indexOptionsConflictCode {
+		// the retention changed, the TTL of the existing index is updated in place
+		command := bson.D{
+			{Key: "collMod", Value: ar.conf.AuditCollection},
+			{Key: "index", Value: bson.M{"name": auditRetentionIndex, "expireAfterSeconds": expireAfter}},
+		}
+		err = ar.connection.Database(ar.conf.UserDatabase, nil).RunCommand(dbContext, command).Err()
+		if err == nil {
+			_, err = ar.collection().Indexes().CreateMany(dbContext, models[1:])
+		}
+	}
+	if err != nil {
+		ar.logger.Error("got error on creating audit indexes",
+			"method", "EnsureIndexes",
+			"err", err)
+
+		return status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	return nil
+}
+
+func (ar *iAuditRepository) Ping(ctx context.Context) (err error) {
+	err = ar.establishConnection(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = ar.connection.Ping(ctx, nil)
+	if err != nil {
+		return status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	return nil
+}
+
+func (ar *iAuditRepository) collection() *mongo.Collection {
+	return ar.connection.Database(ar.conf.UserDatabase, nil).
+		Collection(ar.conf.AuditCollection, nil)
+}
+
+func (ar *iAuditRepository) establishConnection(ctx context.Context) (err error) {
+	if ar.connection == nil {
+		ar.connection, err = connect(ctx, ar.conf)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
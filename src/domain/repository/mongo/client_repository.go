@@ -0,0 +1,31 @@
+package mongo
+
+import (
+	"context"
+	"github.com/Juno-chat-app/user-service/config"
+	"github.com/Juno-chat-app/user-service/domain/entity"
+	"github.com/Juno-chat-app/user-service/infra/logger"
+)
+
+// IClientRepository stores the machine clients next to the users, in the client collection
+type IClientRepository interface {
+	Save(ctx context.Context, client *entity.Client) (client_ *entity.Client, err error)
+	FindWithClientId(ctx context.Context, clientId string) (client *entity.Client, err error)
+	Ping(ctx context.Context) (err error)
+}
+
+func NewClientRepository(conf config.PersistConfig, logger logger.ILogger) IClientRepository {
+	logger.Info("create mongo client",
+		"method", "NewClientRepository",
+		"host", conf.Host,
+		"port", conf.Port,
+		"uri", conf.ConnectionUri)
+
+	repo := iClientRepository{
+		conf:       conf,
+		logger:     logger,
+		connection: nil,
+	}
+
+	return &repo
+}
@@ -0,0 +1,95 @@
+package mongo
+
+import (
+	"context"
+	"github.com/Juno-chat-app/user-service/config"
+	"github.com/Juno-chat-app/user-service/domain/entity"
+	"github.com/Juno-chat-app/user-service/infra/logger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"time"
+)
+
+type iClientRepository struct {
+	conf       config.PersistConfig
+	logger     logger.ILogger
+	connection *mongo.Client
+}
+
+func (cr *iClientRepository) Save(ctx context.Context, client *entity.Client) (client_ *entity.Client, err error) {
+	err = cr.establishConnection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dbContext, cancel := context.WithTimeout(ctx, time.Duration(cr.conf.ConnectionTimeout)*time.Second)
+	defer cancel()
+
+	_, err = cr.collection().InsertOne(dbContext, client)
+	if err != nil {
+		return nil, status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	return client, nil
+}
+
+func (cr *iClientRepository) FindWithClientId(ctx context.Context, clientId string) (client *entity.Client, err error) {
+	err = cr.establishConnection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dbContext, cancel := context.WithTimeout(ctx, time.Duration(cr.conf.ConnectionTimeout)*time.Second)
+	defer cancel()
+	query := bson.M{
+		"$and": []bson.M{
+			bson.M{string(entity.ClientIdPath): clientId},
+			bson.M{string(entity.DeletedAtPath): nil},
+		},
+	}
+
+	res := cr.collection().FindOne(dbContext, query)
+	if res.Err() == mongo.ErrNoDocuments {
+		return nil, status.Error(http.StatusNotFound, "client not found")
+	}
+
+	client = &entity.Client{}
+	err = res.Decode(client)
+	if err != nil {
+		return nil, status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	return client, nil
+}
+
+func (cr *iClientRepository) Ping(ctx context.Context) (err error) {
+	err = cr.establishConnection(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = cr.connection.Ping(ctx, nil)
+	if err != nil {
+		return status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	return nil
+}
+
+func (cr *iClientRepository) collection() *mongo.Collection {
+	return cr.connection.Database(cr.conf.UserDatabase, nil).
+		Collection(cr.conf.ClientCollection, nil)
+}
+
+func (cr *iClientRepository) establishConnection(ctx context.Context) (err error) {
+	if cr.connection == nil {
+		cr.connection, err = connect(ctx, cr.conf)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
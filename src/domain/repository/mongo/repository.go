@@ -5,13 +5,42 @@ import (
 	"github.com/Juno-chat-app/user-service/config"
 	"github.com/Juno-chat-app/user-service/domain/entity"
 	"github.com/Juno-chat-app/user-service/infra/logger"
+	"time"
 )
 
 type IUserRepository interface {
 	Save(ctx context.Context, user *entity.User) (user_ *entity.User, err error)
 	FindWithUserName(ctx context.Context, userName string) (user *entity.User, err error)
 	FindWithUserId(ctx context.Context, userId string) (user *entity.User, err error)
+	// FindWithUserName and FindWithEmail compare case insensitive, by the canonical forms.
+	// FindWithUserName finds active users and the ones an admin locked out
+	// FindWithEmail finds a not deleted user in any status
+	FindWithEmail(ctx context.Context, email string) (user *entity.User, err error)
+	// FindWithMobile finds a not deleted user in any status
+	FindWithMobile(ctx context.Context, mobile string) (user *entity.User, err error)
+	UpdateStatus(ctx context.Context, userId string, status *entity.UserStatus) (err error)
+	// Update sets the given paths of a not deleted user and returns the updated user, changed
+	// user names and emails are checked for uniqueness
+	Update(ctx context.Context, userId string, fields map[entity.Path]interface{}) (user *entity.User, err error)
+	UpdatePassword(ctx context.Context, userId string, passwordHash string) (err error)
+	// UseMfaCounter stores counter as the last accepted TOTP time step, ok is false when
+	// a code of the same or a later time step was accepted already
+	UseMfaCounter(ctx context.Context, userId string, counter int64) (ok bool, err error)
+	// UseRecoveryCode removes the recovery code hash, ok is false when it was used already
+	UseRecoveryCode(ctx context.Context, userId string, hash string) (ok bool, err error)
 	Remove(ctx context.Context, user *entity.User) (user_ *entity.User, err error)
+	// FindDeletedWithEmail finds the latest deleted user of email which is not purged yet
+	FindDeletedWithEmail(ctx context.Context, email string) (user *entity.User, err error)
+	// Restore undoes the deletion of a user and brings back its status, it fails with
+	// a conflict when the user name or email were taken meanwhile and with gone when
+	// the purge time passed
+	Restore(ctx context.Context, userId string) (user *entity.User, err error)
+	// Purge permanently deletes the users whose purge time passed, users deleted
+	// without purge time are purged when they were deleted before deletedBefore
+	Purge(ctx context.Context, now time.Time, deletedBefore time.Time) (count int64, err error)
+	// EnsureIndexes creates the unique indexes of the canonical user names and emails,
+	// users stored before the canonical fields existed get them first
+	EnsureIndexes(ctx context.Context) (err error)
 	Ping(ctx context.Context) (err error)
 }
 
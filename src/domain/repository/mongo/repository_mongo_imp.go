@@ -11,9 +11,17 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"google.golang.org/grpc/status"
 	"net/http"
+	"strings"
 	"time"
 )
 
+const (
+	userNameIndex string = "unique-canonical-user-name"
+	emailIndex    string = "unique-canonical-email"
+
+	duplicateKeyCode int = 11000
+)
+
 type iUserRepository struct {
 	conf       config.PersistConfig
 	logger     logger.ILogger
@@ -26,7 +34,10 @@ func (ur *iUserRepository) Save(ctx context.Context, user *entity.User) (userId
 		return nil, err
 	}
 
-	if exist, err := ur.isUserNameOrEmailDuplicated(ctx, user.UserName, user.ContactInfo.Email); err != nil {
+	user.CanonicalUserName = entity.CanonicalUserName(user.UserName)
+	user.CanonicalEmail = entity.CanonicalEmail(user.ContactInfo.Email)
+
+	if exist, err := ur.isUserNameOrEmailDuplicated(ctx, user.CanonicalUserName, user.CanonicalEmail); err != nil {
 		return nil, err
 	} else if !exist {
 		dbContext, cancel := context.WithTimeout(ctx, time.Duration(ur.conf.ConnectionTimeout)*time.Second)
@@ -36,6 +47,10 @@ func (ur *iUserRepository) Save(ctx context.Context, user *entity.User) (userId
 			Collection(ur.conf.UserCollection, nil).
 			InsertOne(dbContext, user)
 
+		// the unique indexes catch the sign ups racing past the check above
+		if isDuplicateKey(err) {
+			return nil, duplicateKeyError(err)
+		}
 		if err != nil {
 			return nil, status.Error(http.StatusInternalServerError, err.Error())
 		}
@@ -56,8 +71,8 @@ func (ur *iUserRepository) FindWithUserName(ctx context.Context, userName string
 	defer cancel()
 	query := bson.M{
 		"$and": []bson.M{
-			bson.M{string(entity.UserNamePath): userName},
-			bson.M{string(entity.StatusPath): entity.Active},
+			bson.M{string(entity.CanonicalUserNamePath): entity.CanonicalUserName(userName)},
+			bson.M{string(entity.StatusPath): bson.M{"$in": []entity.Status{entity.Active, entity.Suspended, entity.Banned}}},
 			bson.M{string(entity.DeletedAtPath): nil},
 		},
 	}
@@ -65,6 +80,9 @@ func (ur *iUserRepository) FindWithUserName(ctx context.Context, userName string
 	res := ur.connection.Database(ur.conf.UserDatabase, nil).
 		Collection(ur.conf.UserCollection, nil).
 		FindOne(dbContext, query)
+	if res.Err() == mongo.ErrNoDocuments {
+		return nil, status.Error(http.StatusNotFound, "user not found")
+	}
 
 	usr := entity.User{}
 	err = res.Decode(&usr)
@@ -89,6 +107,138 @@ func (ur *iUserRepository) FindWithUserId(ctx context.Context, userId string) (u
 	res := ur.connection.Database(ur.conf.UserDatabase, nil).
 		Collection(ur.conf.UserCollection, nil).
 		FindOne(dbContext, query)
+	if res.Err() == mongo.ErrNoDocuments {
+		return nil, status.Error(http.StatusNotFound, "user not found")
+	}
+
+	usr := entity.User{}
+	err = res.Decode(&usr)
+	if err != nil {
+		return nil, status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	return &usr, nil
+}
+
+func (ur *iUserRepository) FindWithEmail(ctx context.Context, email string) (user *entity.User, err error) {
+	return ur.findOne(ctx, entity.CanonicalEmailPath, entity.CanonicalEmail(email))
+}
+
+func (ur *iUserRepository) FindWithMobile(ctx context.Context, mobile string) (user *entity.User, err error) {
+	return ur.findOne(ctx, entity.MobilePath, mobile)
+}
+
+// findOne finds the not deleted user with value at path
+func (ur *iUserRepository) findOne(ctx context.Context, path entity.Path, value string) (user *entity.User, err error) {
+	err = ur.establishConnection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dbContext, cancel := context.WithTimeout(ctx, time.Duration(ur.conf.ConnectionTimeout)*time.Second)
+	defer cancel()
+	query := bson.M{
+		"$and": []bson.M{
+			bson.M{string(path): value},
+			bson.M{string(entity.DeletedAtPath): nil},
+		},
+	}
+
+	res := ur.connection.Database(ur.conf.UserDatabase, nil).
+		Collection(ur.conf.UserCollection, nil).
+		FindOne(dbContext, query)
+	if res.Err() == mongo.ErrNoDocuments {
+		return nil, status.Error(http.StatusNotFound, "user not found")
+	}
+
+	usr := entity.User{}
+	err = res.Decode(&usr)
+	if err != nil {
+		return nil, status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	return &usr, nil
+}
+
+func (ur *iUserRepository) UpdateStatus(ctx context.Context, userId string, userStatus *entity.UserStatus) (err error) {
+	err = ur.establishConnection(ctx)
+	if err != nil {
+		return err
+	}
+
+	dbContext, cancel := context.WithTimeout(ctx, time.Duration(ur.conf.ConnectionTimeout)*time.Second)
+	defer cancel()
+
+	update := bson.M{
+		"$set": bson.M{
+			string(entity.UserStatusPath): userStatus,
+			string(entity.UpdatedAtPath):  userStatus.UpdatedAt,
+		},
+	}
+
+	res, err := ur.connection.Database(ur.conf.UserDatabase, nil).
+		Collection(ur.conf.UserCollection, nil).
+		UpdateOne(dbContext, bson.M{string(entity.UserIdPath): userId}, update, nil)
+	if err != nil {
+		return status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	if res.MatchedCount == 0 {
+		return status.Error(http.StatusNotFound, "user not found")
+	}
+
+	return nil
+}
+
+func (ur *iUserRepository) Update(ctx context.Context, userId string, fields map[entity.Path]interface{}) (user *entity.User, err error) {
+	err = ur.establishConnection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	set := bson.M{
+		string(entity.UpdatedAtPath): time.Now().UTC(),
+	}
+	for path, value := range fields {
+		set[string(path)] = value
+	}
+
+	// changed user names and emails take their canonical forms along
+	if value, ok := fields[entity.UserNamePath]; ok {
+		set[string(entity.CanonicalUserNamePath)] = entity.CanonicalUserName(value.(string))
+	}
+	if value, ok := fields[entity.EmailPath]; ok {
+		set[string(entity.CanonicalEmailPath)] = entity.CanonicalEmail(value.(string))
+	}
+
+	for _, path := range []entity.Path{entity.CanonicalUserNamePath, entity.CanonicalEmailPath} {
+		if value, ok := set[string(path)]; ok {
+			err = ur.isTakenByOther(ctx, userId, path, value)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	dbContext, cancel := context.WithTimeout(ctx, time.Duration(ur.conf.ConnectionTimeout)*time.Second)
+	defer cancel()
+
+	query := bson.M{
+		"$and": []bson.M{
+			bson.M{string(entity.UserIdPath): userId},
+			bson.M{string(entity.DeletedAtPath): nil},
+		},
+	}
+
+	res := ur.connection.Database(ur.conf.UserDatabase, nil).
+		Collection(ur.conf.UserCollection, nil).
+		FindOneAndUpdate(dbContext, query, bson.M{"$set": set}, options.FindOneAndUpdate().SetReturnDocument(options.After))
+	if res.Err() == mongo.ErrNoDocuments {
+		return nil, status.Error(http.StatusNotFound, "user not found")
+	}
+	if isDuplicateKey(res.Err()) {
+		return nil, duplicateKeyError(res.Err())
+	}
 
 	usr := entity.User{}
 	err = res.Decode(&usr)
@@ -99,6 +249,86 @@ func (ur *iUserRepository) FindWithUserId(ctx context.Context, userId string) (u
 	return &usr, nil
 }
 
+func (ur *iUserRepository) UpdatePassword(ctx context.Context, userId string, passwordHash string) (err error) {
+	err = ur.establishConnection(ctx)
+	if err != nil {
+		return err
+	}
+
+	dbContext, cancel := context.WithTimeout(ctx, time.Duration(ur.conf.ConnectionTimeout)*time.Second)
+	defer cancel()
+
+	tm := time.Now().UTC()
+	update := bson.M{
+		"$set": bson.M{
+			string(entity.PasswordPath):  passwordHash,
+			string(entity.UpdatedAtPath): &tm,
+		},
+	}
+
+	res, err := ur.connection.Database(ur.conf.UserDatabase, nil).
+		Collection(ur.conf.UserCollection, nil).
+		UpdateOne(dbContext, bson.M{string(entity.UserIdPath): userId}, update, nil)
+	if err != nil {
+		return status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	if res.MatchedCount == 0 {
+		return status.Error(http.StatusNotFound, "user not found")
+	}
+
+	return nil
+}
+
+func (ur *iUserRepository) UseMfaCounter(ctx context.Context, userId string, counter int64) (ok bool, err error) {
+	query := bson.M{
+		string(entity.UserIdPath):         userId,
+		string(entity.DeletedAtPath):      nil,
+		string(entity.MfaLastCounterPath): bson.M{"$lt": counter},
+	}
+
+	return ur.updateMfa(ctx, query, bson.M{
+		"$set": bson.M{
+			string(entity.MfaLastCounterPath): counter,
+			string(entity.UpdatedAtPath):      time.Now().UTC(),
+		},
+	})
+}
+
+func (ur *iUserRepository) UseRecoveryCode(ctx context.Context, userId string, hash string) (ok bool, err error) {
+	query := bson.M{
+		string(entity.UserIdPath):           userId,
+		string(entity.DeletedAtPath):        nil,
+		string(entity.MfaRecoveryCodesPath): hash,
+	}
+
+	return ur.updateMfa(ctx, query, bson.M{
+		"$pull": bson.M{string(entity.MfaRecoveryCodesPath): hash},
+		"$set":  bson.M{string(entity.UpdatedAtPath): time.Now().UTC()},
+	})
+}
+
+// updateMfa applies update to the user matching query, the query holds the condition
+// of the second factor so concurrent uses of one code modify the user only once
+func (ur *iUserRepository) updateMfa(ctx context.Context, query bson.M, update bson.M) (ok bool, err error) {
+	err = ur.establishConnection(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	dbContext, cancel := context.WithTimeout(ctx, time.Duration(ur.conf.ConnectionTimeout)*time.Second)
+	defer cancel()
+
+	res, err := ur.connection.Database(ur.conf.UserDatabase, nil).
+		Collection(ur.conf.UserCollection, nil).
+		UpdateOne(dbContext, query, update, nil)
+	if err != nil {
+		return false, status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	return res.ModifiedCount == 1, nil
+}
+
 func (ur *iUserRepository) Remove(ctx context.Context, user *entity.User) (user_ *entity.User, err error) {
 	err = ur.establishConnection(ctx)
 	if err != nil {
@@ -111,6 +341,9 @@ func (ur *iUserRepository) Remove(ctx context.Context, user *entity.User) (user_
 	tm := time.Now().UTC()
 
 	user.Status.UpdatedAt = &tm
+	if user.Status.Status != entity.Inactive {
+		user.Status.DeletedStatus = user.Status.Status
+	}
 	user.Status.Status = entity.Inactive
 	user.UpdatedAt = &tm
 	user.DeletedAt = &tm
@@ -126,6 +359,256 @@ func (ur *iUserRepository) Remove(ctx context.Context, user *entity.User) (user_
 	return user, nil
 }
 
+func (ur *iUserRepository) FindDeletedWithEmail(ctx context.Context, email string) (user *entity.User, err error) {
+	err = ur.establishConnection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dbContext, cancel := context.WithTimeout(ctx, time.Duration(ur.conf.ConnectionTimeout)*time.Second)
+	defer cancel()
+	query := bson.M{
+		"$and": []bson.M{
+			bson.M{string(entity.CanonicalEmailPath): entity.CanonicalEmail(email)},
+			bson.M{string(entity.DeletedAtPath): bson.M{"$ne": nil}},
+			bson.M{"$or": []bson.M{
+				bson.M{string(entity.PurgeAtPath): nil},
+				bson.M{string(entity.PurgeAtPath): bson.M{"$gt": time.Now().UTC()}},
+			}},
+		},
+	}
+
+	res := ur.connection.Database(ur.conf.UserDatabase, nil).
+		Collection(ur.conf.UserCollection, nil).
+		FindOne(dbContext, query, options.FindOne().SetSort(bson.M{string(entity.DeletedAtPath): -1}))
+	if res.Err() == mongo.ErrNoDocuments {
+		return nil, status.Error(http.StatusNotFound, "user not found")
+	}
+
+	usr := entity.User{}
+	err = res.Decode(&usr)
+	if err != nil {
+		return nil, status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	return &usr, nil
+}
+
+func (ur *iUserRepository) Restore(ctx context.Context, userId string) (user *entity.User, err error) {
+	err = ur.establishConnection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deleted, err := ur.FindWithUserId(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if deleted.DeletedAt == nil {
+		return nil, status.Error(http.StatusConflict, "user is not deleted")
+	}
+
+	// the grace period is over, the purge job just did not get to the user yet
+	tm := time.Now().UTC()
+	if deleted.PurgeAt != nil && !deleted.PurgeAt.After(tm) {
+		return nil, status.Error(http.StatusGone, "user is purged")
+	}
+
+	// users deleted before the canonical fields existed get them with the restore
+	canonicalUserName := entity.CanonicalUserName(deleted.UserName)
+	err = ur.isTakenByOther(ctx, userId, entity.CanonicalUserNamePath, canonicalUserName)
+	if err != nil {
+		return nil, err
+	}
+
+	canonicalEmail := ""
+	if deleted.ContactInfo != nil {
+		canonicalEmail = entity.CanonicalEmail(deleted.ContactInfo.Email)
+		err = ur.isTakenByOther(ctx, userId, entity.CanonicalEmailPath, canonicalEmail)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	dbContext, cancel := context.WithTimeout(ctx, time.Duration(ur.conf.ConnectionTimeout)*time.Second)
+	defer cancel()
+
+	// suspended and banned users stay restricted, users deleted before the
+	// deleted status was kept were active
+	status_ := entity.Active
+	if deleted.Status != nil && deleted.Status.DeletedStatus != "" {
+		status_ = deleted.Status.DeletedStatus
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			string(entity.StatusPath):          status_,
+			string(entity.StatusUpdatedAtPath): &tm,
+			string(entity.UpdatedAtPath):       &tm,
+			string(entity.DeletedAtPath):       nil,
+			string(entity.PurgeAtPath):         nil,
+
+			string(entity.CanonicalUserNamePath): canonicalUserName,
+			string(entity.CanonicalEmailPath):    canonicalEmail,
+		},
+		"$unset": bson.M{
+			string(entity.DeletedStatusPath): "",
+		},
+	}
+	query := bson.M{
+		"$and": []bson.M{
+			bson.M{string(entity.UserIdPath): userId},
+			bson.M{string(entity.DeletedAtPath): bson.M{"$ne": nil}},
+			bson.M{"$or": []bson.M{
+				bson.M{string(entity.PurgeAtPath): nil},
+				bson.M{string(entity.PurgeAtPath): bson.M{"$gt": tm}},
+			}},
+		},
+	}
+
+	res := ur.connection.Database(ur.conf.UserDatabase, nil).
+		Collection(ur.conf.UserCollection, nil).
+		FindOneAndUpdate(dbContext, query, update, options.FindOneAndUpdate().SetReturnDocument(options.After))
+	if res.Err() == mongo.ErrNoDocuments {
+		return nil, status.Error(http.StatusNotFound, "user not found")
+	}
+	if isDuplicateKey(res.Err()) {
+		return nil, duplicateKeyError(res.Err())
+	}
+
+	usr := entity.User{}
+	err = res.Decode(&usr)
+	if err != nil {
+		return nil, status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	return &usr, nil
+}
+
+func (ur *iUserRepository) Purge(ctx context.Context, now time.Time, deletedBefore time.Time) (count int64, err error) {
+	err = ur.establishConnection(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	dbContext, cancel := context.WithTimeout(ctx, time.Duration(ur.conf.ConnectionTimeout)*time.Second)
+	defer cancel()
+	query := bson.M{
+		"$or": []bson.M{
+			bson.M{string(entity.PurgeAtPath): bson.M{"$lte": now}},
+			bson.M{
+				string(entity.PurgeAtPath):   nil,
+				string(entity.DeletedAtPath): bson.M{"$lte": deletedBefore},
+			},
+		},
+	}
+
+	res, err := ur.connection.Database(ur.conf.UserDatabase, nil).
+		Collection(ur.conf.UserCollection, nil).
+		DeleteMany(dbContext, query)
+	if err != nil {
+		return 0, status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	return res.DeletedCount, nil
+}
+
+func (ur *iUserRepository) EnsureIndexes(ctx context.Context) (err error) {
+	err = ur.establishConnection(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = ur.backfillCanonical(ctx)
+	if err != nil {
+		return err
+	}
+
+	dbContext, cancel := context.WithTimeout(ctx, time.Duration(ur.conf.ConnectionTimeout)*time.Second)
+	defer cancel()
+
+	// deleted users keep their names until they are purged, only the names of the
+	// others must be unique
+	notDeleted := func(path entity.Path) bson.M {
+		return bson.M{
+			string(entity.DeletedAtPath): bson.M{"$type": "null"},
+			string(path):                 bson.M{"$exists": true},
+		}
+	}
+	models := []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: string(entity.CanonicalUserNamePath), Value: 1}},
+			Options: options.Index().SetName(userNameIndex).SetUnique(true).
+				SetPartialFilterExpression(notDeleted(entity.CanonicalUserNamePath)),
+		},
+		{
+			Keys: bson.D{{Key: string(entity.CanonicalEmailPath), Value: 1}},
+			Options: options.Index().SetName(emailIndex).SetUnique(true).
+				SetPartialFilterExpression(notDeleted(entity.CanonicalEmailPath)),
+		},
+	}
+
+	_, err = ur.connection.Database(ur.conf.UserDatabase, nil).
+		Collection(ur.conf.UserCollection, nil).
+		Indexes().
+		CreateMany(dbContext, models)
+	if err != nil {
+		ur.logger.Error("got error on creating indexes, users sharing a name or email have to be resolved by hand",
+			"method", "EnsureIndexes",
+			"err", err)
+
+		return status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	return nil
+}
+
+// backfillCanonical sets the canonical fields of the users stored before they existed
+func (ur *iUserRepository) backfillCanonical(ctx context.Context) (err error) {
+	collection := ur.connection.Database(ur.conf.UserDatabase, nil).
+		Collection(ur.conf.UserCollection, nil)
+
+	cursor, err := collection.Find(ctx, bson.M{string(entity.CanonicalUserNamePath): bson.M{"$exists": false}})
+	if err != nil {
+		return status.Error(http.StatusInternalServerError, err.Error())
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		usr := entity.User{}
+		err = cursor.Decode(&usr)
+		if err != nil {
+			return status.Error(http.StatusInternalServerError, err.Error())
+		}
+
+		email := ""
+		if usr.ContactInfo != nil {
+			email = usr.ContactInfo.Email
+		}
+
+		update := bson.M{
+			"$set": bson.M{
+				string(entity.CanonicalUserNamePath): entity.CanonicalUserName(usr.UserName),
+				string(entity.CanonicalEmailPath):    entity.CanonicalEmail(email),
+			},
+		}
+
+		dbContext, cancel := context.WithTimeout(ctx, time.Duration(ur.conf.ConnectionTimeout)*time.Second)
+		_, err = collection.UpdateOne(dbContext, bson.M{string(entity.UserIdPath): usr.UserId}, update)
+		cancel()
+		if err != nil {
+			return status.Error(http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	if err = cursor.Err(); err != nil {
+		return status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	return nil
+}
+
 func (ur *iUserRepository) Ping(ctx context.Context) (err error) {
 	err = ur.establishConnection(ctx)
 	if err != nil {
@@ -144,10 +627,12 @@ func (ur *iUserRepository) isUserNameOrEmailDuplicated(ctx context.Context, user
 	dbContext, cancel := context.WithTimeout(ctx, time.Duration(ur.conf.ConnectionTimeout)*time.Second)
 	defer cancel()
 	query := bson.M{
-		"$or": []bson.M{
-			bson.M{string(entity.UserNamePath): userName},
-			bson.M{string(entity.EmailPath): email},
-			bson.M{string(entity.StatusPath): entity.Active},
+		"$and": []bson.M{
+			bson.M{"$or": []bson.M{
+				bson.M{string(entity.CanonicalUserNamePath): userName},
+				bson.M{string(entity.CanonicalEmailPath): email},
+			}},
+			bson.M{string(entity.DeletedAtPath): nil},
 		},
 	}
 
@@ -169,41 +654,104 @@ func (ur *iUserRepository) isUserNameOrEmailDuplicated(ctx context.Context, user
 		return false, status.Error(http.StatusInternalServerError, err.Error())
 	}
 
-	if data.UserName == userName && data.DeletedAt == nil {
+	if data.CanonicalUserName == userName {
 		return true, status.Error(http.StatusConflict, "User name exist")
-	} else if data.ContactInfo.Email == email && data.DeletedAt == nil {
-		return true, status.Error(http.StatusConflict, "Email already registered")
 	}
 
-	return false, nil
+	return true, status.Error(http.StatusConflict, "Email already registered")
 }
 
-func (ur *iUserRepository) establishConnection(ctx context.Context) (err error) {
-	if ur.connection == nil {
-		var (
-			auth string
-			uri  string
-		)
+// isTakenByOther fails with a conflict when a not deleted user other than userId has value at path
+func (ur *iUserRepository) isTakenByOther(ctx context.Context, userId string, path entity.Path, value interface{}) (err error) {
+	dbContext, cancel := context.WithTimeout(ctx, time.Duration(ur.conf.ConnectionTimeout)*time.Second)
+	defer cancel()
+	query := bson.M{
+		"$and": []bson.M{
+			bson.M{string(path): value},
+			bson.M{string(entity.UserIdPath): bson.M{"$ne": userId}},
+			bson.M{string(entity.DeletedAtPath): nil},
+		},
+	}
 
-		if ur.conf.UserName != "" && ur.conf.Password != "" {
-			auth = fmt.Sprintf("%v:%v@", ur.conf.UserName, ur.conf.Password)
-		}
-		if ur.conf.ConnectionUri != "" {
-			uri = fmt.Sprintf("mongodb://%v%v", auth, ur.conf.ConnectionUri)
-		} else if ur.conf.Host == "" || ur.conf.Port == 0 {
-			return status.Error(http.StatusInternalServerError, "mongo connection is not specified")
-		} else {
-			uri = fmt.Sprintf("mongodb://%v%v:%d", auth, ur.conf.Host, ur.conf.Port)
+	count, err := ur.connection.Database(ur.conf.UserDatabase, nil).
+		Collection(ur.conf.UserCollection, nil).
+		CountDocuments(dbContext, query)
+	if err != nil {
+		return status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	if count == 0 {
+		return nil
+	}
+
+	if path == entity.CanonicalUserNamePath {
+		return status.Error(http.StatusConflict, "User name exist")
+	}
+	return status.Error(http.StatusConflict, "Email already registered")
+}
+
+// isDuplicateKey reports whether err is the violation of a unique index
+func isDuplicateKey(err error) bool {
+	switch e := err.(type) {
+	case mongo.WriteException:
+		for _, writeError := range e.WriteErrors {
+			if writeError.Code == duplicateKeyCode {
+				return true
+			}
 		}
+	case mongo.CommandError:
+		return int(e.Code) == duplicateKeyCode
+	}
+
+	return false
+}
 
-		connectionCtx, _ := context.WithTimeout(ctx, time.Duration(ur.conf.ConnectionTimeout)*time.Second)
-		clientOptions := options.Client().ApplyURI(uri)
+// duplicateKeyError tells by the violated index which value is taken
+func duplicateKeyError(err error) error {
+	if strings.Contains(err.Error(), userNameIndex) {
+		return status.Error(http.StatusConflict, "User name exist")
+	}
+	return status.Error(http.StatusConflict, "Email already registered")
+}
 
-		ur.connection, err = mongo.Connect(connectionCtx, clientOptions)
+func (ur *iUserRepository) establishConnection(ctx context.Context) (err error) {
+	if ur.connection == nil {
+		ur.connection, err = connect(ctx, ur.conf)
 		if err != nil {
-			return status.Error(http.StatusInternalServerError, err.Error())
+			return err
 		}
 	}
 
 	return nil
 }
+
+// connect creates a client for the configured mongo server, the repositories
+// of every collection connect the same way
+func connect(ctx context.Context, conf config.PersistConfig) (connection *mongo.Client, err error) {
+	var (
+		auth string
+		uri  string
+	)
+
+	if conf.UserName != "" && conf.Password != "" {
+		auth = fmt.Sprintf("%v:%v@", conf.UserName, conf.Password)
+	}
+	if conf.ConnectionUri != "" {
+		uri = fmt.Sprintf("mongodb://%v%v", auth, conf.ConnectionUri)
+	} else if conf.Host == "" || conf.Port == 0 {
+		return nil, status.Error(http.StatusInternalServerError, "mongo connection is not specified")
+	} else {
+		uri = fmt.Sprintf("mongodb://%v%v:%d", auth, conf.Host, conf.Port)
+	}
+
+	connectionCtx, cancel := context.WithTimeout(ctx, time.Duration(conf.ConnectionTimeout)*time.Second)
+	defer cancel()
+	clientOptions := options.Client().ApplyURI(uri)
+
+	connection, err = mongo.Connect(connectionCtx, clientOptions)
+	if err != nil {
+		return nil, status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	return connection, nil
+}
@@ -8,6 +8,9 @@ import (
 	"github.com/Juno-chat-app/user-service/infra/logger"
 	"github.com/stretchr/testify/require"
 	"github.com/twinj/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
 	"os"
 	"testing"
 	"time"
@@ -17,6 +20,9 @@ var (
 	log  logger.ILogger
 	conf *config.Configuration
 	repo mongo.IUserRepository
+
+	clients mongo.IClientRepository
+	audits  mongo.IAuditRepository
 )
 
 func TestMain(m *testing.M) {
@@ -31,6 +37,8 @@ func TestMain(m *testing.M) {
 	}
 
 	repo = mongo.NewUserRepository(conf.CQRSConfig.PersistConfig, log)
+	clients = mongo.NewClientRepository(conf.CQRSConfig.PersistConfig, log)
+	audits = mongo.NewAuditRepository(conf.CQRSConfig.PersistConfig, log)
 
 	code := m.Run()
 	os.Exit(code)
@@ -42,6 +50,42 @@ func Test_Ping(t *testing.T) {
 	require.Nil(t, err)
 }
 
+func Test_EnsureIndexes_Canonical(t *testing.T) {
+	ctx := context.Background()
+	err := repo.EnsureIndexes(ctx)
+	require.Nil(t, err)
+
+	user := newUser()
+	user.UserName = "Test-Canonical"
+	user.ContactInfo.Email = "Test-Canonical@juno.com"
+	user, err = repo.Save(ctx, user)
+	require.Nil(t, err)
+
+	usr, err := repo.FindWithUserName(ctx, "TEST-canonical")
+	require.Nil(t, err)
+	require.Equal(t, user.UserId, usr.UserId)
+	require.Equal(t, "Test-Canonical", usr.UserName)
+
+	usr, err = repo.FindWithEmail(ctx, "test-canonical@JUNO.com")
+	require.Nil(t, err)
+	require.Equal(t, user.UserId, usr.UserId)
+
+	// names and emails only differing in case are taken
+	other := newUser()
+	other.UserName = "test-canonical"
+	other.ContactInfo.Email = "other-canonical@juno.com"
+	_, err = repo.Save(ctx, other)
+	require.NotNil(t, err)
+
+	other.UserName = "other-canonical"
+	other.ContactInfo.Email = "TEST-CANONICAL@juno.com"
+	_, err = repo.Save(ctx, other)
+	require.NotNil(t, err)
+
+	_, err = repo.Remove(ctx, user)
+	require.Nil(t, err)
+}
+
 func Test_Save_FindWithUserNamePassword_FindWithUserId_Remove(t *testing.T) {
 	user := newUser()
 	ctx := context.Background()
@@ -58,11 +102,215 @@ func Test_Save_FindWithUserNamePassword_FindWithUserId_Remove(t *testing.T) {
 	require.Nil(t, err)
 	require.Equal(t, user.UserId, usr2.UserId)
 
+	usr3, err := repo.FindWithEmail(ctx, user.ContactInfo.Email)
+	require.Nil(t, err)
+	require.Equal(t, user.UserId, usr3.UserId)
+
+	_, err = repo.FindWithMobile(ctx, "+4900000000")
+	require.NotNil(t, err)
+
+	user.Status.ActivationCode = "code"
+	err = repo.UpdateStatus(ctx, user.UserId, user.Status)
+	require.Nil(t, err)
+
+	usr4, err := repo.FindWithUserId(ctx, user.UserId)
+	require.Nil(t, err)
+	require.Equal(t, "code", usr4.Status.ActivationCode)
+
+	err = repo.UpdatePassword(ctx, user.UserId, "new-hash")
+	require.Nil(t, err)
+
+	usr5, err := repo.FindWithUserId(ctx, user.UserId)
+	require.Nil(t, err)
+	require.Equal(t, "new-hash", usr5.Password)
+
 	user, err = repo.Remove(ctx, user)
 	require.Nil(t, err)
 	require.NotNil(t, user.DeletedAt)
 }
 
+func Test_Client_Save_FindWithClientId(t *testing.T) {
+	ctx := context.Background()
+	ti := time.Now().UTC()
+	client := entity.Client{
+		ClientId:        uuid.NewV4().String(),
+		Name:            "message-service",
+		SecretHash:      "hash",
+		Scopes:          []string{"user:read"},
+		CreatedAt:       &ti,
+		UpdatedAt:       &ti,
+		DocumentVersion: entity.DocumentVersion,
+	}
+
+	_, err := clients.Save(ctx, &client)
+	require.Nil(t, err)
+
+	client_, err := clients.FindWithClientId(ctx, client.ClientId)
+	require.Nil(t, err)
+	require.Equal(t, client.Name, client_.Name)
+	require.Equal(t, client.Scopes, client_.Scopes)
+
+	_, err = clients.FindWithClientId(ctx, uuid.NewV4().String())
+	require.NotNil(t, err)
+}
+
+func Test_Audit_Save_Find(t *testing.T) {
+	ctx := context.Background()
+	err := audits.EnsureIndexes(ctx)
+	require.Nil(t, err)
+
+	target := uuid.NewV4().String()
+	start := time.Now().UTC().Add(-time.Minute)
+	for index, outcome := range []entity.AuditOutcome{entity.Failed, entity.Failed, entity.Succeeded} {
+		ti := start.Add(time.Duration(index) * time.Second)
+		err = audits.Save(ctx, &entity.AuditEvent{
+			EventId:         uuid.NewV4().String(),
+			Type:            entity.SignInEvent,
+			Actor:           target,
+			Target:          target,
+			Outcome:         outcome,
+			Time:            &ti,
+			DocumentVersion: entity.DocumentVersion,
+		})
+		require.Nil(t, err)
+	}
+
+	events, total, err := audits.Find(ctx, mongo.AuditQuery{
+		Fields: map[entity.Path]string{entity.AuditTargetPath: target},
+		Limit:  2,
+	})
+	require.Nil(t, err)
+	require.Equal(t, int64(3), total)
+	require.Len(t, events, 2)
+	require.Equal(t, entity.Succeeded, events[0].Outcome)
+
+	from := start.Add(time.Second)
+	events, total, err = audits.Find(ctx, mongo.AuditQuery{
+		Fields: map[entity.Path]string{
+			entity.AuditTargetPath:  target,
+			entity.AuditOutcomePath: string(entity.Failed),
+		},
+		From:  &from,
+		Limit: 10,
+	})
+	require.Nil(t, err)
+	require.Equal(t, int64(1), total)
+	require.Len(t, events, 1)
+}
+
+func Test_Remove_Purge(t *testing.T) {
+	ctx := context.Background()
+	user := newUser()
+	user.UserName = "test-purge"
+	user.ContactInfo.Email = "test-purge@juno.com"
+
+	_, err := repo.Save(ctx, user)
+	require.Nil(t, err)
+
+	purgeAt := time.Now().UTC().Add(time.Hour)
+	user.PurgeAt = &purgeAt
+	_, err = repo.Remove(ctx, user)
+	require.Nil(t, err)
+
+	// the grace period is not over yet
+	_, err = repo.Purge(ctx, time.Now().UTC(), time.Now().UTC().Add(-time.Hour))
+	require.Nil(t, err)
+	_, err = repo.FindWithUserId(ctx, user.UserId)
+	require.Nil(t, err)
+
+	count, err := repo.Purge(ctx, purgeAt.Add(time.Second), time.Now().UTC().Add(-time.Hour))
+	require.Nil(t, err)
+	require.True(t, count >= 1)
+	_, err = repo.FindWithUserId(ctx, user.UserId)
+	require.NotNil(t, err)
+}
+
+func Test_Remove_Restore_Purged(t *testing.T) {
+	ctx := context.Background()
+	user := newUser()
+	user.UserName = "test-restore-purged"
+	user.ContactInfo.Email = "test-restore-purged@juno.com"
+
+	_, err := repo.Save(ctx, user)
+	require.Nil(t, err)
+
+	purgeAt := time.Now().UTC().Add(-time.Second)
+	user.PurgeAt = &purgeAt
+	_, err = repo.Remove(ctx, user)
+	require.Nil(t, err)
+
+	// the purge job did not run yet, the user is gone anyway
+	_, err = repo.Restore(ctx, user.UserId)
+	require.Equal(t, codes.Code(http.StatusGone), status.Code(err))
+
+	_, err = repo.Purge(ctx, time.Now().UTC(), time.Now().UTC().Add(-time.Hour))
+	require.Nil(t, err)
+}
+
+func Test_Remove_Restore_Status(t *testing.T) {
+	ctx := context.Background()
+	user := newUser()
+	user.UserName = "test-restore-banned"
+	user.ContactInfo.Email = "test-restore-banned@juno.com"
+	user.Status.Status = entity.Banned
+	user.Status.Restriction = &entity.Restriction{Reason: "spam", Actor: "admin"}
+
+	_, err := repo.Save(ctx, user)
+	require.Nil(t, err)
+
+	_, err = repo.Remove(ctx, user)
+	require.Nil(t, err)
+
+	// a deletion does not lift the ban
+	restored, err := repo.Restore(ctx, user.UserId)
+	require.Nil(t, err)
+	require.Nil(t, restored.DeletedAt)
+	require.Equal(t, entity.Banned, restored.Status.Status)
+	require.Equal(t, "spam", restored.Status.Restriction.Reason)
+	require.Equal(t, entity.Status(""), restored.Status.DeletedStatus)
+
+	_, err = repo.Remove(ctx, restored)
+	require.Nil(t, err)
+}
+
+func Test_UseMfaCounter_UseRecoveryCode(t *testing.T) {
+	ctx := context.Background()
+	user := newUser()
+	user.UserName = "test-mfa-use"
+	user.ContactInfo.Email = "test-mfa-use@juno.com"
+	user.Mfa = &entity.Mfa{Enabled: true, Secret: "secret", LastCounter: 10, RecoveryCodes: []string{"a", "b"}}
+
+	_, err := repo.Save(ctx, user)
+	require.Nil(t, err)
+
+	// a time step is accepted once and never before the last one
+	ok, err := repo.UseMfaCounter(ctx, user.UserId, 11)
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	for _, counter := range []int64{11, 9} {
+		ok, err = repo.UseMfaCounter(ctx, user.UserId, counter)
+		require.Nil(t, err)
+		require.False(t, ok)
+	}
+
+	ok, err = repo.UseRecoveryCode(ctx, user.UserId, "a")
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	ok, err = repo.UseRecoveryCode(ctx, user.UserId, "a")
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	found, err := repo.FindWithUserId(ctx, user.UserId)
+	require.Nil(t, err)
+	require.Equal(t, int64(11), found.Mfa.LastCounter)
+	require.Equal(t, []string{"b"}, found.Mfa.RecoveryCodes)
+
+	_, err = repo.Remove(ctx, found)
+	require.Nil(t, err)
+}
+
 func newUser() *entity.User {
 	ti := time.Now().UTC()
 
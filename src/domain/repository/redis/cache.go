@@ -13,7 +13,25 @@ type ICache interface {
 	Ping(ctx context.Context) (err error)
 	Set(ctx context.Context, key string, value string, expiration time.Duration) (err error)
 	Get(ctx context.Context, key string) (value string, err error)
+	// Take returns the value of key and removes it in one step, of concurrent callers only one gets the value
+	Take(ctx context.Context, key string) (value string, err error)
+	// SetIfAbsent stores the value only when key does not exist, ok reports whether it was stored
+	SetIfAbsent(ctx context.Context, key string, value string, expiration time.Duration) (ok bool, err error)
 	Remove(ctx context.Context, key string) (err error)
+	// Increment adds one to the counter stored at key, a new counter expires after expiration
+	Increment(ctx context.Context, key string, expiration time.Duration) (count int64, err error)
+	// AddEvent records an event in the sliding window stored at key and returns the
+	// event and the number of events of the last window
+	AddEvent(ctx context.Context, key string, window time.Duration) (event string, count int64, err error)
+	// RemoveEvent takes back an event AddEvent recorded
+	RemoveEvent(ctx context.Context, key string, event string) (err error)
+	// AddMember adds member to the set stored at key and renews the expiration of the whole set
+	AddMember(ctx context.Context, key string, member string, expiration time.Duration) (err error)
+	Members(ctx context.Context, key string) (members []string, err error)
+	RemoveMember(ctx context.Context, key string, member string) (err error)
+	Publish(ctx context.Context, channel string, message string) (err error)
+	// Subscribe delivers the messages published on channel until ctx is done, then closes messages
+	Subscribe(ctx context.Context, channel string) (messages <-chan string, err error)
 }
 
 func NewCache(address string, port int32, password string, db int, retry int32, logger logger.ILogger) ICache {
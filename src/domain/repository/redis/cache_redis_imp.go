@@ -6,6 +6,7 @@ import (
 	"github.com/Juno-chat-app/user-service/infra/logger"
 	"github.com/go-redis/redis/v8"
 	"google.golang.org/grpc/status"
+	"math/rand"
 	"net/http"
 	"sync"
 	"time"
@@ -71,6 +72,81 @@ func (c *iRedisCache) Set(ctx context.Context, key string, value string, expirat
 	return nil
 }
 
+func (c *iRedisCache) SetIfAbsent(ctx context.Context, key string, value string, expiration time.Duration) (ok bool, err error) {
+	ok, err = c.connection.SetNX(ctx, key, value, expiration).Result()
+
+	if err != nil {
+		err := c.reconnect(ctx)
+		if err != nil {
+			return false, err
+		} else {
+			return c.SetIfAbsent(ctx, key, value, expiration)
+		}
+	}
+
+	return ok, nil
+}
+
+func (c *iRedisCache) Increment(ctx context.Context, key string, expiration time.Duration) (count int64, err error) {
+	// the counter is created with its expiration in the same transaction, only
+	// the first increment starts the window and later ones must not extend it
+	pipe := c.connection.TxPipeline()
+	pipe.SetNX(ctx, key, 0, expiration)
+	incr := pipe.Incr(ctx, key)
+	_, err = pipe.Exec(ctx)
+
+	if err != nil {
+		err := c.reconnect(ctx)
+		if err != nil {
+			return 0, err
+		} else {
+			return c.Increment(ctx, key, expiration)
+		}
+	}
+
+	return incr.Val(), nil
+}
+
+func (c *iRedisCache) AddEvent(ctx context.Context, key string, window time.Duration) (event string, count int64, err error) {
+	// the events are a sorted set scored by their time, the ones older than the
+	// window are dropped with every new event
+	now := time.Now().UnixNano()
+	member := fmt.Sprintf("%d-%d", now, rand.Int63())
+
+	pipe := c.connection.TxPipeline()
+	pipe.ZAdd(ctx, key, &redis.Z{Score: float64(now), Member: member})
+	pipe.ZRemRangeByScore(ctx, key, "-inf", fmt.Sprintf("(%d", now-window.Nanoseconds()))
+	card := pipe.ZCard(ctx, key)
+	pipe.Expire(ctx, key, window)
+	_, err = pipe.Exec(ctx)
+
+	if err != nil {
+		err := c.reconnect(ctx)
+		if err != nil {
+			return "", 0, err
+		} else {
+			return c.AddEvent(ctx, key, window)
+		}
+	}
+
+	return member, card.Val(), nil
+}
+
+func (c *iRedisCache) RemoveEvent(ctx context.Context, key string, event string) (err error) {
+	err = c.connection.ZRem(ctx, key, event).Err()
+
+	if err != nil {
+		err := c.reconnect(ctx)
+		if err != nil {
+			return err
+		} else {
+			return c.RemoveEvent(ctx, key, event)
+		}
+	}
+
+	return nil
+}
+
 func (c *iRedisCache) Get(ctx context.Context, key string) (value string, err error) {
 	value, err = c.connection.Get(ctx, key).Result()
 	if err != redis.Nil && err != nil {
@@ -87,6 +163,131 @@ func (c *iRedisCache) Get(ctx context.Context, key string) (value string, err er
 	return value, nil
 }
 
+func (c *iRedisCache) Take(ctx context.Context, key string) (value string, err error) {
+	pipe := c.connection.TxPipeline()
+	get := pipe.Get(ctx, key)
+	pipe.Del(ctx, key)
+	_, err = pipe.Exec(ctx)
+
+	if err != redis.Nil && err != nil {
+		err := c.reconnect(ctx)
+		if err != nil {
+			return "", err
+		} else {
+			return c.Take(ctx, key)
+		}
+	} else if err == redis.Nil {
+		return "", status.Error(http.StatusNotFound, "key not found")
+	}
+
+	return get.Val(), nil
+}
+
+func (c *iRedisCache) AddMember(ctx context.Context, key string, member string, expiration time.Duration) (err error) {
+	pipe := c.connection.TxPipeline()
+	pipe.SAdd(ctx, key, member)
+	pipe.Expire(ctx, key, expiration)
+	_, err = pipe.Exec(ctx)
+
+	if err != nil {
+		err := c.reconnect(ctx)
+		if err != nil {
+			return err
+		} else {
+			return c.AddMember(ctx, key, member, expiration)
+		}
+	}
+
+	return nil
+}
+
+func (c *iRedisCache) Members(ctx context.Context, key string) (members []string, err error) {
+	members, err = c.connection.SMembers(ctx, key).Result()
+
+	if err != nil {
+		err := c.reconnect(ctx)
+		if err != nil {
+			return nil, err
+		} else {
+			return c.Members(ctx, key)
+		}
+	}
+
+	return members, nil
+}
+
+func (c *iRedisCache) RemoveMember(ctx context.Context, key string, member string) (err error) {
+	err = c.connection.SRem(ctx, key, member).Err()
+
+	if err != nil {
+		err := c.reconnect(ctx)
+		if err != nil {
+			return err
+		} else {
+			return c.RemoveMember(ctx, key, member)
+		}
+	}
+
+	return nil
+}
+
+func (c *iRedisCache) Publish(ctx context.Context, channel string, message string) (err error) {
+	err = c.connection.Publish(ctx, channel, message).Err()
+
+	if err != nil {
+		err := c.reconnect(ctx)
+		if err != nil {
+			return err
+		} else {
+			return c.Publish(ctx, channel, message)
+		}
+	}
+
+	return nil
+}
+
+func (c *iRedisCache) Subscribe(ctx context.Context, channel string) (messages <-chan string, err error) {
+	pubSub := c.connection.Subscribe(ctx, channel)
+
+	// the subscription is only confirmed by its first reply
+	_, err = pubSub.Receive(ctx)
+	if err != nil {
+		_ = pubSub.Close()
+		err := c.reconnect(ctx)
+		if err != nil {
+			return nil, err
+		} else {
+			return c.Subscribe(ctx, channel)
+		}
+	}
+
+	out := make(chan string)
+	go func() {
+		defer close(out)
+		defer pubSub.Close()
+
+		in := pubSub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-in:
+				if !ok {
+					return
+				}
+
+				select {
+				case out <- msg.Payload:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
 func (c *iRedisCache) reconnect(ctx context.Context) (err error) {
 	val := ctx.Value(Retry)
 	if val == nil {
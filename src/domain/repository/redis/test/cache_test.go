@@ -59,3 +59,134 @@ func Test_Ping_Set_Get(t *testing.T) {
 	require.Equal(t, true, ok)
 	require.Equal(t, codes.Code(http.StatusNotFound), stat.Code())
 }
+
+func Test_Take(t *testing.T) {
+	ctx := context.Background()
+
+	err := cache.Set(ctx, "test-take", "value", time.Second)
+	require.Nil(t, err)
+
+	value, err := cache.Take(ctx, "test-take")
+	require.Nil(t, err)
+	require.Equal(t, "value", value)
+
+	// the value is gone with the first take
+	_, err = cache.Take(ctx, "test-take")
+	require.Equal(t, codes.Code(http.StatusNotFound), status.Code(err))
+
+	_, err = cache.Get(ctx, "test-take")
+	require.Equal(t, codes.Code(http.StatusNotFound), status.Code(err))
+}
+
+func Test_SetIfAbsent(t *testing.T) {
+	ctx := context.Background()
+
+	ok, err := cache.SetIfAbsent(ctx, "test-absent", "first", time.Second)
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	ok, err = cache.SetIfAbsent(ctx, "test-absent", "second", time.Second)
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	value, err := cache.Get(ctx, "test-absent")
+	require.Nil(t, err)
+	require.Equal(t, "first", value)
+
+	err = cache.Remove(ctx, "test-absent")
+	require.Nil(t, err)
+}
+
+func Test_Increment(t *testing.T) {
+	ctx := context.Background()
+
+	count, err := cache.Increment(ctx, "test-counter", time.Second)
+	require.Nil(t, err)
+	require.Equal(t, int64(1), count)
+
+	count, err = cache.Increment(ctx, "test-counter", time.Second)
+	require.Nil(t, err)
+	require.Equal(t, int64(2), count)
+
+	// the counter starts over once it expired
+	time.Sleep(1100 * time.Millisecond)
+	count, err = cache.Increment(ctx, "test-counter", time.Second)
+	require.Nil(t, err)
+	require.Equal(t, int64(1), count)
+
+	err = cache.Remove(ctx, "test-counter")
+	require.Nil(t, err)
+}
+
+func Test_AddEvent(t *testing.T) {
+	ctx := context.Background()
+
+	_, count, err := cache.AddEvent(ctx, "test-events", time.Second)
+	require.Nil(t, err)
+	require.Equal(t, int64(1), count)
+
+	time.Sleep(600 * time.Millisecond)
+	_, count, err = cache.AddEvent(ctx, "test-events", time.Second)
+	require.Nil(t, err)
+	require.Equal(t, int64(2), count)
+
+	// the first event slid out of the window
+	time.Sleep(600 * time.Millisecond)
+	event, count, err := cache.AddEvent(ctx, "test-events", time.Second)
+	require.Nil(t, err)
+	require.Equal(t, int64(2), count)
+
+	// a removed event does not count any more
+	err = cache.RemoveEvent(ctx, "test-events", event)
+	require.Nil(t, err)
+	_, count, err = cache.AddEvent(ctx, "test-events", time.Second)
+	require.Nil(t, err)
+	require.Equal(t, int64(2), count)
+
+	err = cache.Remove(ctx, "test-events")
+	require.Nil(t, err)
+}
+
+func Test_AddMember_Members_RemoveMember(t *testing.T) {
+	ctx := context.Background()
+
+	err := cache.AddMember(ctx, "test-set", "first", time.Second)
+	require.Nil(t, err)
+	err = cache.AddMember(ctx, "test-set", "second", time.Second)
+	require.Nil(t, err)
+
+	members, err := cache.Members(ctx, "test-set")
+	require.Nil(t, err)
+	require.ElementsMatch(t, []string{"first", "second"}, members)
+
+	err = cache.RemoveMember(ctx, "test-set", "first")
+	require.Nil(t, err)
+
+	members, err = cache.Members(ctx, "test-set")
+	require.Nil(t, err)
+	require.Equal(t, []string{"second"}, members)
+
+	err = cache.Remove(ctx, "test-set")
+	require.Nil(t, err)
+}
+
+func Test_Publish_Subscribe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	messages, err := cache.Subscribe(ctx, "test-channel")
+	require.Nil(t, err)
+
+	err = cache.Publish(ctx, "test-channel", "message")
+	require.Nil(t, err)
+
+	select {
+	case message := <-messages:
+		require.Equal(t, "message", message)
+	case <-time.After(time.Second):
+		t.Fatal("message was not delivered")
+	}
+
+	cancel()
+	_, open := <-messages
+	require.Equal(t, false, open)
+}
@@ -0,0 +1,71 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"github.com/Juno-chat-app/user-service/config"
+	"github.com/Juno-chat-app/user-service/infra/logger"
+)
+
+const (
+	SmtpDriver   string = "smtp"
+	OutboxDriver string = "outbox"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type IMailer interface {
+	Send(ctx context.Context, message *Message) (err error)
+}
+
+// NewMailer creates the mailer of the configured driver
+func NewMailer(conf config.MailConfig, logger logger.ILogger) (IMailer, error) {
+	switch conf.Driver {
+	case SmtpDriver:
+		return NewSmtpMailer(conf.Host, conf.Port, conf.UserName, conf.Password, conf.From, logger), nil
+	case OutboxDriver:
+		return NewOutboxMailer(conf.OutboxDir, conf.From, logger), nil
+	default:
+		return nil, fmt.Errorf("unsupported mail driver %q", conf.Driver)
+	}
+}
+
+func NewSmtpMailer(address string, port int32, userName string, password string, from string, logger logger.ILogger) IMailer {
+	logger.Info("create smtp mailer",
+		"method", "NewSmtpMailer",
+		"host", address,
+		"port", port,
+		"from", from)
+
+	mailer := iSmtpMailer{
+		address:  address,
+		port:     port,
+		userName: userName,
+		password: password,
+		from:     from,
+		logger:   logger,
+	}
+
+	return &mailer
+}
+
+// NewOutboxMailer creates a mailer which writes every mail to a file in dir
+// instead of sending it, it is meant for local development and tests
+func NewOutboxMailer(dir string, from string, logger logger.ILogger) IMailer {
+	logger.Info("create outbox mailer",
+		"method", "NewOutboxMailer",
+		"dir", dir,
+		"from", from)
+
+	mailer := iOutboxMailer{
+		dir:    dir,
+		from:   from,
+		logger: logger,
+	}
+
+	return &mailer
+}
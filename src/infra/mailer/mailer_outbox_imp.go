@@ -0,0 +1,43 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"github.com/Juno-chat-app/user-service/infra/logger"
+	"google.golang.org/grpc/status"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type iOutboxMailer struct {
+	dir    string
+	from   string
+	logger logger.ILogger
+}
+
+func (m *iOutboxMailer) Send(ctx context.Context, message *Message) (err error) {
+	err = os.MkdirAll(m.dir, 0700)
+	if err != nil {
+		return status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	// the recipient is part of the name so mails are easy to find
+	recipient := strings.NewReplacer("/", "_", "\\", "_").Replace(message.To)
+	path := filepath.Join(m.dir, fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), recipient))
+
+	err = ioutil.WriteFile(path, format(m.from, message), 0600)
+	if err != nil {
+		return status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	m.logger.Info("mail written to outbox",
+		"method", "Send",
+		"to", message.To,
+		"subject", message.Subject,
+		"path", path)
+	return nil
+}
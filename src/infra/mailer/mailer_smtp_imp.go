@@ -0,0 +1,46 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"github.com/Juno-chat-app/user-service/infra/logger"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"net/smtp"
+)
+
+type iSmtpMailer struct {
+	address  string
+	port     int32
+	userName string
+	password string
+	from     string
+	logger   logger.ILogger
+}
+
+func (m *iSmtpMailer) Send(ctx context.Context, message *Message) (err error) {
+	var auth smtp.Auth
+	if m.userName != "" {
+		auth = smtp.PlainAuth("", m.userName, m.password, m.address)
+	}
+
+	address := fmt.Sprintf("%s:%d", m.address, m.port)
+	err = smtp.SendMail(address, auth, m.from, []string{message.To}, format(m.from, message))
+	if err != nil {
+		m.logger.Error("got error on sending mail",
+			"method", "Send",
+			"to", message.To,
+			"subject", message.Subject,
+			"err", err)
+
+		return status.Error(http.StatusInternalServerError, "got error on sending mail")
+	}
+
+	return nil
+}
+
+// format renders message as a plain text RFC 5322 mail
+func format(from string, message *Message) []byte {
+	return []byte(fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n",
+		from, message.To, message.Subject, message.Body))
+}
@@ -0,0 +1,87 @@
+package sms
+
+import (
+	"context"
+	"fmt"
+	"github.com/Juno-chat-app/user-service/config"
+	"github.com/Juno-chat-app/user-service/infra/logger"
+	"net/http"
+	"time"
+)
+
+const (
+	HttpDriver   string = "http"
+	LogDriver    string = "log"
+	OutboxDriver string = "outbox"
+)
+
+type Message struct {
+	// To is the mobile number in E.164 format
+	To   string
+	Body string
+}
+
+type ISmsSender interface {
+	Send(ctx context.Context, message *Message) (err error)
+}
+
+// NewSmsSender creates the sender of the configured driver
+func NewSmsSender(conf config.SmsConfig, logger logger.ILogger) (ISmsSender, error) {
+	switch conf.Driver {
+	case HttpDriver:
+		return NewHttpSmsSender(conf.Url, conf.ApiKey, conf.From, logger), nil
+	case LogDriver:
+		return NewLogSmsSender(logger), nil
+	case OutboxDriver:
+		return NewOutboxSmsSender(conf.OutboxDir, logger), nil
+	default:
+		return nil, fmt.Errorf("unsupported sms driver %q", conf.Driver)
+	}
+}
+
+// NewHttpSmsSender creates a sender which posts every message as json to the
+// gateway at url, the api key is sent as bearer token
+func NewHttpSmsSender(url string, apiKey string, from string, logger logger.ILogger) ISmsSender {
+	logger.Info("create http sms sender",
+		"method", "NewHttpSmsSender",
+		"url", url,
+		"from", from)
+
+	sender := iHttpSmsSender{
+		url:    url,
+		apiKey: apiKey,
+		from:   from,
+		client: &http.Client{Timeout: 10 * time.Second},
+		logger: logger,
+	}
+
+	return &sender
+}
+
+// NewLogSmsSender creates a sender which only logs the messages, it is meant
+// for local development
+func NewLogSmsSender(logger logger.ILogger) ISmsSender {
+	logger.Info("create log sms sender",
+		"method", "NewLogSmsSender")
+
+	sender := iLogSmsSender{
+		logger: logger,
+	}
+
+	return &sender
+}
+
+// NewOutboxSmsSender creates a sender which writes every message to a file in
+// dir instead of sending it, it is meant for local development and tests
+func NewOutboxSmsSender(dir string, logger logger.ILogger) ISmsSender {
+	logger.Info("create outbox sms sender",
+		"method", "NewOutboxSmsSender",
+		"dir", dir)
+
+	sender := iOutboxSmsSender{
+		dir:    dir,
+		logger: logger,
+	}
+
+	return &sender
+}
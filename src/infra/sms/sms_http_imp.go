@@ -0,0 +1,62 @@
+package sms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/Juno-chat-app/user-service/infra/logger"
+	"google.golang.org/grpc/status"
+	"net/http"
+)
+
+type iHttpSmsSender struct {
+	url    string
+	apiKey string
+	from   string
+	client *http.Client
+	logger logger.ILogger
+}
+
+type httpMessage struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Body string `json:"body"`
+}
+
+func (s *iHttpSmsSender) Send(ctx context.Context, message *Message) (err error) {
+	body, err := json.Marshal(httpMessage{From: s.from, To: message.To, Body: message.Body})
+	if err != nil {
+		return status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return status.Error(http.StatusInternalServerError, err.Error())
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if s.apiKey != "" {
+		request.Header.Set("Authorization", "Bearer "+s.apiKey)
+	}
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		s.logger.Error("got error on sending sms",
+			"method", "Send",
+			"to", message.To,
+			"err", err)
+
+		return status.Error(http.StatusInternalServerError, "got error on sending sms")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		s.logger.Error("sms gateway refused the message",
+			"method", "Send",
+			"to", message.To,
+			"status", response.StatusCode)
+
+		return status.Error(http.StatusInternalServerError, "got error on sending sms")
+	}
+
+	return nil
+}
@@ -0,0 +1,18 @@
+package sms
+
+import (
+	"context"
+	"github.com/Juno-chat-app/user-service/infra/logger"
+)
+
+type iLogSmsSender struct {
+	logger logger.ILogger
+}
+
+func (s *iLogSmsSender) Send(ctx context.Context, message *Message) (err error) {
+	s.logger.Info("sms",
+		"method", "Send",
+		"to", message.To,
+		"body", message.Body)
+	return nil
+}
@@ -0,0 +1,39 @@
+package sms
+
+import (
+	"context"
+	"fmt"
+	"github.com/Juno-chat-app/user-service/infra/logger"
+	"google.golang.org/grpc/status"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type iOutboxSmsSender struct {
+	dir    string
+	logger logger.ILogger
+}
+
+func (s *iOutboxSmsSender) Send(ctx context.Context, message *Message) (err error) {
+	err = os.MkdirAll(s.dir, 0700)
+	if err != nil {
+		return status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	// the recipient is part of the name so messages are easy to find
+	path := filepath.Join(s.dir, fmt.Sprintf("%d-%s.sms", time.Now().UnixNano(), message.To))
+
+	err = ioutil.WriteFile(path, []byte(message.Body), 0600)
+	if err != nil {
+		return status.Error(http.StatusInternalServerError, err.Error())
+	}
+
+	s.logger.Info("sms written to outbox",
+		"method", "Send",
+		"to", message.To,
+		"path", path)
+	return nil
+}
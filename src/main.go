@@ -5,16 +5,20 @@ import (
 	"fmt"
 	"github.com/Juno-chat-app/user-service/config"
 	"github.com/Juno-chat-app/user-service/domain/model/authorization"
+	"github.com/Juno-chat-app/user-service/domain/model/mfa"
+	"github.com/Juno-chat-app/user-service/domain/model/password"
 	"github.com/Juno-chat-app/user-service/domain/model/services"
 	"github.com/Juno-chat-app/user-service/domain/repository/mongo"
 	"github.com/Juno-chat-app/user-service/domain/repository/redis"
 	"github.com/Juno-chat-app/user-service/infra/logger"
+	"github.com/Juno-chat-app/user-service/infra/mailer"
+	"github.com/Juno-chat-app/user-service/infra/sms"
 	"github.com/Juno-chat-app/user-service/server/grpc"
+	"github.com/Juno-chat-app/user-service/server/http"
 	"os"
 	"time"
 )
 
-// todo :: implement get user by user id, or one of contact info
 func main() {
 	mode := os.Getenv("APP_MODE")
 	var conf *config.Configuration
@@ -41,7 +45,11 @@ func main() {
 		os.Exit(1)
 	}
 
-	auth := authorization.NewJwtHandler(int64(conf.AuthConfig.AccessTTL*time.Minute), int64(conf.AuthConfig.RefreshTTL*time.Hour), log)
+	auth, err := authorization.NewJwtHandler(conf.AuthConfig, log)
+	if err != nil {
+		log.Error("got error on loading signing keys", "err", err)
+		os.Exit(1)
+	}
 
 	cacheConfig := conf.CQRSConfig.CacheConfig
 	cache := redis.NewCache(cacheConfig.Host, cacheConfig.Port, cacheConfig.Password, cacheConfig.Db, cacheConfig.Retry, log)
@@ -58,7 +66,73 @@ func main() {
 		os.Exit(1)
 	}
 
-	service := services.NewUserService(log, repo, cache, auth)
+	err = repo.EnsureIndexes(context.Background())
+	if err != nil {
+		log.Error("got error on creating mongo indexes", "err", err)
+		os.Exit(1)
+	}
+
+	clients := mongo.NewClientRepository(conf.CQRSConfig.PersistConfig, log)
+
+	audits := mongo.NewAuditRepository(conf.CQRSConfig.PersistConfig, log)
+	err = audits.EnsureIndexes(context.Background())
+	if err != nil {
+		log.Error("got error on creating audit indexes", "err", err)
+		os.Exit(1)
+	}
+
+	mail, err := mailer.NewMailer(conf.MailConfig, log)
+	if err != nil {
+		log.Error("got error on creating mailer", "err", err)
+		os.Exit(1)
+	}
+
+	smsSender, err := sms.NewSmsSender(conf.SmsConfig, log)
+	if err != nil {
+		log.Error("got error on creating sms sender", "err", err)
+		os.Exit(1)
+	}
+
+	passwordPolicy, err := password.NewPasswordPolicy(conf.PasswordConfig, log)
+	if err != nil {
+		log.Error("got error on creating password policy", "err", err)
+		os.Exit(1)
+	}
+
+	passwordHasher, err := password.NewPasswordHasher(conf.HashConfig, log)
+	if err != nil {
+		log.Error("got error on creating password hasher", "err", err)
+		os.Exit(1)
+	}
+
+	totp, err := mfa.NewTotpHandler(conf.MfaConfig, log)
+	if err != nil {
+		log.Error("got error on creating totp handler", "err", err)
+		os.Exit(1)
+	}
+
+	// revoked access tokens outlive them by the leeway they are still accepted with
+	authConfig := conf.AuthConfig
+	revocations := services.NewRevocationList(cache, authConfig.AccessTTL*time.Minute+authConfig.Leeway*time.Second,
+		authConfig.Leeway*time.Second, authConfig.RevocationReload*time.Second, log)
+	if authConfig.ValidationMode == services.StatelessValidation {
+		go revocations.Run(context.Background())
+	}
+
+	accountConfig := conf.AccountConfig
+	purgeJob := services.NewPurgeJob(repo, accountConfig.DeletionGracePeriod*24*time.Hour, accountConfig.PurgeInterval*time.Minute, log)
+	go purgeJob.Run(context.Background())
+
+	service := services.NewUserService(conf, log, repo, clients, cache, auth, revocations, mail, smsSender, passwordPolicy, passwordHasher, totp, audits)
+
+	httpServer := http.NewServer(conf.HTTPConfig.Host, conf.HTTPConfig.Port, service, log)
+	go func() {
+		err := httpServer.Start()
+		if err != nil {
+			os.Exit(1)
+		}
+	}()
+
 	grpcServer := grpc.NewServer(conf.GRPCConfig.Host, conf.GRPCConfig.Port, service, log)
 	err = grpcServer.Start()
 	if err != nil {
@@ -0,0 +1,878 @@
+package grpc
+
+import (
+	"context"
+	userproto "github.com/Juno-chat-app/user-proto"
+	"github.com/Juno-chat-app/user-service/domain/entity"
+	"github.com/Juno-chat-app/user-service/domain/model/authorization"
+	"github.com/Juno-chat-app/user-service/domain/model/services"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func (s *Server) Keys(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error) {
+	keySet, err := s.userService.PublicKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return newResponse("KeysResponse", "JsonWebKeySet", keySet)
+}
+
+func (s *Server) SignOut(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error) {
+	body := SignOutRequest{}
+	err := unmarshalBody(req, SignOutRequestMethod, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := requestUserId(req)
+	if err != nil {
+		return nil, err
+	}
+
+	token := authorization.TokenDetail{
+		AccessToken: body.BearerToken,
+		UserId:      userId,
+	}
+
+	err = s.userService.SignOut(ctx, &token)
+	if err != nil {
+		return nil, err
+	}
+
+	return newResponse("SignOutResponse", "", nil)
+}
+
+func (s *Server) ListSessions(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error) {
+	body := ListSessionsRequest{}
+	err := unmarshalBody(req, ListSessionsRequestMethod, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := requestUserId(req)
+	if err != nil {
+		return nil, err
+	}
+
+	token := authorization.TokenDetail{
+		AccessToken: body.BearerToken,
+		UserId:      userId,
+	}
+
+	current, err := s.userService.Validate(ctx, &token)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := s.userService.ListSessions(ctx, &token)
+	if err != nil {
+		return nil, err
+	}
+
+	responseBody := ListSessionsResponse{
+		Sessions: make([]SessionInfo, 0, len(sessions)),
+	}
+	for _, session := range sessions {
+		responseBody.Sessions = append(responseBody.Sessions, SessionInfo{
+			SessionId:     session.SessionId,
+			IpAddress:     session.IpAddress,
+			UserAgent:     session.UserAgent,
+			CreatedAt:     session.CreatedAt,
+			LastRefreshAt: session.LastRefreshAt,
+			Current:       session.SessionId == current.FamilyId,
+		})
+	}
+
+	return newResponse("ListSessionsResponse", "ListSessionsResponse", &responseBody)
+}
+
+func (s *Server) RevokeSession(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error) {
+	body := RevokeSessionRequest{}
+	err := unmarshalBody(req, RevokeSessionRequestMethod, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := requestUserId(req)
+	if err != nil {
+		return nil, err
+	}
+
+	token := authorization.TokenDetail{
+		AccessToken: body.BearerToken,
+		UserId:      userId,
+	}
+
+	err = s.userService.RevokeSession(ctx, &token, body.SessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	return newResponse("RevokeSessionResponse", "", nil)
+}
+
+func (s *Server) RevokeAllSessions(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error) {
+	body := RevokeAllSessionsRequest{}
+	err := unmarshalBody(req, RevokeAllSessionsRequestMethod, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := requestUserId(req)
+	if err != nil {
+		return nil, err
+	}
+
+	token := authorization.TokenDetail{
+		AccessToken: body.BearerToken,
+		UserId:      userId,
+	}
+
+	err = s.userService.RevokeAllSessions(ctx, &token)
+	if err != nil {
+		return nil, err
+	}
+
+	return newResponse("RevokeAllSessionsResponse", "", nil)
+}
+
+func (s *Server) Introspect(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error) {
+	body := IntrospectRequest{}
+	err := unmarshalBody(req, IntrospectRequestMethod, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	clientId, err := requestUserId(req)
+	if err != nil {
+		return nil, err
+	}
+
+	caller := authorization.TokenDetail{
+		AccessToken: body.BearerToken,
+		UserId:      clientId,
+	}
+
+	introspection, err := s.userService.Introspect(ctx, &caller, body.Token, body.TokenTypeHint)
+	if err != nil {
+		return nil, err
+	}
+
+	responseBody := IntrospectResponse{
+		Active:    introspection.Active,
+		TokenType: introspection.TokenType,
+		Subject:   introspection.UserId,
+		IssuedAt:  introspection.IssuedAt,
+		ExpireAt:  introspection.ExpireAt,
+		SessionId: introspection.SessionId,
+		IpAddress: introspection.IpAddress,
+		UserAgent: introspection.UserAgent,
+	}
+
+	responseBody.Principal = introspection.PrincipalType
+	if introspection.PrincipalType == authorization.ClientPrincipal {
+		responseBody.ClientId = introspection.UserId
+	}
+
+	// client tokens carry scopes, the permissions of users are their scope
+	scopes := append([]string(nil), introspection.Scopes...)
+	for _, p := range introspection.Permissions {
+		responseBody.Permissions = append(responseBody.Permissions, Permission{Key: p.Key, Value: p.Value})
+		scopes = append(scopes, p.Key+":"+p.Value)
+	}
+	responseBody.Scope = strings.Join(scopes, " ")
+
+	return newResponse("IntrospectResponse", "IntrospectResponse", &responseBody)
+}
+
+func (s *Server) ClientToken(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error) {
+	body := ClientTokenRequest{}
+	err := unmarshalBody(req, ClientTokenRequestMethod, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := s.userService.ClientToken(ctx, body.ClientId, body.ClientSecret, strings.Fields(body.Scope))
+	if err != nil {
+		return nil, err
+	}
+
+	responseBody := ClientTokenResponse{
+		AccessToken: token.AccessToken,
+		TokenType:   "Bearer",
+		ExpireAt:    token.ExpireAt,
+		Scope:       strings.Join(token.Scopes, " "),
+	}
+
+	return newResponse("ClientTokenResponse", "ClientTokenResponse", &responseBody)
+}
+
+func (s *Server) RegisterClient(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error) {
+	body := RegisterClientRequest{}
+	err := unmarshalBody(req, RegisterClientRequestMethod, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := requestUserId(req)
+	if err != nil {
+		return nil, err
+	}
+
+	token := authorization.TokenDetail{
+		AccessToken: body.BearerToken,
+		UserId:      userId,
+	}
+
+	client := entity.Client{
+		Name:   body.Name,
+		Scopes: body.Scopes,
+	}
+
+	client_, secret, err := s.userService.RegisterClient(ctx, &token, &client)
+	if err != nil {
+		return nil, err
+	}
+
+	responseBody := RegisterClientResponse{
+		ClientId:     client_.ClientId,
+		ClientSecret: secret,
+		Name:         client_.Name,
+		Scopes:       client_.Scopes,
+	}
+
+	return newResponse("RegisterClientResponse", "RegisterClientResponse", &responseBody)
+}
+
+func (s *Server) ActivateAccount(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error) {
+	body := ActivateAccountRequest{}
+	err := unmarshalBody(req, ActivateAccountRequestMethod, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.userService.ActivateAccount(ctx, body.Email, body.ActivationCode)
+	if err != nil {
+		return nil, err
+	}
+
+	return newResponse("ActivateAccountResponse", "", nil)
+}
+
+func (s *Server) ResendActivation(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error) {
+	body := ResendActivationRequest{}
+	err := unmarshalBody(req, ResendActivationRequestMethod, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.userService.ResendActivation(ctx, body.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	return newResponse("ResendActivationResponse", "", nil)
+}
+
+func (s *Server) RequestPasswordReset(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error) {
+	body := RequestPasswordResetRequest{}
+	err := unmarshalBody(req, RequestPasswordResetRequestMethod, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.userService.RequestPasswordReset(ctx, body.Login)
+	if err != nil {
+		return nil, err
+	}
+
+	return newResponse("RequestPasswordResetResponse", "", nil)
+}
+
+func (s *Server) ResetPassword(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error) {
+	body := ResetPasswordRequest{}
+	err := unmarshalBody(req, ResetPasswordRequestMethod, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.userService.ResetPassword(ctx, body.ResetToken, body.NewPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	return newResponse("ResetPasswordResponse", "", nil)
+}
+
+func (s *Server) ChangePassword(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error) {
+	body := ChangePasswordRequest{}
+	err := unmarshalBody(req, ChangePasswordRequestMethod, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := requestUserId(req)
+	if err != nil {
+		return nil, err
+	}
+
+	token := authorization.TokenDetail{
+		AccessToken: body.BearerToken,
+		UserId:      userId,
+	}
+
+	err = s.userService.ChangePassword(ctx, &token, body.OldPassword, body.NewPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	return newResponse("ChangePasswordResponse", "", nil)
+}
+
+func (s *Server) UpdateProfile(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error) {
+	body := UpdateProfileRequest{}
+	err := unmarshalBody(req, UpdateProfileRequestMethod, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := requestUserId(req)
+	if err != nil {
+		return nil, err
+	}
+
+	token := authorization.TokenDetail{
+		AccessToken: body.BearerToken,
+		UserId:      userId,
+	}
+
+	user := entity.User{
+		UserName: body.UserName,
+		ContactInfo: &entity.ContactInfo{
+			Mobile: body.Mobile,
+			Phone:  body.Phone,
+			Email:  body.Email,
+		},
+		Profile: &entity.Profile{
+			DisplayName: body.Profile.DisplayName,
+			Bio:         body.Profile.Bio,
+			AvatarUrl:   body.Profile.AvatarUrl,
+			Locale:      body.Profile.Locale,
+		},
+	}
+
+	mask := make([]entity.Path, 0, len(body.UpdateMask))
+	for _, path := range body.UpdateMask {
+		mask = append(mask, entity.Path(path))
+	}
+
+	user_, err := s.userService.UpdateProfile(ctx, &token, &user, mask)
+	if err != nil {
+		return nil, err
+	}
+
+	responseBody := profileResponse(user_)
+	return newResponse("UpdateProfileResponse", "UpdateProfileResponse", &responseBody)
+}
+
+func (s *Server) ConfirmEmailChange(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error) {
+	body := ConfirmEmailChangeRequest{}
+	err := unmarshalBody(req, ConfirmEmailChangeRequestMethod, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := requestUserId(req)
+	if err != nil {
+		return nil, err
+	}
+
+	token := authorization.TokenDetail{
+		AccessToken: body.BearerToken,
+		UserId:      userId,
+	}
+
+	user, err := s.userService.ConfirmEmailChange(ctx, &token, body.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	responseBody := profileResponse(user)
+	return newResponse("ConfirmEmailChangeResponse", "UpdateProfileResponse", &responseBody)
+}
+
+func profileResponse(user *entity.User) UpdateProfileResponse {
+	responseBody := UpdateProfileResponse{
+		UserId:   user.UserId,
+		UserName: user.UserName,
+	}
+	if user.ContactInfo != nil {
+		responseBody.Email = user.ContactInfo.Email
+		responseBody.Mobile = user.ContactInfo.Mobile
+		responseBody.Phone = user.ContactInfo.Phone
+	}
+	if user.Profile != nil {
+		responseBody.Profile = ProfileInfo{
+			DisplayName: user.Profile.DisplayName,
+			Bio:         user.Profile.Bio,
+			AvatarUrl:   user.Profile.AvatarUrl,
+			Locale:      user.Profile.Locale,
+		}
+	}
+
+	return responseBody
+}
+
+func (s *Server) LookupUser(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error) {
+	body := LookupUserRequest{}
+	err := unmarshalBody(req, LookupUserRequestMethod, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := requestUserId(req)
+	if err != nil {
+		return nil, err
+	}
+
+	token := authorization.TokenDetail{
+		AccessToken: body.BearerToken,
+		UserId:      userId,
+	}
+
+	query := services.UserQuery{
+		UserId: body.UserId,
+		Email:  body.Email,
+		Mobile: body.Mobile,
+	}
+
+	user, err := s.userService.GetUser(ctx, &token, query)
+	if err != nil {
+		return nil, err
+	}
+
+	responseBody := profileResponse(user)
+	return newResponse("LookupUserResponse", "UpdateProfileResponse", &responseBody)
+}
+
+func (s *Server) DeleteAccount(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error) {
+	body := DeleteAccountRequest{}
+	err := unmarshalBody(req, DeleteAccountRequestMethod, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := requestUserId(req)
+	if err != nil {
+		return nil, err
+	}
+
+	token := authorization.TokenDetail{
+		AccessToken: body.BearerToken,
+		UserId:      userId,
+	}
+
+	err = s.userService.DeleteAccount(ctx, &token, body.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	return newResponse("DeleteAccountResponse", "", nil)
+}
+
+func (s *Server) RequestAccountRestore(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error) {
+	body := RequestAccountRestoreRequest{}
+	err := unmarshalBody(req, RequestAccountRestoreRequestMethod, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.userService.RequestAccountRestore(ctx, body.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	return newResponse("RequestAccountRestoreResponse", "", nil)
+}
+
+func (s *Server) RestoreAccount(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error) {
+	body := RestoreAccountRequest{}
+	err := unmarshalBody(req, RestoreAccountRequestMethod, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	var user *entity.User
+	if body.RestoreCode != "" {
+		user, err = s.userService.RestoreAccount(ctx, body.RestoreCode)
+	} else {
+		var userId string
+		userId, err = requestUserId(req)
+		if err != nil {
+			return nil, err
+		}
+
+		token := authorization.TokenDetail{
+			AccessToken: body.BearerToken,
+			UserId:      userId,
+		}
+		user, err = s.userService.AdminRestoreAccount(ctx, &token, body.UserId)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	responseBody := RestoreAccountResponse{
+		UserId:   user.UserId,
+		UserName: user.UserName,
+	}
+
+	return newResponse("RestoreAccountResponse", "RestoreAccountResponse", &responseBody)
+}
+
+func (s *Server) RequestMobileVerification(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error) {
+	body := RequestMobileVerificationRequest{}
+	err := unmarshalBody(req, RequestMobileVerificationRequestMethod, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := requestUserId(req)
+	if err != nil {
+		return nil, err
+	}
+
+	token := authorization.TokenDetail{
+		AccessToken: body.BearerToken,
+		UserId:      userId,
+	}
+
+	err = s.userService.RequestMobileVerification(ctx, &token)
+	if err != nil {
+		return nil, err
+	}
+
+	return newResponse("RequestMobileVerificationResponse", "", nil)
+}
+
+func (s *Server) VerifyMobile(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error) {
+	body := VerifyMobileRequest{}
+	err := unmarshalBody(req, VerifyMobileRequestMethod, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := requestUserId(req)
+	if err != nil {
+		return nil, err
+	}
+
+	token := authorization.TokenDetail{
+		AccessToken: body.BearerToken,
+		UserId:      userId,
+	}
+
+	err = s.userService.VerifyMobile(ctx, &token, body.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	return newResponse("VerifyMobileResponse", "", nil)
+}
+
+func (s *Server) RestrictUser(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error) {
+	body := RestrictUserRequest{}
+	err := unmarshalBody(req, RestrictUserRequestMethod, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := requestUserId(req)
+	if err != nil {
+		return nil, err
+	}
+
+	token := authorization.TokenDetail{
+		AccessToken: body.BearerToken,
+		UserId:      userId,
+	}
+
+	user, err := s.userService.RestrictUser(ctx, &token, body.UserId, entity.Status(body.Status), body.Reason, body.EndAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return newResponse("RestrictUserResponse", "RestrictionResponse", restrictionResponse(user))
+}
+
+func (s *Server) LiftRestriction(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error) {
+	body := LiftRestrictionRequest{}
+	err := unmarshalBody(req, LiftRestrictionRequestMethod, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := requestUserId(req)
+	if err != nil {
+		return nil, err
+	}
+
+	token := authorization.TokenDetail{
+		AccessToken: body.BearerToken,
+		UserId:      userId,
+	}
+
+	user, err := s.userService.LiftRestriction(ctx, &token, body.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	return newResponse("LiftRestrictionResponse", "RestrictionResponse", restrictionResponse(user))
+}
+
+func restrictionResponse(user *entity.User) *RestrictionResponse {
+	response := RestrictionResponse{
+		UserId: user.UserId,
+		Status: string(user.Status.Status),
+	}
+
+	if restriction := user.Status.Restriction; restriction != nil {
+		response.Reason = restriction.Reason
+		response.Actor = restriction.Actor
+		response.StartAt = restriction.StartAt
+		response.EndAt = restriction.EndAt
+	}
+
+	return &response
+}
+
+func (s *Server) EnrollTotp(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error) {
+	body := EnrollTotpRequest{}
+	err := unmarshalBody(req, EnrollTotpRequestMethod, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := requestUserId(req)
+	if err != nil {
+		return nil, err
+	}
+
+	token := authorization.TokenDetail{
+		AccessToken: body.BearerToken,
+		UserId:      userId,
+	}
+
+	secret, uri, err := s.userService.EnrollTotp(ctx, &token)
+	if err != nil {
+		return nil, err
+	}
+
+	responseBody := EnrollTotpResponse{
+		Secret: secret,
+		Uri:    uri,
+	}
+
+	return newResponse("EnrollTotpResponse", "EnrollTotpResponse", &responseBody)
+}
+
+func (s *Server) ConfirmTotp(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error) {
+	body := ConfirmTotpRequest{}
+	err := unmarshalBody(req, ConfirmTotpRequestMethod, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := requestUserId(req)
+	if err != nil {
+		return nil, err
+	}
+
+	token := authorization.TokenDetail{
+		AccessToken: body.BearerToken,
+		UserId:      userId,
+	}
+
+	recoveryCodes, err := s.userService.ConfirmTotp(ctx, &token, body.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	responseBody := ConfirmTotpResponse{
+		RecoveryCodes: recoveryCodes,
+	}
+
+	return newResponse("ConfirmTotpResponse", "ConfirmTotpResponse", &responseBody)
+}
+
+func (s *Server) CompleteMfa(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error) {
+	body := CompleteMfaRequest{}
+	err := unmarshalBody(req, CompleteMfaRequestMethod, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := s.userService.CompleteMfa(ctx, body.MfaToken, body.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	return tokenResponse(token)
+}
+
+func (s *Server) DisableMfa(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error) {
+	body := DisableMfaRequest{}
+	err := unmarshalBody(req, DisableMfaRequestMethod, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := requestUserId(req)
+	if err != nil {
+		return nil, err
+	}
+
+	token := authorization.TokenDetail{
+		AccessToken: body.BearerToken,
+		UserId:      userId,
+	}
+
+	err = s.userService.DisableMfa(ctx, &token, body.Password, body.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	return newResponse("DisableMfaResponse", "", nil)
+}
+
+func (s *Server) QueryAuditEvents(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error) {
+	body := QueryAuditEventsRequest{}
+	err := unmarshalBody(req, QueryAuditEventsRequestMethod, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := requestUserId(req)
+	if err != nil {
+		return nil, err
+	}
+
+	token := authorization.TokenDetail{
+		AccessToken: body.BearerToken,
+		UserId:      userId,
+	}
+
+	query := services.AuditQuery{
+		Type:      entity.AuditEventType(body.Type),
+		Actor:     body.Actor,
+		Target:    body.Target,
+		IpAddress: body.IpAddress,
+		Outcome:   entity.AuditOutcome(body.Outcome),
+		Page:      int(req.Header.Page),
+		PerPage:   int(req.Header.PerPage),
+	}
+
+	err = applyAuditMeta(req.Header, &query)
+	if err != nil {
+		return nil, err
+	}
+
+	events, total, err := s.userService.QueryAuditEvents(ctx, &token, query)
+	if err != nil {
+		return nil, err
+	}
+
+	responseBody := QueryAuditEventsResponse{
+		Events: make([]AuditEventInfo, 0, len(events)),
+	}
+	for _, event := range events {
+		responseBody.Events = append(responseBody.Events, AuditEventInfo{
+			EventId:   event.EventId,
+			Type:      string(event.Type),
+			Actor:     event.Actor,
+			Target:    event.Target,
+			IpAddress: event.IpAddress,
+			UserAgent: event.UserAgent,
+			Outcome:   string(event.Outcome),
+			Reason:    event.Reason,
+			Details:   event.Details,
+			Time:      event.Time,
+		})
+	}
+
+	response, err := newResponse("QueryAuditEventsResponse", "QueryAuditEventsResponse", &responseBody)
+	if err != nil {
+		return nil, err
+	}
+
+	// the meta tells the page the defaults of the service resulted in
+	response.Meta = &userproto.ResponseMetadata{
+		Total:   uint32(total),
+		Page:    uint32(query.Page),
+		PerPage: uint32(query.PerPage),
+	}
+	if response.Meta.Page == 0 {
+		response.Meta.Page = 1
+	}
+	if response.Meta.PerPage == 0 {
+		response.Meta.PerPage = uint32(services.DefaultAuditPerPage)
+	}
+	return response, nil
+}
+
+// applyAuditMeta adds the filter and the time range of the request header to query,
+// the filter type names the field ("type", "actor", "target", "ip-address" or
+// "outcome") and only compares for equality
+func applyAuditMeta(header *userproto.ReqMeta, query *services.AuditQuery) error {
+	if filter := header.Filters; filter != nil && filter.Type != "" {
+		if filter.Opt != "" && filter.Opt != "eq" {
+			return status.Error(http.StatusBadRequest, "audit events can only be filtered with eq")
+		}
+
+		switch entity.Path(filter.Type) {
+		case entity.AuditTypePath:
+			query.Type = entity.AuditEventType(filter.Value)
+		case entity.AuditActorPath:
+			query.Actor = filter.Value
+		case entity.AuditTargetPath:
+			query.Target = filter.Value
+		case entity.AuditIpAddressPath:
+			query.IpAddress = filter.Value
+		case entity.AuditOutcomePath:
+			query.Outcome = entity.AuditOutcome(filter.Value)
+		default:
+			return status.Error(http.StatusBadRequest, "audit events can not be filtered by "+filter.Type)
+		}
+	}
+
+	var err error
+	query.From, err = parseMetaTime(header.StartAt)
+	if err != nil {
+		return err
+	}
+
+	query.To, err = parseMetaTime(header.EndAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// parseMetaTime parses the RFC 3339 times of a request header, an empty value is no time
+func parseMetaTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, status.Error(http.StatusBadRequest, "startAt and endAt must be RFC 3339 times")
+	}
+
+	return &parsed, nil
+}
@@ -0,0 +1,239 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	userproto "github.com/Juno-chat-app/user-proto"
+	"google.golang.org/grpc"
+)
+
+const (
+	AccountServiceName string = "userservice.AccountService"
+)
+
+// AccountServiceServer holds the operations which are not part of the userproto
+// contract, they share its RequestMessage/ResponseMessage envelope so clients
+// can reuse the messages they already have
+type AccountServiceServer interface {
+	Keys(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error)
+	SignOut(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error)
+	ListSessions(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error)
+	RevokeSession(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error)
+	RevokeAllSessions(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error)
+	Introspect(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error)
+	ClientToken(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error)
+	RegisterClient(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error)
+	ActivateAccount(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error)
+	ResendActivation(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error)
+	RequestPasswordReset(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error)
+	ResetPassword(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error)
+	ChangePassword(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error)
+	UpdateProfile(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error)
+	ConfirmEmailChange(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error)
+	DeleteAccount(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error)
+	RequestAccountRestore(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error)
+	RestoreAccount(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error)
+	RequestMobileVerification(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error)
+	VerifyMobile(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error)
+	RestrictUser(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error)
+	LiftRestriction(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error)
+	EnrollTotp(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error)
+	ConfirmTotp(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error)
+	CompleteMfa(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error)
+	DisableMfa(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error)
+	QueryAuditEvents(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error)
+	LookupUser(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error)
+}
+
+type accountMethod func(srv AccountServiceServer, ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error)
+
+var accountServiceDesc = grpc.ServiceDesc{
+	ServiceName: AccountServiceName,
+	HandlerType: (*AccountServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		accountHandler("Keys", AccountServiceServer.Keys),
+		accountHandler("SignOut", AccountServiceServer.SignOut),
+		accountHandler("ListSessions", AccountServiceServer.ListSessions),
+		accountHandler("RevokeSession", AccountServiceServer.RevokeSession),
+		accountHandler("RevokeAllSessions", AccountServiceServer.RevokeAllSessions),
+		accountHandler("Introspect", AccountServiceServer.Introspect),
+		accountHandler("ClientToken", AccountServiceServer.ClientToken),
+		accountHandler("RegisterClient", AccountServiceServer.RegisterClient),
+		accountHandler("ActivateAccount", AccountServiceServer.ActivateAccount),
+		accountHandler("ResendActivation", AccountServiceServer.ResendActivation),
+		accountHandler("RequestPasswordReset", AccountServiceServer.RequestPasswordReset),
+		accountHandler("ResetPassword", AccountServiceServer.ResetPassword),
+		accountHandler("ChangePassword", AccountServiceServer.ChangePassword),
+		accountHandler("UpdateProfile", AccountServiceServer.UpdateProfile),
+		accountHandler("ConfirmEmailChange", AccountServiceServer.ConfirmEmailChange),
+		accountHandler("DeleteAccount", AccountServiceServer.DeleteAccount),
+		accountHandler("RequestAccountRestore", AccountServiceServer.RequestAccountRestore),
+		accountHandler("RestoreAccount", AccountServiceServer.RestoreAccount),
+		accountHandler("RequestMobileVerification", AccountServiceServer.RequestMobileVerification),
+		accountHandler("VerifyMobile", AccountServiceServer.VerifyMobile),
+		accountHandler("RestrictUser", AccountServiceServer.RestrictUser),
+		accountHandler("LiftRestriction", AccountServiceServer.LiftRestriction),
+		accountHandler("EnrollTotp", AccountServiceServer.EnrollTotp),
+		accountHandler("ConfirmTotp", AccountServiceServer.ConfirmTotp),
+		accountHandler("CompleteMfa", AccountServiceServer.CompleteMfa),
+		accountHandler("DisableMfa", AccountServiceServer.DisableMfa),
+		accountHandler("QueryAuditEvents", AccountServiceServer.QueryAuditEvents),
+		accountHandler("LookupUser", AccountServiceServer.LookupUser),
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "account_service.go",
+}
+
+func accountHandler(name string, method accountMethod) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			in := new(userproto.RequestMessage)
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+			if interceptor == nil {
+				return method(srv.(AccountServiceServer), ctx, in)
+			}
+			info := &grpc.UnaryServerInfo{
+				Server:     srv,
+				FullMethod: fmt.Sprintf("/%s/%s", AccountServiceName, name),
+			}
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				return method(srv.(AccountServiceServer), ctx, req.(*userproto.RequestMessage))
+			}
+			return interceptor(ctx, in, info, handler)
+		},
+	}
+}
+
+// AccountServiceClient calls the AccountService operations of a user-service
+type AccountServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAccountServiceClient(cc grpc.ClientConnInterface) *AccountServiceClient {
+	return &AccountServiceClient{cc: cc}
+}
+
+func (c *AccountServiceClient) Keys(ctx context.Context, in *userproto.RequestMessage, opts ...grpc.CallOption) (*userproto.ResponseMessage, error) {
+	return c.invoke(ctx, "Keys", in, opts...)
+}
+
+func (c *AccountServiceClient) SignOut(ctx context.Context, in *userproto.RequestMessage, opts ...grpc.CallOption) (*userproto.ResponseMessage, error) {
+	return c.invoke(ctx, "SignOut", in, opts...)
+}
+
+func (c *AccountServiceClient) ListSessions(ctx context.Context, in *userproto.RequestMessage, opts ...grpc.CallOption) (*userproto.ResponseMessage, error) {
+	return c.invoke(ctx, "ListSessions", in, opts...)
+}
+
+func (c *AccountServiceClient) RevokeSession(ctx context.Context, in *userproto.RequestMessage, opts ...grpc.CallOption) (*userproto.ResponseMessage, error) {
+	return c.invoke(ctx, "RevokeSession", in, opts...)
+}
+
+func (c *AccountServiceClient) RevokeAllSessions(ctx context.Context, in *userproto.RequestMessage, opts ...grpc.CallOption) (*userproto.ResponseMessage, error) {
+	return c.invoke(ctx, "RevokeAllSessions", in, opts...)
+}
+
+func (c *AccountServiceClient) Introspect(ctx context.Context, in *userproto.RequestMessage, opts ...grpc.CallOption) (*userproto.ResponseMessage, error) {
+	return c.invoke(ctx, "Introspect", in, opts...)
+}
+
+func (c *AccountServiceClient) ClientToken(ctx context.Context, in *userproto.RequestMessage, opts ...grpc.CallOption) (*userproto.ResponseMessage, error) {
+	return c.invoke(ctx, "ClientToken", in, opts...)
+}
+
+func (c *AccountServiceClient) RegisterClient(ctx context.Context, in *userproto.RequestMessage, opts ...grpc.CallOption) (*userproto.ResponseMessage, error) {
+	return c.invoke(ctx, "RegisterClient", in, opts...)
+}
+
+func (c *AccountServiceClient) ActivateAccount(ctx context.Context, in *userproto.RequestMessage, opts ...grpc.CallOption) (*userproto.ResponseMessage, error) {
+	return c.invoke(ctx, "ActivateAccount", in, opts...)
+}
+
+func (c *AccountServiceClient) ResendActivation(ctx context.Context, in *userproto.RequestMessage, opts ...grpc.CallOption) (*userproto.ResponseMessage, error) {
+	return c.invoke(ctx, "ResendActivation", in, opts...)
+}
+
+func (c *AccountServiceClient) RequestPasswordReset(ctx context.Context, in *userproto.RequestMessage, opts ...grpc.CallOption) (*userproto.ResponseMessage, error) {
+	return c.invoke(ctx, "RequestPasswordReset", in, opts...)
+}
+
+func (c *AccountServiceClient) ResetPassword(ctx context.Context, in *userproto.RequestMessage, opts ...grpc.CallOption) (*userproto.ResponseMessage, error) {
+	return c.invoke(ctx, "ResetPassword", in, opts...)
+}
+
+func (c *AccountServiceClient) ChangePassword(ctx context.Context, in *userproto.RequestMessage, opts ...grpc.CallOption) (*userproto.ResponseMessage, error) {
+	return c.invoke(ctx, "ChangePassword", in, opts...)
+}
+
+func (c *AccountServiceClient) UpdateProfile(ctx context.Context, in *userproto.RequestMessage, opts ...grpc.CallOption) (*userproto.ResponseMessage, error) {
+	return c.invoke(ctx, "UpdateProfile", in, opts...)
+}
+
+func (c *AccountServiceClient) ConfirmEmailChange(ctx context.Context, in *userproto.RequestMessage, opts ...grpc.CallOption) (*userproto.ResponseMessage, error) {
+	return c.invoke(ctx, "ConfirmEmailChange", in, opts...)
+}
+
+func (c *AccountServiceClient) DeleteAccount(ctx context.Context, in *userproto.RequestMessage, opts ...grpc.CallOption) (*userproto.ResponseMessage, error) {
+	return c.invoke(ctx, "DeleteAccount", in, opts...)
+}
+
+func (c *AccountServiceClient) RequestAccountRestore(ctx context.Context, in *userproto.RequestMessage, opts ...grpc.CallOption) (*userproto.ResponseMessage, error) {
+	return c.invoke(ctx, "RequestAccountRestore", in, opts...)
+}
+
+func (c *AccountServiceClient) RestoreAccount(ctx context.Context, in *userproto.RequestMessage, opts ...grpc.CallOption) (*userproto.ResponseMessage, error) {
+	return c.invoke(ctx, "RestoreAccount", in, opts...)
+}
+
+func (c *AccountServiceClient) RequestMobileVerification(ctx context.Context, in *userproto.RequestMessage, opts ...grpc.CallOption) (*userproto.ResponseMessage, error) {
+	return c.invoke(ctx, "RequestMobileVerification", in, opts...)
+}
+
+func (c *AccountServiceClient) VerifyMobile(ctx context.Context, in *userproto.RequestMessage, opts ...grpc.CallOption) (*userproto.ResponseMessage, error) {
+	return c.invoke(ctx, "VerifyMobile", in, opts...)
+}
+
+func (c *AccountServiceClient) RestrictUser(ctx context.Context, in *userproto.RequestMessage, opts ...grpc.CallOption) (*userproto.ResponseMessage, error) {
+	return c.invoke(ctx, "RestrictUser", in, opts...)
+}
+
+func (c *AccountServiceClient) LiftRestriction(ctx context.Context, in *userproto.RequestMessage, opts ...grpc.CallOption) (*userproto.ResponseMessage, error) {
+	return c.invoke(ctx, "LiftRestriction", in, opts...)
+}
+
+func (c *AccountServiceClient) EnrollTotp(ctx context.Context, in *userproto.RequestMessage, opts ...grpc.CallOption) (*userproto.ResponseMessage, error) {
+	return c.invoke(ctx, "EnrollTotp", in, opts...)
+}
+
+func (c *AccountServiceClient) ConfirmTotp(ctx context.Context, in *userproto.RequestMessage, opts ...grpc.CallOption) (*userproto.ResponseMessage, error) {
+	return c.invoke(ctx, "ConfirmTotp", in, opts...)
+}
+
+func (c *AccountServiceClient) CompleteMfa(ctx context.Context, in *userproto.RequestMessage, opts ...grpc.CallOption) (*userproto.ResponseMessage, error) {
+	return c.invoke(ctx, "CompleteMfa", in, opts...)
+}
+
+func (c *AccountServiceClient) DisableMfa(ctx context.Context, in *userproto.RequestMessage, opts ...grpc.CallOption) (*userproto.ResponseMessage, error) {
+	return c.invoke(ctx, "DisableMfa", in, opts...)
+}
+
+func (c *AccountServiceClient) QueryAuditEvents(ctx context.Context, in *userproto.RequestMessage, opts ...grpc.CallOption) (*userproto.ResponseMessage, error) {
+	return c.invoke(ctx, "QueryAuditEvents", in, opts...)
+}
+
+func (c *AccountServiceClient) LookupUser(ctx context.Context, in *userproto.RequestMessage, opts ...grpc.CallOption) (*userproto.ResponseMessage, error) {
+	return c.invoke(ctx, "LookupUser", in, opts...)
+}
+
+func (c *AccountServiceClient) invoke(ctx context.Context, name string, in *userproto.RequestMessage, opts ...grpc.CallOption) (*userproto.ResponseMessage, error) {
+	out := new(userproto.ResponseMessage)
+	err := c.cc.Invoke(ctx, fmt.Sprintf("/%s/%s", AccountServiceName, name), in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
@@ -0,0 +1,341 @@
+package grpc
+
+import (
+	"encoding/json"
+	"fmt"
+	userproto "github.com/Juno-chat-app/user-proto"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"time"
+)
+
+// The bodies of operations without a userproto message are JSON documents
+// carried in the Any value of the envelope, the type url names the document
+
+// unmarshalBody checks the type url of the request body and decodes it into body
+func unmarshalBody(req *userproto.RequestMessage, typeUrl string, body interface{}) error {
+	if req.Body == nil || req.Body.TypeUrl != typeUrl {
+		return status.Error(http.StatusBadRequest, fmt.Sprintf("request content type must be %s", typeUrl))
+	}
+
+	err := json.Unmarshal(req.Body.Value, body)
+	if err != nil {
+		return status.Error(http.StatusBadRequest, fmt.Sprintf("request body type is not %s", typeUrl))
+	}
+
+	return nil
+}
+
+// newResponse wraps body into a response message, a nil body results in an empty response
+func newResponse(entity string, typeUrl string, body interface{}) (*userproto.ResponseMessage, error) {
+	response := userproto.ResponseMessage{
+		Entity: entity,
+		Meta:   nil,
+		Data:   nil,
+	}
+
+	if body != nil {
+		value, err := json.Marshal(body)
+		if err != nil {
+			return nil, status.Error(http.StatusInternalServerError, "got error on generating response")
+		}
+
+		response.Data = &userproto.Any{
+			TypeUrl: typeUrl,
+			Value:   value,
+		}
+	}
+
+	return &response, nil
+}
+
+//============== UserService bodies without userproto message
+
+type ValidationResponse struct {
+	UserId string `json:"userId"`
+	// PrincipalType is "user" or "client", the user id of a client token is its client id
+	PrincipalType string       `json:"principalType"`
+	Permissions   []Permission `json:"permissions"`
+	// PermissionsOverflow tells the token carries none of the permissions as the user has too many
+	PermissionsOverflow bool     `json:"permissionsOverflow"`
+	Scopes              []string `json:"scopes,omitempty"`
+}
+
+type Permission struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+//============== AccountService bodies
+
+type SignOutRequest struct {
+	BearerToken string `json:"bearerToken"`
+}
+
+// LookupUserRequest looks a user up by exactly one of UserId, Email or Mobile, it is
+// GetUser with the bearer token in the body.
+// The response is an UpdateProfileResponse with the contact info the caller may see
+type LookupUserRequest struct {
+	BearerToken string `json:"bearerToken"`
+	UserId      string `json:"userId"`
+	Email       string `json:"email"`
+	Mobile      string `json:"mobile"`
+}
+
+type ListSessionsRequest struct {
+	BearerToken string `json:"bearerToken"`
+}
+
+type ListSessionsResponse struct {
+	Sessions []SessionInfo `json:"sessions"`
+}
+
+type SessionInfo struct {
+	SessionId     string     `json:"sessionId"`
+	IpAddress     string     `json:"ipAddress"`
+	UserAgent     string     `json:"userAgent"`
+	CreatedAt     *time.Time `json:"createdAt"`
+	LastRefreshAt *time.Time `json:"lastRefreshAt"`
+	// Current marks the session of the token used for the request
+	Current bool `json:"current"`
+}
+
+type RevokeSessionRequest struct {
+	BearerToken string `json:"bearerToken"`
+	SessionId   string `json:"sessionId"`
+}
+
+type RevokeAllSessionsRequest struct {
+	BearerToken string `json:"bearerToken"`
+}
+
+type IntrospectRequest struct {
+	// BearerToken is the access token of the calling client
+	BearerToken   string `json:"bearerToken"`
+	Token         string `json:"token"`
+	TokenTypeHint string `json:"token_type_hint"`
+}
+
+// IntrospectResponse follows the member names of RFC 7662
+type IntrospectResponse struct {
+	Active      bool         `json:"active"`
+	TokenType   string       `json:"token_type,omitempty"`
+	Subject     string       `json:"sub,omitempty"`
+	ClientId    string       `json:"client_id,omitempty"`
+	Principal   string       `json:"principal_type,omitempty"`
+	Scope       string       `json:"scope,omitempty"`
+	Permissions []Permission `json:"permissions,omitempty"`
+	IssuedAt    int64        `json:"iat,omitempty"`
+	ExpireAt    int64        `json:"exp,omitempty"`
+	SessionId   string       `json:"sid,omitempty"`
+	IpAddress   string       `json:"client_ip,omitempty"`
+	UserAgent   string       `json:"user_agent,omitempty"`
+}
+
+type ClientTokenRequest struct {
+	ClientId     string `json:"clientId"`
+	ClientSecret string `json:"clientSecret"`
+	// Scope is a space separated list, empty asks for every allowed scope
+	Scope string `json:"scope"`
+}
+
+type ClientTokenResponse struct {
+	AccessToken string `json:"accessToken"`
+	TokenType   string `json:"tokenType"`
+	ExpireAt    int64  `json:"expireAt"`
+	Scope       string `json:"scope"`
+}
+
+type RegisterClientRequest struct {
+	BearerToken string   `json:"bearerToken"`
+	Name        string   `json:"name"`
+	Scopes      []string `json:"scopes"`
+}
+
+type RegisterClientResponse struct {
+	ClientId     string   `json:"clientId"`
+	ClientSecret string   `json:"clientSecret"`
+	Name         string   `json:"name"`
+	Scopes       []string `json:"scopes"`
+}
+
+type ActivateAccountRequest struct {
+	Email          string `json:"email"`
+	ActivationCode string `json:"activationCode"`
+}
+
+type ResendActivationRequest struct {
+	Email string `json:"email"`
+}
+
+type RequestPasswordResetRequest struct {
+	// Login is the email or the user-name of the account
+	Login string `json:"login"`
+}
+
+type ResetPasswordRequest struct {
+	ResetToken  string `json:"resetToken"`
+	NewPassword string `json:"newPassword"`
+}
+
+type ChangePasswordRequest struct {
+	BearerToken string `json:"bearerToken"`
+	OldPassword string `json:"oldPassword"`
+	NewPassword string `json:"newPassword"`
+}
+
+type UpdateProfileRequest struct {
+	BearerToken string `json:"bearerToken"`
+	// UpdateMask names the fields to update, e.g. "user-name", "contact-info.email"
+	// or "profile.display-name", fields not in it are ignored
+	UpdateMask []string    `json:"updateMask"`
+	UserName   string      `json:"userName"`
+	Email      string      `json:"email"`
+	Mobile     string      `json:"mobile"`
+	Phone      string      `json:"phone"`
+	Profile    ProfileInfo `json:"profile"`
+}
+
+type ProfileInfo struct {
+	DisplayName string `json:"displayName"`
+	Bio         string `json:"bio"`
+	AvatarUrl   string `json:"avatarUrl"`
+	Locale      string `json:"locale"`
+}
+
+type UpdateProfileResponse struct {
+	UserId   string      `json:"userId"`
+	UserName string      `json:"userName"`
+	Email    string      `json:"email"`
+	Mobile   string      `json:"mobile"`
+	Phone    string      `json:"phone"`
+	Profile  ProfileInfo `json:"profile"`
+}
+
+type DeleteAccountRequest struct {
+	BearerToken string `json:"bearerToken"`
+	Password    string `json:"password"`
+}
+
+type RequestAccountRestoreRequest struct {
+	Email string `json:"email"`
+}
+
+// RestoreAccountRequest restores with the mailed restore code, or as an admin
+// with the bearer token and the user-id of the deleted account
+type RestoreAccountRequest struct {
+	RestoreCode string `json:"restoreCode"`
+	BearerToken string `json:"bearerToken"`
+	UserId      string `json:"userId"`
+}
+
+type RestoreAccountResponse struct {
+	UserId   string `json:"userId"`
+	UserName string `json:"userName"`
+}
+
+type ConfirmEmailChangeRequest struct {
+	BearerToken string `json:"bearerToken"`
+	Code        string `json:"code"`
+}
+
+type RequestMobileVerificationRequest struct {
+	BearerToken string `json:"bearerToken"`
+}
+
+type VerifyMobileRequest struct {
+	BearerToken string `json:"bearerToken"`
+	Code        string `json:"code"`
+}
+
+// RestrictUserRequest suspends or bans the user of UserId, Status is suspended or
+// banned and only suspensions may have an EndAt
+type RestrictUserRequest struct {
+	BearerToken string     `json:"bearerToken"`
+	UserId      string     `json:"userId"`
+	Status      string     `json:"status"`
+	Reason      string     `json:"reason"`
+	EndAt       *time.Time `json:"endAt,omitempty"`
+}
+
+type LiftRestrictionRequest struct {
+	BearerToken string `json:"bearerToken"`
+	UserId      string `json:"userId"`
+}
+
+type EnrollTotpRequest struct {
+	BearerToken string `json:"bearerToken"`
+}
+
+// EnrollTotpResponse carries the secret for manual entry and the otpauth uri for a QR code
+type EnrollTotpResponse struct {
+	Secret string `json:"secret"`
+	Uri    string `json:"uri"`
+}
+
+type ConfirmTotpRequest struct {
+	BearerToken string `json:"bearerToken"`
+	Code        string `json:"code"`
+}
+
+// ConfirmTotpResponse holds the recovery codes, they are not shown again
+type ConfirmTotpResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// MfaChallengeResponse is the SignIn response of users with a second factor
+type MfaChallengeResponse struct {
+	MfaToken string `json:"mfaToken"`
+	ExpireAt int64  `json:"expireAt"`
+}
+
+// CompleteMfaRequest answers the challenge of a sign in, Code is a TOTP or a recovery code.
+// The response is the userproto Response of SignIn
+type CompleteMfaRequest struct {
+	MfaToken string `json:"mfaToken"`
+	Code     string `json:"code"`
+}
+
+type DisableMfaRequest struct {
+	BearerToken string `json:"bearerToken"`
+	Password    string `json:"password"`
+	Code        string `json:"code"`
+}
+
+// QueryAuditEventsRequest filters by every non empty field, the header adds its
+// filter, the time range of startAt and endAt (RFC 3339) and the page
+type QueryAuditEventsRequest struct {
+	BearerToken string `json:"bearerToken"`
+	Type        string `json:"type"`
+	Actor       string `json:"actor"`
+	Target      string `json:"target"`
+	IpAddress   string `json:"ipAddress"`
+	Outcome     string `json:"outcome"`
+}
+
+// QueryAuditEventsResponse lists the events newest first, the total is in the response meta
+type QueryAuditEventsResponse struct {
+	Events []AuditEventInfo `json:"events"`
+}
+
+type AuditEventInfo struct {
+	EventId   string            `json:"eventId"`
+	Type      string            `json:"type"`
+	Actor     string            `json:"actor,omitempty"`
+	Target    string            `json:"target,omitempty"`
+	IpAddress string            `json:"ipAddress,omitempty"`
+	UserAgent string            `json:"userAgent,omitempty"`
+	Outcome   string            `json:"outcome"`
+	Reason    string            `json:"reason,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+	Time      *time.Time        `json:"time"`
+}
+
+type RestrictionResponse struct {
+	UserId  string     `json:"userId"`
+	Status  string     `json:"status"`
+	Reason  string     `json:"reason,omitempty"`
+	Actor   string     `json:"actor,omitempty"`
+	StartAt *time.Time `json:"startAt,omitempty"`
+	EndAt   *time.Time `json:"endAt,omitempty"`
+}
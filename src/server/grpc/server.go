@@ -1,12 +1,16 @@
 package grpc
 
 import (
+	"context"
 	"fmt"
 	userproto "github.com/Juno-chat-app/user-proto"
 	"github.com/Juno-chat-app/user-service/domain/model/services"
 	"github.com/Juno-chat-app/user-service/infra/logger"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"net"
+	"net/http"
 )
 
 const (
@@ -15,6 +19,40 @@ const (
 	ValidateRequestMethod string = "ValidateRequest"
 	RefreshRequestMethod  string = "RefreshRequest"
 	GetUserRequestMethod  string = "GetUserRequest"
+	SignOutRequestMethod  string = "SignOutRequest"
+
+	ListSessionsRequestMethod      string = "ListSessionsRequest"
+	RevokeSessionRequestMethod     string = "RevokeSessionRequest"
+	RevokeAllSessionsRequestMethod string = "RevokeAllSessionsRequest"
+	IntrospectRequestMethod        string = "IntrospectRequest"
+	ClientTokenRequestMethod       string = "ClientTokenRequest"
+	RegisterClientRequestMethod    string = "RegisterClientRequest"
+	ActivateAccountRequestMethod   string = "ActivateAccountRequest"
+	ResendActivationRequestMethod  string = "ResendActivationRequest"
+
+	RequestPasswordResetRequestMethod string = "RequestPasswordResetRequest"
+	ResetPasswordRequestMethod        string = "ResetPasswordRequest"
+	ChangePasswordRequestMethod       string = "ChangePasswordRequest"
+	UpdateProfileRequestMethod        string = "UpdateProfileRequest"
+	ConfirmEmailChangeRequestMethod   string = "ConfirmEmailChangeRequest"
+	DeleteAccountRequestMethod        string = "DeleteAccountRequest"
+
+	RequestAccountRestoreRequestMethod string = "RequestAccountRestoreRequest"
+	RestoreAccountRequestMethod        string = "RestoreAccountRequest"
+
+	RequestMobileVerificationRequestMethod string = "RequestMobileVerificationRequest"
+	VerifyMobileRequestMethod              string = "VerifyMobileRequest"
+
+	RestrictUserRequestMethod    string = "RestrictUserRequest"
+	LiftRestrictionRequestMethod string = "LiftRestrictionRequest"
+
+	EnrollTotpRequestMethod  string = "EnrollTotpRequest"
+	ConfirmTotpRequestMethod string = "ConfirmTotpRequest"
+	CompleteMfaRequestMethod string = "CompleteMfaRequest"
+	DisableMfaRequestMethod  string = "DisableMfaRequest"
+
+	QueryAuditEventsRequestMethod string = "QueryAuditEventsRequest"
+	LookupUserRequestMethod       string = "LookupUserRequest"
 )
 
 type Server struct {
@@ -54,8 +92,9 @@ func (s *Server) Start() error {
 		"host", s.address,
 		"port", s.port)
 
-	grpcServer := grpc.NewServer()
+	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(clientInfoInterceptor))
 	userproto.RegisterUserServiceServer(grpcServer, s)
+	grpcServer.RegisterService(&accountServiceDesc, s)
 	if err := grpcServer.Serve(lis); err != nil {
 		s.logger.Error("Got error on listening",
 			"method", "Start",
@@ -68,3 +107,42 @@ func (s *Server) Start() error {
 
 	return nil
 }
+
+// clientInfoInterceptor hands the client ip address of the request header and
+// the user agent of the call to the service layer
+func clientInfoInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	client := services.ClientInfo{}
+
+	if message, ok := req.(*userproto.RequestMessage); ok && message.Header != nil {
+		client.IpAddress = message.Header.IpAddress
+	}
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if userAgent := md.Get("user-agent"); len(userAgent) > 0 {
+			client.UserAgent = userAgent[0]
+		}
+	}
+
+	return handler(services.WithClientInfo(ctx, client), req)
+}
+
+// requestUserId returns the user-id of the request header
+func requestUserId(req *userproto.RequestMessage) (string, error) {
+	if req.Header == nil || req.Header.UID == "" {
+		return "", status.Error(http.StatusBadRequest, "invalid user-id")
+	}
+
+	return req.Header.UID, nil
+}
+
+// requestBearerToken returns the access token of the authorization metadata, it
+// authenticates the operations whose userproto message has no token field
+func requestBearerToken(ctx context.Context) (string, error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if authorization := md.Get("authorization"); len(authorization) > 0 && authorization[0] != "" {
+			return authorization[0], nil
+		}
+	}
+
+	return "", status.Error(http.StatusUnauthorized, "missing authorization metadata")
+}
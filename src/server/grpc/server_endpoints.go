@@ -5,6 +5,7 @@ import (
 	userproto "github.com/Juno-chat-app/user-proto"
 	"github.com/Juno-chat-app/user-service/domain/entity"
 	"github.com/Juno-chat-app/user-service/domain/model/authorization"
+	"github.com/Juno-chat-app/user-service/domain/model/services"
 	"github.com/golang/protobuf/proto"
 	"google.golang.org/grpc/status"
 	"net/http"
@@ -31,10 +32,25 @@ func (s *Server) SignIn(ctx context.Context, req *userproto.RequestMessage, ) (*
 		return nil, err
 	}
 
+	// the tokens of users with a second factor are issued by CompleteMfa
+	if signInResult.MfaToken != "" {
+		challenge := MfaChallengeResponse{
+			MfaToken: signInResult.MfaToken,
+			ExpireAt: signInResult.ExpireAt,
+		}
+
+		return newResponse("MfaChallengeResponse", "MfaChallengeResponse", &challenge)
+	}
+
+	return tokenResponse(signInResult)
+}
+
+// tokenResponse is the userproto response of a sign in
+func tokenResponse(token *authorization.TokenDetail) (*userproto.ResponseMessage, error) {
 	responseBody := userproto.Response{
-		BearerToken:  signInResult.AccessToken,
-		Duration:     signInResult.ExpireAt,
-		RefreshToken: signInResult.RefreshToke,
+		BearerToken:  token.AccessToken,
+		Duration:     token.ExpireAt,
+		RefreshToken: token.RefreshToke,
 	}
 
 	responseBodyByte, err := proto.Marshal(&responseBody)
@@ -69,7 +85,8 @@ func (s *Server) SignUp(ctx context.Context, req *userproto.RequestMessage) (*us
 		UserName: body.UserName,
 		Password: body.Password,
 		ContactInfo: &entity.ContactInfo{
-			Email: body.Email,
+			Email:  body.Email,
+			Mobile: body.PhoneNumber,
 		},
 	}
 
@@ -107,19 +124,24 @@ func (s *Server) Verify(ctx context.Context, req *userproto.RequestMessage) (*us
 		UserId:      req.Header.UID,
 	}
 
-	_, err = s.userService.Validate(ctx, &verificationToken)
+	token, err := s.userService.Validate(ctx, &verificationToken)
 
 	if err != nil {
 		return nil, err
 	}
 
-	response := userproto.ResponseMessage{
-		Entity: "ValidationResponse",
-		Meta:   nil,
-		Data:   nil,
+	responseBody := ValidationResponse{
+		UserId:              token.UserId,
+		PrincipalType:       token.PrincipalType,
+		Permissions:         make([]Permission, 0, len(token.Permissions)),
+		PermissionsOverflow: token.PermissionsOverflow,
+		Scopes:              token.Scopes,
+	}
+	for _, p := range token.Permissions {
+		responseBody.Permissions = append(responseBody.Permissions, Permission{Key: p.Key, Value: p.Value})
 	}
 
-	return &response, nil
+	return newResponse("ValidationResponse", "ValidationResponse", &responseBody)
 }
 
 func (s *Server) Refresh(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error) {
@@ -138,7 +160,7 @@ func (s *Server) Refresh(ctx context.Context, req *userproto.RequestMessage) (*u
 	}
 
 	verificationToken := authorization.TokenDetail{
-		AccessToken: body.RefreshToken,
+		RefreshToke: body.RefreshToken,
 		UserId:      req.Header.UID,
 	}
 
@@ -172,7 +194,7 @@ func (s *Server) Refresh(ctx context.Context, req *userproto.RequestMessage) (*u
 }
 
 func (s *Server) GetUser(ctx context.Context, req *userproto.RequestMessage) (*userproto.ResponseMessage, error) {
-	if req.Body.TypeUrl != GetUserRequestMethod {
+	if req.Body == nil || req.Body.TypeUrl != GetUserRequestMethod {
 		return nil, status.Error(http.StatusBadRequest, "request content type must be GetUserRequest")
 	}
 
@@ -182,12 +204,31 @@ func (s *Server) GetUser(ctx context.Context, req *userproto.RequestMessage) (*u
 		return nil, status.Error(http.StatusBadRequest, "request body type is not GetUserRequest")
 	}
 
-	contactInfo := entity.ContactInfo{
-		Mobile: body.SearchInfo.PhoneNumber,
-		Email:  body.SearchInfo.Email,
+	userId, err := requestUserId(req)
+	if err != nil {
+		return nil, err
+	}
+
+	bearerToken, err := requestBearerToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token := authorization.TokenDetail{
+		AccessToken: bearerToken,
+		UserId:      userId,
+	}
+
+	// the proto message has no user-id, a lookup by id names the user in the FID header
+	query := services.UserQuery{
+		UserId: req.Header.FID,
+	}
+	if body.SearchInfo != nil {
+		query.Email = body.SearchInfo.Email
+		query.Mobile = body.SearchInfo.PhoneNumber
 	}
 
-	user, err := s.userService.GetUser(ctx, contactInfo)
+	user, err := s.userService.GetUser(ctx, &token, query)
 	if err != nil {
 		return nil, err
 	}
@@ -6,13 +6,18 @@ import (
 	userproto "github.com/Juno-chat-app/user-proto"
 	"github.com/Juno-chat-app/user-service/config"
 	"github.com/Juno-chat-app/user-service/domain/model/authorization"
+	"github.com/Juno-chat-app/user-service/domain/model/mfa"
+	"github.com/Juno-chat-app/user-service/domain/model/password"
 	"github.com/Juno-chat-app/user-service/domain/model/services"
 	"github.com/Juno-chat-app/user-service/domain/repository/mongo"
 	"github.com/Juno-chat-app/user-service/domain/repository/redis"
 	"github.com/Juno-chat-app/user-service/infra/logger"
+	"github.com/Juno-chat-app/user-service/infra/mailer"
+	"github.com/Juno-chat-app/user-service/infra/sms"
 	"github.com/golang/protobuf/proto"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	"io/ioutil"
 	"os"
 	"testing"
 	"time"
@@ -20,6 +25,7 @@ import (
 
 var (
 	server *Server
+	conn   *grpc.ClientConn
 	client userproto.UserServiceClient
 )
 
@@ -37,7 +43,11 @@ func TestMain(m *testing.M) {
 		os.Exit(1)
 	}
 
-	auth := authorization.NewJwtHandler(int64(conf.AuthConfig.AccessTTL*time.Minute), int64(conf.AuthConfig.RefreshTTL*time.Hour), log)
+	auth, err := authorization.NewJwtHandler(conf.AuthConfig, log)
+	if err != nil {
+		log.Error("got error on loading signing keys", "err", err)
+		os.Exit(1)
+	}
 
 	cacheConfig := conf.CQRSConfig.CacheConfig
 	cache := redis.NewCache(cacheConfig.Host, cacheConfig.Port, cacheConfig.Password, cacheConfig.Db, cacheConfig.Retry, log)
@@ -54,7 +64,31 @@ func TestMain(m *testing.M) {
 		os.Exit(1)
 	}
 
-	service := services.NewUserService(log, repo, cache, auth)
+	clients := mongo.NewClientRepository(conf.CQRSConfig.PersistConfig, log)
+	audits := mongo.NewAuditRepository(conf.CQRSConfig.PersistConfig, log)
+	authConfig := conf.AuthConfig
+	revocations := services.NewRevocationList(cache, authConfig.AccessTTL*time.Minute+authConfig.Leeway*time.Second,
+		authConfig.Leeway*time.Second, authConfig.RevocationReload*time.Second, log)
+	outbox, err := ioutil.TempDir("", "user-service-outbox")
+	if err != nil {
+		os.Exit(1)
+	}
+	mail := mailer.NewOutboxMailer(outbox, conf.MailConfig.From, log)
+	smsSender := sms.NewOutboxSmsSender(outbox, log)
+	passwordPolicy, err := password.NewPasswordPolicy(conf.PasswordConfig, log)
+	if err != nil {
+		os.Exit(1)
+	}
+	passwordHasher, err := password.NewPasswordHasher(conf.HashConfig, log)
+	if err != nil {
+		os.Exit(1)
+	}
+	totp, err := mfa.NewTotpHandler(conf.MfaConfig, log)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	service := services.NewUserService(conf, log, repo, clients, cache, auth, revocations, mail, smsSender, passwordPolicy, passwordHasher, totp, audits)
 	server = NewServer(conf.GRPCConfig.Host, conf.GRPCConfig.Port, service, log)
 	go func() {
 		err := server.Start()
@@ -66,20 +100,21 @@ func TestMain(m *testing.M) {
 	// warm-up server
 	time.Sleep(time.Second)
 
-	conn, err := grpc.Dial(fmt.Sprintf("%s:%d", conf.GRPCConfig.Host, conf.GRPCConfig.Port), grpc.WithInsecure(), grpc.WithBlock())
+	conn, err = grpc.Dial(fmt.Sprintf("%s:%d", conf.GRPCConfig.Host, conf.GRPCConfig.Port), grpc.WithInsecure(), grpc.WithBlock())
 	if err != nil {
 		panic(err)
 	}
 	client = userproto.NewUserServiceClient(conn)
 
 	code := m.Run()
+	_ = os.RemoveAll(outbox)
 	os.Exit(code)
 }
 
 func TestServer_SignUp(t *testing.T) {
 	req := userproto.SignUpRequest{
 		UserName: "test",
-		Email:    "test",
+		Email:    "test@juno.com",
 		Password: "test",
 	}
 	reqBody, err := proto.Marshal(&req)
@@ -99,3 +134,15 @@ func TestServer_SignUp(t *testing.T) {
 	_, err = client.SignUp(context.Background(), &request)
 	require.Nil(t, err)
 }
+
+func TestServer_Keys(t *testing.T) {
+	request := userproto.RequestMessage{
+		Name: "",
+		Type: "",
+		Time: "",
+	}
+
+	response, err := NewAccountServiceClient(conn).Keys(context.Background(), &request)
+	require.Nil(t, err)
+	require.Equal(t, "JsonWebKeySet", response.Data.TypeUrl)
+}
@@ -0,0 +1,87 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Juno-chat-app/user-service/domain/model/services"
+	"github.com/Juno-chat-app/user-service/infra/logger"
+	"net/http"
+)
+
+const (
+	JwksPath string = "/.well-known/jwks.json"
+)
+
+// Server publishes the documents which have to be reachable without a gRPC client
+type Server struct {
+	address     string
+	port        int32
+	userService services.IUserService
+	logger      logger.ILogger
+}
+
+func NewServer(address string, port int32, userService services.IUserService, logger logger.ILogger) *Server {
+	server := Server{
+		address:     address,
+		port:        port,
+		userService: userService,
+		logger:      logger,
+	}
+
+	return &server
+}
+
+func (s *Server) Start() error {
+	address := fmt.Sprintf("%s:%d", s.address, s.port)
+
+	s.logger.Info("HTTP Server started",
+		"method", "Start",
+		"host", s.address,
+		"port", s.port)
+
+	if err := http.ListenAndServe(address, s.Handler()); err != nil {
+		s.logger.Error("Got error on listening",
+			"method", "Start",
+			"host", s.address,
+			"port", s.port,
+			"error", err)
+
+		return err
+	}
+
+	return nil
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(JwksPath, s.jwks)
+
+	return mux
+}
+
+func (s *Server) jwks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	keySet, err := s.userService.PublicKeys(r.Context())
+	if err != nil {
+		s.logger.Error("got error on loading public keys",
+			"method", "jwks",
+			"error", err)
+
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/jwk-set+json")
+	// verifiers should poll often enough to pick up a rotation before the old key retires
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	err = json.NewEncoder(w).Encode(keySet)
+	if err != nil {
+		s.logger.Error("got error on writing public keys",
+			"method", "jwks",
+			"error", err)
+	}
+}